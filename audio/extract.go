@@ -12,8 +12,14 @@ type Extractor interface {
 	Transcode(video io.Reader, audio io.Writer) error
 	Supported() bool
 	Ext() string
+	// Preset names the output format produced by Transcode.
+	Preset() string
+	// Filters describes the audio filters applied by Transcode.
+	Filters() string
 }
 
+var ErrNoExtractor = errors.New("No supported extractor found")
+
 func FindSupportedExtractor(extractors ...Extractor) (Extractor, error) {
 	for _, e := range extractors {
 		if e.Supported() {
@@ -21,30 +27,58 @@ func FindSupportedExtractor(extractors ...Extractor) (Extractor, error) {
 		}
 	}
 
-	return nil, errors.New("No supported extractor found")
+	return nil, ErrNoExtractor
+}
+
+// ArgSetter is implemented by extractors that accept extra command line
+// arguments for transcoding.
+type ArgSetter interface {
+	SetArgs(args []string)
 }
 
 type GenericExtractor struct {
-	cmd  string
-	args []string
-	ext  string
+	cmd     string
+	args    []string
+	ext     string
+	preset  string
+	filters string
+	// in is the amount of leading input arguments in args,
+	// extra arguments are inserted after them.
+	in int
+	// extractArgs copy the audio track as is, args are used if empty.
+	extractArgs []string
 }
 
 func (m *GenericExtractor) Name() string {
 	return m.cmd
 }
 
+func (m *GenericExtractor) SetArgs(extra []string) {
+	args := make([]string, 0, len(m.args)+len(extra))
+	args = append(args, m.args[:m.in]...)
+	args = append(args, extra...)
+	m.args = append(args, m.args[m.in:]...)
+}
+
 func (m *GenericExtractor) Supported() bool {
 	_, err := exec.LookPath(m.cmd)
 	return err == nil
 }
 
 func (m *GenericExtractor) Transcode(v io.Reader, a io.Writer) error {
-	return m.Extract(v, a)
+	return m.run(m.args, v, a)
 }
 
 func (m *GenericExtractor) Extract(v io.Reader, a io.Writer) error {
-	cmd := exec.Command(m.cmd, m.args...)
+	if len(m.extractArgs) == 0 {
+		return m.Transcode(v, a)
+	}
+
+	return m.run(m.extractArgs, v, a)
+}
+
+func (m *GenericExtractor) run(args []string, v io.Reader, a io.Writer) error {
+	cmd := exec.Command(m.cmd, args...)
 	cmd.Stdin = v
 	cmd.Stdout = a
 	return cmd.Run()
@@ -57,3 +91,15 @@ func (m *GenericExtractor) Ext() string {
 
 	return m.ext
 }
+
+func (m *GenericExtractor) Preset() string {
+	if m.preset == "" {
+		return m.Ext()
+	}
+
+	return m.preset
+}
+
+func (m *GenericExtractor) Filters() string {
+	return m.filters
+}
@@ -1,15 +1,202 @@
 package audio
 
-func NewFFMPEG() *GenericExtractor {
-	return &GenericExtractor{
-		cmd: "ffmpeg",
-		args: []string{
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultFilters trims leading silence.
+var DefaultFilters = &Filters{TrimStart: true, TrimThreshold: -90}
+
+// FFMPEG transcodes using a Preset and Filters.
+type FFMPEG struct {
+	GenericExtractor
+	p     *Preset
+	f     *Filters
+	extra []string
+}
+
+func NewFFMPEG() *FFMPEG {
+	p, _ := ParsePreset("aac")
+	return NewFFMPEGPreset(p, DefaultFilters)
+}
+
+// NewFFMPEGPreset transcodes using p and applies f, see Filters.Validate.
+func NewFFMPEGPreset(p *Preset, f *Filters) *FFMPEG {
+	e := &FFMPEG{p: p, f: f}
+	e.GenericExtractor = GenericExtractor{
+		cmd:     "ffmpeg",
+		preset:  p.Name(),
+		filters: f.String(),
+		ext:     p.Ext(),
+		args:    e.args(0, false),
+		extractArgs: []string{
 			"-i", "-",
 			"-vn",
-			//"-acodec", "copy",
-			"-af", "silenceremove=1:0:0:1:0:0",
-			"-f", "adts",
+			"-c:a", "copy",
+			"-f", "matroska",
 			"-",
 		},
 	}
+
+	return e
+}
+
+func (e *FFMPEG) args(dur time.Duration, detect bool) []string {
+	args := append([]string{"-nostats", "-i", "-"}, e.extra...)
+	args = append(args, "-vn")
+	if chain := e.f.graph(dur, detect); chain != "" {
+		args = append(args, "-af", chain)
+	}
+
+	return append(append(args, e.p.args...), "-")
+}
+
+func (e *FFMPEG) SetArgs(extra []string) {
+	e.extra = extra
+	e.GenericExtractor.args = e.args(0, false)
+}
+
+// NeedsDuration reports whether the filters depend on the duration of
+// the input, see TranscodeFilters.
+func (e *FFMPEG) NeedsDuration() bool {
+	return e.f != nil && e.f.FadeOut > 0
+}
+
+// Silence is the silence found by TranscodeFilters.
+type Silence struct {
+	// Leading is the duration that was trimmed from the start.
+	Leading time.Duration
+	// Trailing is the position in the output after which there is only
+	// silence, 0 if there is none or TrimEnd is off, see Cut.
+	Trailing time.Duration
+}
+
+// TranscodeFilters is Transcode for an input of duration dur, which is
+// required to fade out. Trailing silence is not removed, the output
+// should be cut at Silence.Trailing.
+func (e *FFMPEG) TranscodeFilters(v io.Reader, a io.Writer, dur time.Duration) (Silence, error) {
+	detect := e.f != nil && (e.f.TrimStart || e.f.TrimEnd)
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command(e.cmd, e.args(dur, detect)...)
+	cmd.Stdin = v
+	cmd.Stdout = a
+	if detect {
+		cmd.Stderr = buf
+	}
+	if err := cmd.Run(); err != nil {
+		return Silence{}, err
+	}
+
+	return e.f.silence(buf.String()), nil
+}
+
+// Cut truncates file, as written by TranscodeFilters, to length
+// without transcoding it again.
+func (e *FFMPEG) Cut(file string, length time.Duration) error {
+	tmp := file + ".cut"
+	args := []string{"-nostats", "-v", "error", "-i", file, "-c", "copy", "-t", secs(length)}
+	args = append(append(args, e.p.format()...), "-y", tmp)
+	if err := exec.Command(e.cmd, args...).Run(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+var (
+	silenceStart = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEnd   = regexp.MustCompile(`silence_end: ([\d.]+)`)
+	outputTime   = regexp.MustCompile(`time=(\d+):(\d+):(\d+(?:\.\d+)?)`)
+)
+
+// silence reads the silences of the input logged by silencedetect.
+func (f *Filters) silence(log string) Silence {
+	var s Silence
+	if f == nil {
+		return s
+	}
+	if f.TrimStart {
+		s.Leading = leadingSilence(log)
+	}
+	if f.TrimEnd {
+		s.Trailing = trailingSilence(log, s.Leading, f.TrimDuration)
+	}
+
+	return s
+}
+
+// trailingSilence returns the position in the output where the last
+// silence logged by silencedetect starts if it lasts until the end of
+// the input and at least min. lead was trimmed from the start of the
+// output, whose duration ffmpeg reports last.
+func trailingSilence(log string, lead, min time.Duration) time.Duration {
+	starts := silenceStart.FindAllStringSubmatchIndex(log, -1)
+	if len(starts) == 0 {
+		return 0
+	}
+	last := starts[len(starts)-1]
+	start, err := seconds(log[last[2]:last[3]])
+	if err != nil || start <= lead {
+		return 0
+	}
+
+	end := time.Duration(-1)
+	if times := outputTime.FindAllStringSubmatch(log, -1); len(times) != 0 {
+		t := times[len(times)-1]
+		h, _ := strconv.Atoi(t[1])
+		m, _ := strconv.Atoi(t[2])
+		s, _ := seconds(t[3])
+		end = lead + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + s
+	}
+
+	// newer versions of ffmpeg log the end of a silence at the end of
+	// the input as well
+	if m := silenceEnd.FindStringSubmatch(log[last[1]:]); m != nil {
+		e, err := seconds(m[1])
+		if err != nil || end < 0 || e < end-time.Millisecond*50 {
+			return 0
+		}
+		end = e
+	}
+
+	if end >= 0 && end-start < min {
+		return 0
+	}
+
+	return start - lead
+}
+
+func seconds(s string) (time.Duration, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	return time.Duration(f * float64(time.Second)), err
+}
+
+// leadingSilence returns the end of the first silence logged by
+// silencedetect if it starts at the start of the input.
+func leadingSilence(log string) time.Duration {
+	start := silenceStart.FindStringSubmatchIndex(log)
+	if start == nil {
+		return 0
+	}
+	if s, err := strconv.ParseFloat(log[start[2]:start[3]], 64); err != nil || s > 0.05 {
+		return 0
+	}
+
+	end := silenceEnd.FindStringSubmatch(log[start[1]:])
+	if end == nil {
+		return 0
+	}
+	s, err := strconv.ParseFloat(end[1], 64)
+	if err != nil {
+		return 0
+	}
+
+	return time.Duration(s * float64(time.Second))
 }
@@ -0,0 +1,158 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filters configures the ffmpeg audio filter chain applied while
+// transcoding.
+type Filters struct {
+	// Trim silence at the start and/or end.
+	TrimStart bool
+	TrimEnd   bool
+	// TrimThreshold in dB, anything quieter is considered silence.
+	TrimThreshold float64
+	// TrimDuration is the minimum duration of silence to trim.
+	TrimDuration time.Duration
+
+	FadeIn  time.Duration
+	FadeOut time.Duration
+
+	// Mono downmixes to a single channel.
+	Mono bool
+	// SampleRate resamples if non zero.
+	SampleRate int
+}
+
+// Validate reports whether the filters can be used with p.
+func (f *Filters) Validate(p *Preset) error {
+	if f == nil {
+		return nil
+	}
+
+	if f.TrimThreshold > 0 {
+		return errors.New("Silence threshold should be <= 0dB")
+	}
+	if f.TrimDuration < 0 || f.FadeIn < 0 || f.FadeOut < 0 {
+		return errors.New("Filter durations can not be negative")
+	}
+	if f.SampleRate < 0 || (f.SampleRate != 0 && f.SampleRate < 8000) || f.SampleRate > 192000 {
+		return fmt.Errorf("Invalid sample rate %d", f.SampleRate)
+	}
+
+	if p == nil {
+		return nil
+	}
+
+	if p.Name() == "copy" && f.String() != "" {
+		return errors.New("Filters can not be used with the copy preset")
+	}
+
+	if strings.HasPrefix(p.Name(), "opus") && f.SampleRate != 0 {
+		switch f.SampleRate {
+		case 8000, 12000, 16000, 24000, 48000:
+		default:
+			return fmt.Errorf("Opus does not support a sample rate of %d", f.SampleRate)
+		}
+	}
+
+	return nil
+}
+
+func secs(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// Chain returns the ffmpeg filter graph for an input of duration dur,
+// empty if no filters are enabled. The fade out is left out if dur is
+// unknown.
+func (f *Filters) Chain(dur time.Duration) string {
+	return f.graph(dur, false)
+}
+
+// graph is Chain, detect logs the silences of the input (see
+// Filters.silence) before anything is faded or trimmed.
+func (f *Filters) graph(dur time.Duration, detect bool) string {
+	if f == nil || f.FadeOut <= 0 || dur <= f.FadeOut {
+		return f.chain("", detect)
+	}
+
+	return f.chain(fmt.Sprintf(
+		"afade=t=out:st=%s:d=%s",
+		secs(dur-f.FadeOut),
+		secs(f.FadeOut),
+	), detect)
+}
+
+// String describes the filters, unlike Chain it does not depend on the
+// input.
+func (f *Filters) String() string {
+	if f == nil {
+		return ""
+	}
+
+	var chain []string
+	if f.FadeOut > 0 {
+		chain = append(chain, f.chain("afade=t=out:d="+secs(f.FadeOut), false))
+	} else if c := f.chain("", false); c != "" {
+		chain = append(chain, c)
+	}
+	if f.TrimEnd {
+		// cut after transcoding, see FFMPEG.Cut
+		chain = append(chain, fmt.Sprintf(
+			"atrim=end=silence:%sdB:%s",
+			f.threshold(),
+			secs(f.TrimDuration),
+		))
+	}
+
+	return strings.Join(chain, ",")
+}
+
+func (f *Filters) threshold() string {
+	return strconv.FormatFloat(f.TrimThreshold, 'f', -1, 64)
+}
+
+func (f *Filters) chain(fadeOut string, detect bool) string {
+	if f == nil {
+		return ""
+	}
+
+	var chain []string
+	if detect && (f.TrimStart || f.TrimEnd) {
+		chain = append(chain, fmt.Sprintf("silencedetect=n=%sdB:d=0.01", f.threshold()))
+	}
+
+	// Faded on the timestamps of the input, before any silence is
+	// removed.
+	if fadeOut != "" {
+		chain = append(chain, fadeOut)
+	}
+
+	// Trailing silence is only known once the input ended, it is cut
+	// afterwards instead.
+	if f.TrimStart {
+		chain = append(chain, fmt.Sprintf(
+			"silenceremove=start_periods=1:start_duration=%s:start_threshold=%sdB",
+			secs(f.TrimDuration),
+			f.threshold(),
+		))
+	}
+
+	if f.FadeIn > 0 {
+		chain = append(chain, "afade=t=in:d="+secs(f.FadeIn))
+	}
+
+	if f.Mono {
+		chain = append(chain, "aformat=channel_layouts=mono")
+	}
+	if f.SampleRate != 0 {
+		chain = append(chain, "aresample="+strconv.Itoa(f.SampleRate))
+	}
+
+	return strings.Join(chain, ",")
+}
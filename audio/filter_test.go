@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFiltersChain(t *testing.T) {
+	tests := []struct {
+		f      *Filters
+		dur    time.Duration
+		chain  string
+		string string
+	}{
+		{nil, time.Minute, "", ""},
+		{&Filters{}, time.Minute, "", ""},
+		{
+			&Filters{TrimStart: true, TrimThreshold: -90, TrimDuration: time.Second / 2},
+			0,
+			"silenceremove=start_periods=1:start_duration=0.5:start_threshold=-90dB",
+			"silenceremove=start_periods=1:start_duration=0.5:start_threshold=-90dB",
+		},
+		{
+			&Filters{TrimEnd: true, TrimThreshold: -60, Mono: true},
+			0,
+			"aformat=channel_layouts=mono",
+			"aformat=channel_layouts=mono,atrim=end=silence:-60dB:0",
+		},
+		{
+			&Filters{FadeIn: time.Second, FadeOut: 3 * time.Second, SampleRate: 48000},
+			time.Minute,
+			"afade=t=out:st=57:d=3,afade=t=in:d=1,aresample=48000",
+			"afade=t=out:d=3,afade=t=in:d=1,aresample=48000",
+		},
+		{
+			&Filters{FadeOut: 3 * time.Second},
+			0,
+			"",
+			"afade=t=out:d=3",
+		},
+		{
+			&Filters{FadeOut: 3 * time.Second},
+			2 * time.Second,
+			"",
+			"afade=t=out:d=3",
+		},
+	}
+
+	for _, test := range tests {
+		if c := test.f.Chain(test.dur); c != test.chain {
+			t.Errorf("%+v: chain %q, expected %q", test.f, c, test.chain)
+		}
+		if s := test.f.String(); s != test.string {
+			t.Errorf("%+v: string %q, expected %q", test.f, s, test.string)
+		}
+	}
+}
+
+func TestFFMPEGArgs(t *testing.T) {
+	p, _ := ParsePreset("opus:96")
+	e := NewFFMPEGPreset(p, &Filters{FadeOut: time.Second})
+	e.SetArgs([]string{"-threads", "1"})
+	if !e.NeedsDuration() {
+		t.Error("fading out needs the duration")
+	}
+
+	exp := "-nostats -i - -threads 1 -vn -af afade=t=out:st=9:d=1 -c:a libopus -b:a 96k -f ogg -"
+	if args := strings.Join(e.args(10*time.Second, false), " "); args != exp {
+		t.Errorf("args %q, expected %q", args, exp)
+	}
+
+	exp = "-nostats -i - -threads 1 -vn -c:a libopus -b:a 96k -f ogg -"
+	if args := strings.Join(e.GenericExtractor.args, " "); args != exp {
+		t.Errorf("args %q, expected %q", args, exp)
+	}
+}
+
+func TestLeadingSilence(t *testing.T) {
+	tests := []struct {
+		log string
+		d   time.Duration
+	}{
+		{"", 0},
+		{"[silencedetect @ 0x1] silence_start: 0\n[silencedetect @ 0x1] silence_end: 2.5 | silence_duration: 2.5\n", 2500 * time.Millisecond},
+		{"[silencedetect @ 0x1] silence_start: -0.02\n[silencedetect @ 0x1] silence_end: 1.25 | silence_duration: 1.27\n", 1250 * time.Millisecond},
+		{"[silencedetect @ 0x1] silence_start: 30\n[silencedetect @ 0x1] silence_end: 31 | silence_duration: 1\n", 0},
+		{"[silencedetect @ 0x1] silence_start: 0\n", 0},
+	}
+
+	for _, test := range tests {
+		if d := leadingSilence(test.log); d != test.d {
+			t.Errorf("%q: %s, expected %s", test.log, d, test.d)
+		}
+	}
+
+	f := &Filters{TrimStart: true, TrimThreshold: -90}
+	exp := "silencedetect=n=-90dB:d=0.01,silenceremove=start_periods=1:start_duration=0:start_threshold=-90dB"
+	if g := f.graph(0, true); g != exp {
+		t.Errorf("graph %q, expected %q", g, exp)
+	}
+
+	f = &Filters{TrimEnd: true, TrimThreshold: -60, FadeOut: time.Second}
+	exp = "silencedetect=n=-60dB:d=0.01,afade=t=out:st=9:d=1"
+	if g := f.graph(10*time.Second, true); g != exp {
+		t.Errorf("graph %q, expected %q", g, exp)
+	}
+}
+
+func TestTrailingSilence(t *testing.T) {
+	const (
+		start  = "[silencedetect @ 0x1] silence_start: "
+		end    = "[silencedetect @ 0x1] silence_end: "
+		output = "size=    1024kB time=00:03:"
+	)
+
+	tests := []struct {
+		name string
+		log  string
+		f    *Filters
+		s    Silence
+	}{
+		{"none", output + "00.00 bitrate=128kbits/s\n", &Filters{TrimStart: true, TrimEnd: true}, Silence{}},
+		{
+			"trailing",
+			start + "170.5\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimEnd: true},
+			Silence{Trailing: 170500 * time.Millisecond},
+		},
+		{
+			"logged at the end",
+			start + "170.5\n" + end + "180 | silence_duration: 9.5\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimEnd: true},
+			Silence{Trailing: 170500 * time.Millisecond},
+		},
+		{
+			"in between",
+			start + "60\n" + end + "65 | silence_duration: 5\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimEnd: true},
+			Silence{},
+		},
+		{
+			"in between without the duration of the output",
+			start + "60\n" + end + "65 | silence_duration: 5\n",
+			&Filters{TrimEnd: true},
+			Silence{},
+		},
+		{
+			"shorter than TrimDuration",
+			start + "179.5\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimEnd: true, TrimDuration: time.Second},
+			Silence{},
+		},
+		{
+			"leading and trailing",
+			start + "0\n" + end + "2 | silence_duration: 2\n" + start + "170\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimStart: true, TrimEnd: true},
+			Silence{Leading: 2 * time.Second, Trailing: 168 * time.Second},
+		},
+		{
+			"only silence",
+			start + "0\n" + end + "180 | silence_duration: 180\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimEnd: true},
+			Silence{},
+		},
+		{
+			"trailing ignored",
+			start + "170.5\n" + output + "00.00 bitrate=128kbits/s\n",
+			&Filters{TrimStart: true},
+			Silence{},
+		},
+	}
+
+	for _, test := range tests {
+		if s := test.f.silence(test.log); s != test.s {
+			t.Errorf("%s: %+v, expected %+v", test.name, s, test.s)
+		}
+	}
+}
+
+func TestPresetFormat(t *testing.T) {
+	for _, name := range []string{"aac", "opus", "vorbis", "mp3", "flac", "copy"} {
+		p, err := ParsePreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f := p.format(); len(f) != 2 || f[0] != "-f" {
+			t.Errorf("%s: format %v", name, f)
+		}
+	}
+}
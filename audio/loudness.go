@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const minLoudness = -144
+
+// Loudness as measured according to EBU R128.
+type Loudness struct {
+	// Integrated loudness in LUFS.
+	Integrated float64 `json:"integrated"`
+	// TruePeak in dBTP.
+	TruePeak float64 `json:"truePeak"`
+}
+
+type Analyzer interface {
+	Name() string
+	// Analyze measures the loudness of the given file or url.
+	Analyze(input string) (*Loudness, error)
+	Supported() bool
+}
+
+func FindSupportedAnalyzer(analyzers ...Analyzer) (Analyzer, error) {
+	for _, a := range analyzers {
+		if a.Supported() {
+			return a, nil
+		}
+	}
+
+	return nil, errors.New("No supported analyzer found")
+}
+
+type FFMPEGAnalyzer struct{}
+
+func NewFFMPEGAnalyzer() *FFMPEGAnalyzer {
+	return &FFMPEGAnalyzer{}
+}
+
+func (f *FFMPEGAnalyzer) Name() string { return "ffmpeg" }
+
+func (f *FFMPEGAnalyzer) Supported() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+func (f *FFMPEGAnalyzer) Analyze(input string) (*Loudness, error) {
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command(
+		"ffmpeg",
+		"-nostats",
+		"-hide_banner",
+		"-i", input,
+		"-vn",
+		"-af", "ebur128=peak=true",
+		"-f", "null",
+		"-",
+	)
+	cmd.Stderr = buf
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	return parseEBUR128(buf.Bytes())
+}
+
+// parseEBUR128 parses the summary printed by ffmpeg's ebur128 filter.
+func parseEBUR128(out []byte) (*Loudness, error) {
+	var summary bool
+	var haveI, haveTP bool
+	l := &Loudness{}
+	scan := bufio.NewScanner(bytes.NewReader(out))
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if strings.HasSuffix(line, "Summary:") {
+			summary = true
+			continue
+		}
+		if !summary {
+			continue
+		}
+
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(f[1], 64)
+		if err != nil {
+			continue
+		}
+		if math.IsInf(v, -1) {
+			// Silence.
+			v = minLoudness
+		}
+
+		switch f[0] {
+		case "I:":
+			l.Integrated, haveI = v, true
+		case "Peak:":
+			l.TruePeak, haveTP = v, true
+		}
+	}
+
+	if !haveI || !haveTP {
+		return nil, errors.New("Could not parse loudness")
+	}
+
+	return l, nil
+}
@@ -2,8 +2,10 @@ package audio
 
 func NewMEncoder() *GenericExtractor {
 	return &GenericExtractor{
-		ext: "mp3",
-		cmd: "mencoder",
+		ext:    "mp3",
+		preset: "mencoder-mp3",
+		cmd:    "mencoder",
+		in:     1,
 		args: []string{
 			"-",
 			"-really-quiet",
@@ -0,0 +1,691 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+var (
+	errNoAudioTrack = errors.New("No audio track found")
+	errMoovLast     = errors.New("mp4 fragment before sample table")
+)
+
+// mp4 container boxes that are descended into.
+var mp4Containers = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+type mp4Box struct {
+	typ  string
+	data []byte
+	kids []*mp4Box
+}
+
+func (b *mp4Box) find(path ...string) []*mp4Box {
+	if len(path) == 0 {
+		return []*mp4Box{b}
+	}
+
+	var res []*mp4Box
+	for _, k := range b.kids {
+		if k.typ == path[0] {
+			res = append(res, k.find(path[1:]...)...)
+		}
+	}
+
+	return res
+}
+
+func (b *mp4Box) first(path ...string) *mp4Box {
+	if r := b.find(path...); len(r) != 0 {
+		return r[0]
+	}
+	return nil
+}
+
+func parseMP4Boxes(d []byte) ([]*mp4Box, error) {
+	var boxes []*mp4Box
+	for len(d) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(d))
+		typ := string(d[4:8])
+		hdr := uint64(8)
+		if size == 1 {
+			if len(d) < 16 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			size, hdr = binary.BigEndian.Uint64(d[8:]), 16
+		} else if size == 0 {
+			size = uint64(len(d))
+		}
+
+		if size < hdr || size > uint64(len(d)) {
+			return nil, fmt.Errorf("Invalid mp4 box '%s'", typ)
+		}
+
+		b := &mp4Box{typ: typ, data: d[hdr:size]}
+		if mp4Containers[typ] {
+			kids, err := parseMP4Boxes(b.data)
+			if err != nil {
+				return nil, err
+			}
+			b.kids = kids
+		}
+
+		boxes = append(boxes, b)
+		d = d[size:]
+	}
+
+	return boxes, nil
+}
+
+type mp4Sample struct {
+	offset int64
+	size   int64
+}
+
+type mp4Track struct {
+	id          uint32
+	asc         []byte
+	defaultSize uint32
+}
+
+// mp4Spooled is media data that was read before the sample table.
+type mp4Spooled struct {
+	offset int64
+	at     int64
+	size   int64
+}
+
+type mp4Demuxer struct {
+	w       io.Writer
+	tmp     string
+	track   *mp4Track
+	adts    *adtsWriter
+	pending []mp4Sample
+
+	spool   *os.File
+	spooled []mp4Spooled
+}
+
+func demuxMP4(r *reader, w io.Writer, tmp string) error {
+	d := &mp4Demuxer{w: w, tmp: tmp}
+	defer d.closeSpool()
+	for {
+		start := r.pos
+		hdr, err := r.full(8)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(hdr))
+		typ := string(hdr[4:8])
+		hlen := int64(8)
+		if size == 1 {
+			ext, err := r.full(8)
+			if err != nil {
+				return err
+			}
+			size, hlen = int64(binary.BigEndian.Uint64(ext)), 16
+		}
+
+		payload := size - hlen
+		if size == 0 {
+			payload = -1
+		} else if payload < 0 {
+			return fmt.Errorf("Invalid mp4 box '%s'", typ)
+		}
+
+		switch typ {
+		case "moov", "moof":
+			if payload < 0 {
+				return fmt.Errorf("Invalid mp4 box '%s'", typ)
+			}
+			data, err := r.full(payload)
+			if err != nil {
+				return err
+			}
+			kids, err := parseMP4Boxes(data)
+			if err != nil {
+				return err
+			}
+			box := &mp4Box{typ: typ, kids: kids}
+			if typ == "moov" {
+				err = d.moov(box)
+				if err == nil && d.spool != nil {
+					err = d.unspool(r.pos)
+				}
+			} else {
+				err = d.moof(box, start)
+			}
+			if err != nil {
+				return err
+			}
+
+		case "mdat":
+			if d.track == nil {
+				if err := d.spoolMdat(r, payload); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.mdat(r, payload); err != nil {
+				return err
+			}
+
+		default:
+			if payload < 0 {
+				return nil
+			}
+			if err := r.skip(payload); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.track == nil {
+		return errNoAudioTrack
+	}
+
+	return nil
+}
+
+func (d *mp4Demuxer) moov(moov *mp4Box) error {
+	for _, trak := range moov.find("trak") {
+		hdlr := trak.first("mdia", "hdlr")
+		if hdlr == nil || len(hdlr.data) < 12 || string(hdlr.data[8:12]) != "soun" {
+			continue
+		}
+
+		tkhd := trak.first("tkhd")
+		stsd := trak.first("mdia", "minf", "stbl", "stsd")
+		if tkhd == nil || stsd == nil {
+			continue
+		}
+
+		asc, err := mp4AudioConfig(stsd.data)
+		if err != nil {
+			return err
+		}
+		adts, err := newADTSWriter(d.w, asc)
+		if err != nil {
+			return err
+		}
+
+		t := &mp4Track{id: mp4TrackID(tkhd.data), asc: asc}
+		for _, trex := range moov.find("mvex", "trex") {
+			if len(trex.data) >= 20 && binary.BigEndian.Uint32(trex.data[4:]) == t.id {
+				t.defaultSize = binary.BigEndian.Uint32(trex.data[16:])
+			}
+		}
+
+		d.track, d.adts = t, adts
+		samples, err := mp4SampleTable(trak.first("mdia", "minf", "stbl"))
+		if err != nil {
+			return err
+		}
+		d.queue(samples)
+		return nil
+	}
+
+	return errNoAudioTrack
+}
+
+func (d *mp4Demuxer) moof(moof *mp4Box, start int64) error {
+	if d.track == nil {
+		return errMoovLast
+	}
+
+	for _, traf := range moof.find("traf") {
+		tfhd := traf.first("tfhd")
+		if tfhd == nil || len(tfhd.data) < 8 {
+			continue
+		}
+		if binary.BigEndian.Uint32(tfhd.data[4:]) != d.track.id {
+			continue
+		}
+
+		flags := mp4Flags(tfhd.data)
+		base := start
+		defSize := d.track.defaultSize
+		p := tfhd.data[8:]
+		if flags&0x01 != 0 && len(p) >= 8 {
+			base = int64(binary.BigEndian.Uint64(p))
+			p = p[8:]
+		}
+		for _, f := range []uint32{0x02, 0x08} {
+			if flags&f != 0 && len(p) >= 4 {
+				p = p[4:]
+			}
+		}
+		if flags&0x10 != 0 && len(p) >= 4 {
+			defSize = binary.BigEndian.Uint32(p)
+		}
+
+		offset := base
+		for _, trun := range traf.find("trun") {
+			samples, next, err := mp4Run(trun.data, base, offset, defSize)
+			if err != nil {
+				return err
+			}
+			d.queue(samples)
+			offset = next
+		}
+	}
+
+	return nil
+}
+
+func (d *mp4Demuxer) queue(samples []mp4Sample) {
+	d.pending = append(d.pending, samples...)
+	sort.SliceStable(d.pending, func(i, j int) bool {
+		return d.pending[i].offset < d.pending[j].offset
+	})
+}
+
+// mdat writes the queued samples stored in the media data that follows.
+func (d *mp4Demuxer) mdat(r *reader, size int64) error {
+	end := r.pos + size
+	for len(d.pending) != 0 {
+		s := d.pending[0]
+		if s.offset < r.pos {
+			// Not in the stream we're reading, e.g.: already passed.
+			d.pending = d.pending[1:]
+			continue
+		}
+		if size >= 0 && s.offset+s.size > end {
+			break
+		}
+
+		if err := r.skip(s.offset - r.pos); err != nil {
+			return err
+		}
+		data, err := r.full(s.size)
+		if err != nil {
+			return err
+		}
+		if err := d.adts.write(data); err != nil {
+			return err
+		}
+		d.pending = d.pending[1:]
+	}
+
+	if size < 0 {
+		return nil
+	}
+
+	return r.skip(end - r.pos)
+}
+
+// spoolMdat stores media data in a temp file until the sample table
+// that follows it is read.
+func (d *mp4Demuxer) spoolMdat(r *reader, size int64) error {
+	if d.spool == nil {
+		f, err := ioutil.TempFile(d.tmp, "ym-mdat-")
+		if err != nil {
+			return err
+		}
+		d.spool = f
+	}
+
+	at, err := d.spool.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = r
+	if size >= 0 {
+		src = io.LimitReader(r, size)
+	}
+	offset := r.pos
+	n, err := io.Copy(d.spool, src)
+	if err != nil {
+		return err
+	}
+	if size >= 0 && n != size {
+		return io.ErrUnexpectedEOF
+	}
+
+	d.spooled = append(d.spooled, mp4Spooled{offset, at, n})
+	return nil
+}
+
+// unspool writes the queued samples stored before pos from the spool.
+func (d *mp4Demuxer) unspool(pos int64) error {
+	defer d.closeSpool()
+	for len(d.pending) != 0 && d.pending[0].offset < pos {
+		s := d.pending[0]
+		d.pending = d.pending[1:]
+		for _, sp := range d.spooled {
+			if s.offset < sp.offset || s.offset+s.size > sp.offset+sp.size {
+				continue
+			}
+
+			data := make([]byte, s.size)
+			if _, err := d.spool.ReadAt(data, sp.at+s.offset-sp.offset); err != nil {
+				return err
+			}
+			if err := d.adts.write(data); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+func (d *mp4Demuxer) closeSpool() {
+	if d.spool == nil {
+		return
+	}
+
+	d.spool.Close()
+	os.Remove(d.spool.Name())
+	d.spool, d.spooled = nil, nil
+}
+
+func mp4Flags(d []byte) uint32 {
+	return binary.BigEndian.Uint32(d) & 0xffffff
+}
+
+func mp4TrackID(tkhd []byte) uint32 {
+	if len(tkhd) < 4 {
+		return 0
+	}
+	if tkhd[0] == 1 {
+		if len(tkhd) < 24 {
+			return 0
+		}
+		return binary.BigEndian.Uint32(tkhd[20:])
+	}
+	if len(tkhd) < 16 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(tkhd[12:])
+}
+
+// mp4Run parses a trun box, returning its samples and the offset
+// following the last sample.
+func mp4Run(d []byte, base, offset int64, defSize uint32) ([]mp4Sample, int64, error) {
+	if len(d) < 8 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	flags := mp4Flags(d)
+	count := binary.BigEndian.Uint32(d[4:])
+	p := d[8:]
+	if flags&0x01 != 0 {
+		if len(p) < 4 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		offset = base + int64(int32(binary.BigEndian.Uint32(p)))
+		p = p[4:]
+	}
+	if flags&0x04 != 0 {
+		if len(p) < 4 {
+			return nil, 0, io.ErrUnexpectedEOF
+		}
+		p = p[4:]
+	}
+
+	fields := 0
+	for _, f := range []uint32{0x100, 0x200, 0x400, 0x800} {
+		if flags&f != 0 {
+			fields++
+		}
+	}
+
+	if uint64(len(p)) < uint64(count)*uint64(fields)*4 {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	samples := make([]mp4Sample, count)
+	for i := range samples {
+		size := defSize
+		for _, f := range []uint32{0x100, 0x200, 0x400, 0x800} {
+			if flags&f == 0 {
+				continue
+			}
+			if f == 0x200 {
+				size = binary.BigEndian.Uint32(p)
+			}
+			p = p[4:]
+		}
+
+		samples[i] = mp4Sample{offset, int64(size)}
+		offset += int64(size)
+	}
+
+	return samples, offset, nil
+}
+
+// mp4SampleTable returns the samples of a non fragmented track.
+func mp4SampleTable(stbl *mp4Box) ([]mp4Sample, error) {
+	stsz, stsc := stbl.first("stsz"), stbl.first("stsc")
+	if stsz == nil || stsc == nil || len(stsz.data) < 12 || len(stsc.data) < 8 {
+		// Fragmented.
+		return nil, nil
+	}
+
+	var chunks []int64
+	if co := stbl.first("stco"); co != nil && len(co.data) >= 8 {
+		n := binary.BigEndian.Uint32(co.data[4:])
+		if uint64(len(co.data)-8) < uint64(n)*4 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := uint32(0); i < n; i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint32(co.data[8+i*4:])))
+		}
+	} else if co := stbl.first("co64"); co != nil && len(co.data) >= 8 {
+		n := binary.BigEndian.Uint32(co.data[4:])
+		if uint64(len(co.data)-8) < uint64(n)*8 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		for i := uint32(0); i < n; i++ {
+			chunks = append(chunks, int64(binary.BigEndian.Uint64(co.data[8+i*8:])))
+		}
+	}
+
+	fixed := binary.BigEndian.Uint32(stsz.data[4:])
+	count := binary.BigEndian.Uint32(stsz.data[8:])
+	if fixed == 0 && uint64(len(stsz.data)-12) < uint64(count)*4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := func(i uint32) int64 {
+		if fixed != 0 {
+			return int64(fixed)
+		}
+		return int64(binary.BigEndian.Uint32(stsz.data[12+i*4:]))
+	}
+
+	entries := binary.BigEndian.Uint32(stsc.data[4:])
+	if uint64(len(stsc.data)-8) < uint64(entries)*12 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	samples := make([]mp4Sample, 0, count)
+	var sample uint32
+	for e := uint32(0); e < entries; e++ {
+		p := stsc.data[8+e*12:]
+		first := binary.BigEndian.Uint32(p) - 1
+		perChunk := binary.BigEndian.Uint32(p[4:])
+		last := uint32(len(chunks))
+		if e+1 < entries {
+			last = binary.BigEndian.Uint32(stsc.data[8+(e+1)*12:]) - 1
+		}
+
+		for c := first; c < last && c < uint32(len(chunks)); c++ {
+			offset := chunks[c]
+			for i := uint32(0); i < perChunk && sample < count; i++ {
+				s := size(sample)
+				samples = append(samples, mp4Sample{offset, s})
+				offset += s
+				sample++
+			}
+		}
+	}
+
+	return samples, nil
+}
+
+// mp4AudioConfig extracts the AudioSpecificConfig from an stsd box.
+func mp4AudioConfig(stsd []byte) ([]byte, error) {
+	// fullbox header, entry count, entry size, entry type.
+	if len(stsd) < 16 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	typ := string(stsd[12:16])
+	if typ != "mp4a" {
+		return nil, fmt.Errorf("Unsupported mp4 audio codec '%s'", typ)
+	}
+
+	// Skip the mp4a sample entry fields.
+	const entry = 16 + 28
+	if len(stsd) < entry {
+		return nil, io.ErrUnexpectedEOF
+	}
+	end := 8 + int(binary.BigEndian.Uint32(stsd[8:]))
+	if end > len(stsd) {
+		end = len(stsd)
+	}
+
+	boxes, err := parseMP4Boxes(stsd[entry:end])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range boxes {
+		if b.typ != "esds" || len(b.data) < 4 {
+			continue
+		}
+		if asc := mp4Descriptor(b.data[4:], 5); asc != nil {
+			return asc, nil
+		}
+	}
+
+	return nil, errors.New("No AudioSpecificConfig found")
+}
+
+// mp4Descriptor finds the payload of the first descriptor with the given
+// tag, descending into ES and DecoderConfig descriptors.
+func mp4Descriptor(d []byte, tag byte) []byte {
+	for len(d) > 1 {
+		t := d[0]
+		d = d[1:]
+		var l int
+		for i := 0; i < 4 && len(d) != 0; i++ {
+			b := d[0]
+			d = d[1:]
+			l = l<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		if l > len(d) {
+			return nil
+		}
+
+		payload := d[:l]
+		d = d[l:]
+		if t == tag {
+			return payload
+		}
+
+		switch t {
+		case 3:
+			// ES_ID and flags.
+			if len(payload) < 3 {
+				return nil
+			}
+			flags := payload[2]
+			payload = payload[3:]
+			if flags&0x80 != 0 && len(payload) >= 2 {
+				payload = payload[2:]
+			}
+			if flags&0x40 != 0 && len(payload) >= 1+int(payload[0]) {
+				payload = payload[1+int(payload[0]):]
+			}
+			if flags&0x20 != 0 && len(payload) >= 2 {
+				payload = payload[2:]
+			}
+		case 4:
+			// Object type, stream type, buffer size and bitrates.
+			if len(payload) < 13 {
+				return nil
+			}
+			payload = payload[13:]
+		default:
+			continue
+		}
+
+		if r := mp4Descriptor(payload, tag); r != nil {
+			return r
+		}
+	}
+
+	return nil
+}
+
+type adtsWriter struct {
+	w       io.Writer
+	profile byte
+	freq    byte
+	chans   byte
+}
+
+func newADTSWriter(w io.Writer, asc []byte) (*adtsWriter, error) {
+	if len(asc) < 2 {
+		return nil, errors.New("Invalid AudioSpecificConfig")
+	}
+
+	obj := asc[0] >> 3
+	freq := (asc[0]&0x07)<<1 | asc[1]>>7
+	chans := (asc[1] >> 3) & 0x0f
+	if obj == 31 || freq == 15 {
+		return nil, errors.New("AudioSpecificConfig can not be represented in adts")
+	}
+	if obj == 0 || obj > 4 {
+		// e.g.: HE-AAC, the core is decodable as LC.
+		obj = 2
+	}
+
+	return &adtsWriter{w, obj - 1, freq, chans}, nil
+}
+
+func (a *adtsWriter) write(frame []byte) error {
+	l := len(frame) + 7
+	if l > 0x1fff {
+		return errors.New("aac frame too large for adts")
+	}
+
+	hdr := []byte{
+		0xff,
+		0xf1,
+		a.profile<<6 | a.freq<<2 | a.chans>>2,
+		(a.chans&3)<<6 | byte(l>>11),
+		byte(l >> 3),
+		byte(l&7)<<5 | 0x1f,
+		0xfc,
+	}
+	if _, err := a.w.Write(hdr); err != nil {
+		return err
+	}
+
+	_, err := a.w.Write(frame)
+	return err
+}
@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestADTSWriter(t *testing.T) {
+	tests := []struct {
+		asc []byte
+		hdr []byte
+		err bool
+	}{
+		// LC 44100Hz stereo
+		{[]byte{0x12, 0x10}, []byte{0xff, 0xf1, 0x50, 0x80, 0x01, 0x3f, 0xfc}, false},
+		// HE-AAC 22050Hz stereo, written as LC
+		{[]byte{0x2b, 0x92}, []byte{0xff, 0xf1, 0x5c, 0x80, 0x01, 0x3f, 0xfc}, false},
+		// explicit frequency
+		{[]byte{0x17, 0x80}, nil, true},
+		{[]byte{0x12}, nil, true},
+	}
+
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		a, err := newADTSWriter(buf, test.asc)
+		if test.err {
+			if err == nil {
+				t.Errorf("%x: expected an error", test.asc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := a.write(make([]byte, 2)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes()[:7], test.hdr) {
+			t.Errorf("%x: header %x, expected %x", test.asc, buf.Bytes()[:7], test.hdr)
+		}
+	}
+
+	a, _ := newADTSWriter(ioutil.Discard, []byte{0x12, 0x10})
+	if err := a.write(make([]byte, 0x2000)); err == nil {
+		t.Error("expected an error for a frame that doesn't fit")
+	}
+}
+
+func TestMP4Run(t *testing.T) {
+	// data offset and 2 sample sizes
+	trun := []byte{
+		0, 0, 0x02, 0x01,
+		0, 0, 0, 2,
+		0, 0, 0, 100,
+		0, 0, 0, 10,
+		0, 0, 0, 20,
+	}
+
+	samples, next, err := mp4Run(trun, 1000, 0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []mp4Sample{{1100, 10}, {1110, 20}}
+	if len(samples) != len(exp) || samples[0] != exp[0] || samples[1] != exp[1] {
+		t.Errorf("samples %v, expected %v", samples, exp)
+	}
+	if next != 1130 {
+		t.Errorf("next offset %d, expected 1130", next)
+	}
+
+	// no sizes, default size and offset continue from the previous run
+	samples, next, err = mp4Run([]byte{0, 0, 0, 0, 0, 0, 0, 3}, 1000, 1130, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 || samples[0] != (mp4Sample{1130, 5}) || next != 1145 {
+		t.Errorf("samples %v next %d", samples, next)
+	}
+
+	if _, _, err := mp4Run(trun[:16], 0, 0, 0); err == nil {
+		t.Error("expected an error for a truncated trun")
+	}
+}
@@ -0,0 +1,102 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+var errUnknownContainer = errors.New("Unknown container, expected mp4 or webm")
+
+// Native demuxes the audio track of mp4 and webm streams without
+// re-encoding, mp4 results in adts aac and webm in ogg opus.
+type Native struct {
+	tmp string
+}
+
+func NewNative() *Native {
+	return &Native{}
+}
+
+// SetTempDir sets the directory media data is spooled to when an mp4
+// stores it before its sample table, os.TempDir() by default.
+func (n *Native) SetTempDir(dir string) {
+	n.tmp = dir
+}
+
+func (n *Native) Name() string    { return "native" }
+func (n *Native) Supported() bool { return true }
+func (n *Native) Ext() string     { return "aac" }
+func (n *Native) Preset() string  { return "native" }
+func (n *Native) Filters() string { return "" }
+
+func (n *Native) Extract(v io.Reader, a io.Writer) error {
+	return n.Transcode(v, a)
+}
+
+func (n *Native) Transcode(v io.Reader, a io.Writer) error {
+	_, err := n.TranscodeExt(v, a)
+	return err
+}
+
+// TranscodeExt is Transcode but also returns the file extension matching
+// the container of the output.
+func (n *Native) TranscodeExt(v io.Reader, a io.Writer) (string, error) {
+	r := &reader{r: bufio.NewReaderSize(v, 64*1024)}
+	head, err := r.r.Peek(8)
+	if err != nil {
+		return "", err
+	}
+
+	w := bufio.NewWriter(a)
+	switch {
+	case bytes.Equal(head[4:8], []byte("ftyp")):
+		err = demuxMP4(r, w, n.tmp)
+		if err == nil {
+			err = w.Flush()
+		}
+		return "aac", err
+
+	case bytes.Equal(head[:4], []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		err = demuxWebM(r, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		return "opus", err
+	}
+
+	return "", errUnknownContainer
+}
+
+// reader keeps track of the amount of bytes read.
+type reader struct {
+	r   *bufio.Reader
+	pos int64
+}
+
+func (r *reader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.pos++
+	}
+	return b, err
+}
+
+func (r *reader) full(n int64) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func (r *reader) skip(n int64) error {
+	d, err := r.r.Discard(int(n))
+	r.pos += int64(d)
+	return err
+}
@@ -0,0 +1,77 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNative(t *testing.T) {
+	tests := []struct {
+		in, out, ext string
+	}{
+		{"aac.mp4", "aac.aac", "aac"},
+		{"aac-moov-last.mp4", "aac.aac", "aac"},
+		{"aac-fragmented.mp4", "aac.aac", "aac"},
+		{"opus.webm", "opus.ogg", "opus"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", test.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			exp, err := ioutil.ReadFile(filepath.Join("testdata", test.out))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf := bytes.NewBuffer(nil)
+			ext, err := NewNative().TranscodeExt(f, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ext != test.ext {
+				t.Errorf("ext %s, expected %s", ext, test.ext)
+			}
+			if !bytes.Equal(buf.Bytes(), exp) {
+				t.Errorf("output does not match %s", test.out)
+			}
+		})
+	}
+}
+
+func TestNativeInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"unknown":  []byte("RIFF\x00\x00\x00\x00WAVEfmt "),
+		"short":    []byte("ftyp"),
+		"no audio": []byte("\x00\x00\x00\x10ftypisom\x00\x00\x02\x00"),
+	}
+
+	for _, f := range []string{"aac.mp4", "aac-moov-last.mp4", "opus.webm"} {
+		d, err := ioutil.ReadFile(filepath.Join("testdata", f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests["truncated "+f] = d[:len(d)-3]
+	}
+
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := NewNative().Transcode(bytes.NewReader(in), ioutil.Discard)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func bufioReader(b []byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(b))
+}
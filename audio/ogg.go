@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+var oggCRC [256]uint32
+
+func init() {
+	for i := range oggCRC {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		oggCRC[i] = r
+	}
+}
+
+// oggWriter writes packets to a single logical ogg bitstream.
+type oggWriter struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+
+	segments []byte
+	data     []byte
+	granule  int64
+	bos      bool
+}
+
+func newOggWriter(w io.Writer, serial uint32) *oggWriter {
+	return &oggWriter{w: w, serial: serial, bos: true}
+}
+
+// packet adds a packet that ends at the given granule position,
+// flush forces it to be the last packet of its page.
+func (o *oggWriter) packet(p []byte, granule int64, flush bool) error {
+	if len(o.segments)+len(p)/255+1 > 255 {
+		if err := o.flush(false); err != nil {
+			return err
+		}
+	}
+
+	for n := len(p); ; n -= 255 {
+		if n < 255 {
+			o.segments = append(o.segments, byte(n))
+			break
+		}
+		o.segments = append(o.segments, 255)
+	}
+	o.data = append(o.data, p...)
+	o.granule = granule
+
+	if flush {
+		return o.flush(false)
+	}
+
+	return nil
+}
+
+func (o *oggWriter) close() error {
+	return o.flush(true)
+}
+
+func (o *oggWriter) flush(eos bool) error {
+	if len(o.segments) == 0 && !eos {
+		return nil
+	}
+
+	var typ byte
+	if o.bos {
+		typ |= 0x02
+	}
+	if eos {
+		typ |= 0x04
+	}
+
+	page := make([]byte, 27, 27+len(o.segments)+len(o.data))
+	copy(page, "OggS")
+	page[5] = typ
+	binary.LittleEndian.PutUint64(page[6:], uint64(o.granule))
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.seq)
+	page[26] = byte(len(o.segments))
+	page = append(page, o.segments...)
+	page = append(page, o.data...)
+
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	binary.LittleEndian.PutUint32(page[22:], crc)
+
+	o.bos = false
+	o.seq++
+	o.segments, o.data = o.segments[:0], o.data[:0]
+	_, err := o.w.Write(page)
+	return err
+}
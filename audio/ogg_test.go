@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for i, b := range page {
+		if i >= 22 && i < 26 {
+			b = 0
+		}
+		crc ^= uint32(b) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+func TestOggWriter(t *testing.T) {
+	var packets [][]byte
+	for i := 0; i < 300; i++ {
+		size := i * 7 % 600
+		if i%100 == 1 {
+			// lacing values of exactly 255 need a terminating 0
+			size = 255 * (i/100 + 1)
+		}
+		p := make([]byte, size)
+		for j := range p {
+			p[j] = byte(i + j)
+		}
+		packets = append(packets, p)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	o := newOggWriter(buf, 42)
+	for i, p := range packets {
+		if err := o.packet(p, int64(i), i == 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]byte
+	var partial []byte
+	d := buf.Bytes()
+	for seq := uint32(0); len(d) != 0; seq++ {
+		if len(d) < 27 || string(d[:4]) != "OggS" {
+			t.Fatalf("page %d: invalid header", seq)
+		}
+		segs := int(d[26])
+		size := 27 + segs
+		for _, s := range d[27 : 27+segs] {
+			size += int(s)
+		}
+		page := d[:size]
+		d = d[size:]
+
+		if crc := binary.LittleEndian.Uint32(page[22:]); crc != oggChecksum(page) {
+			t.Errorf("page %d: invalid checksum", seq)
+		}
+		if s := binary.LittleEndian.Uint32(page[14:]); s != 42 {
+			t.Errorf("page %d: serial %d", seq, s)
+		}
+		if s := binary.LittleEndian.Uint32(page[18:]); s != seq {
+			t.Errorf("page %d: sequence %d", seq, s)
+		}
+		if bos := page[5]&0x02 != 0; bos != (seq == 0) {
+			t.Errorf("page %d: bos %t", seq, bos)
+		}
+		if eos := page[5]&0x04 != 0; eos != (len(d) == 0) {
+			t.Errorf("page %d: eos %t", seq, eos)
+		}
+
+		data := page[27+segs:]
+		for _, s := range page[27 : 27+segs] {
+			partial = append(partial, data[:s]...)
+			data = data[s:]
+			if s < 255 {
+				got = append(got, partial)
+				partial = nil
+			}
+		}
+		if partial != nil {
+			t.Fatalf("page %d: packet continues on next page", seq)
+		}
+
+		if seq == 0 && len(got) != 1 {
+			t.Errorf("first page has %d packets, expected it to be flushed", len(got))
+		}
+		if g := int64(binary.LittleEndian.Uint64(page[6:])); g != int64(len(got)-1) {
+			t.Errorf("page %d: granule %d, expected %d", seq, g, len(got)-1)
+		}
+	}
+
+	if len(got) != len(packets) {
+		t.Fatalf("got %d packets, expected %d", len(got), len(packets))
+	}
+	for i := range packets {
+		if !bytes.Equal(got[i], packets[i]) {
+			t.Errorf("packet %d differs", i)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Preset is a named ffmpeg encoding configuration.
+type Preset struct {
+	name string
+	ext  string
+	args []string
+}
+
+func (p *Preset) Name() string { return p.name }
+func (p *Preset) Ext() string  { return p.ext }
+
+// format returns the ffmpeg arguments that select the container.
+func (p *Preset) format() []string {
+	for i := 0; i < len(p.args)-1; i++ {
+		if p.args[i] == "-f" {
+			return p.args[i : i+2]
+		}
+	}
+
+	return nil
+}
+
+// Presets lists the available preset names, the optional parameter after
+// the colon is the bitrate in kbps for opus and the quality for vorbis
+// and mp3.
+var Presets = []string{"aac", "opus[:kbps]", "vorbis[:quality]", "mp3[:quality]", "flac", "copy"}
+
+// ParsePreset parses a preset name like opus:96 or flac.
+func ParsePreset(s string) (*Preset, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	var arg string
+	if i := strings.Index(name, ":"); i != -1 {
+		name, arg = name[:i], name[i+1:]
+	}
+
+	num := func(def int) (int, error) {
+		if arg == "" {
+			return def, nil
+		}
+		return strconv.Atoi(arg)
+	}
+
+	switch name {
+	case "", "aac":
+		return &Preset{
+			"aac",
+			"aac",
+			[]string{"-f", "adts"},
+		}, nil
+
+	case "opus":
+		kbps, err := num(128)
+		if err != nil || kbps <= 0 {
+			break
+		}
+		return &Preset{
+			fmt.Sprintf("opus:%d", kbps),
+			"opus",
+			[]string{"-c:a", "libopus", "-b:a", fmt.Sprintf("%dk", kbps), "-f", "ogg"},
+		}, nil
+
+	case "vorbis":
+		q, err := num(5)
+		if err != nil || q < 0 || q > 10 {
+			break
+		}
+		return &Preset{
+			fmt.Sprintf("vorbis:%d", q),
+			"ogg",
+			[]string{"-c:a", "libvorbis", "-q:a", strconv.Itoa(q), "-f", "ogg"},
+		}, nil
+
+	case "mp3":
+		q, err := num(2)
+		if err != nil || q < 0 || q > 9 {
+			break
+		}
+		return &Preset{
+			fmt.Sprintf("mp3:%d", q),
+			"mp3",
+			[]string{"-c:a", "libmp3lame", "-q:a", strconv.Itoa(q), "-f", "mp3"},
+		}, nil
+
+	case "flac":
+		return &Preset{"flac", "flac", []string{"-c:a", "flac", "-f", "flac"}}, nil
+
+	case "copy":
+		// Remux the source audio track, matroska accepts any codec.
+		return &Preset{"copy", "mka", []string{"-c:a", "copy", "-f", "matroska"}}, nil
+	}
+
+	return nil, fmt.Errorf(
+		"Invalid transcoder preset '%s', available: %s",
+		s,
+		strings.Join(Presets, ", "),
+	)
+}
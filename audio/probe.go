@@ -0,0 +1,189 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Media describes the audio stream of a file.
+type Media struct {
+	Codec      string `json:"codec"`
+	Bitrate    int    `json:"bitrate"`
+	Channels   int    `json:"channels"`
+	SampleRate int    `json:"sampleRate"`
+	// Seconds is the duration in seconds.
+	Seconds float64 `json:"duration"`
+	// Exact is set if the container records the duration or, for local
+	// files, it was measured by reading all packets. Otherwise Seconds
+	// is estimated from the bitrate (e.g.: adts urls).
+	Exact bool `json:"exact"`
+}
+
+// indexed are the containers that record their duration.
+var indexed = map[string]bool{
+	"mp4":      true,
+	"m4a":      true,
+	"matroska": true,
+	"webm":     true,
+	"ogg":      true,
+	"flac":     true,
+}
+
+func (m *Media) Duration() time.Duration {
+	return time.Duration(m.Seconds * float64(time.Second))
+}
+
+type Inspector interface {
+	Name() string
+	// Inspect returns the properties of the first audio stream of file.
+	Inspect(file string) (*Media, error)
+	Supported() bool
+}
+
+func FindSupportedInspector(inspectors ...Inspector) (Inspector, error) {
+	for _, i := range inspectors {
+		if i.Supported() {
+			return i, nil
+		}
+	}
+
+	return nil, errors.New("No supported inspector found")
+}
+
+type FFProbe struct{}
+
+func NewFFProbe() *FFProbe {
+	return &FFProbe{}
+}
+
+func (f *FFProbe) Name() string { return "ffprobe" }
+
+func (f *FFProbe) Supported() bool {
+	_, err := exec.LookPath("ffprobe")
+	return err == nil
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		Codec      string `json:"codec_name"`
+		Bitrate    string `json:"bit_rate"`
+		Channels   int    `json:"channels"`
+		SampleRate string `json:"sample_rate"`
+		Duration   string `json:"duration"`
+	} `json:"streams"`
+	Format struct {
+		Name     string `json:"format_name"`
+		Bitrate  string `json:"bit_rate"`
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+func (f *FFProbe) Inspect(file string) (*Media, error) {
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command(
+		"ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		"-select_streams", "a:0",
+		file,
+	)
+	cmd.Stdout = buf
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+
+	m, err := parseFFProbe(buf.Bytes())
+	if err != nil || m.Exact || strings.Contains(file, "://") {
+		return m, err
+	}
+
+	if d, err := f.packetDuration(file); err == nil && d > 0 {
+		m.Seconds, m.Exact = d, true
+	}
+
+	return m, nil
+}
+
+// packetDuration reads all packets of the first audio stream and returns
+// the end of the last one in seconds.
+func (f *FFProbe) packetDuration(file string) (float64, error) {
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command(
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "packet=pts_time,duration_time",
+		"-of", "csv=p=0",
+		file,
+	)
+	cmd.Stdout = buf
+	if err := cmd.Run(); err != nil {
+		return 0, err
+	}
+
+	return parsePackets(buf.String()), nil
+}
+
+// parsePackets returns the end of the last packet in ffprobe's
+// pts_time,duration_time csv output.
+func parsePackets(csv string) float64 {
+	var end float64
+	for _, l := range strings.Split(csv, "\n") {
+		f := strings.Split(strings.TrimSpace(l), ",")
+		if len(f) != 2 {
+			continue
+		}
+		pts, err := strconv.ParseFloat(f[0], 64)
+		if err != nil {
+			continue
+		}
+		d, _ := strconv.ParseFloat(f[1], 64)
+		if pts+d > end {
+			end = pts + d
+		}
+	}
+
+	return end
+}
+
+func parseFFProbe(d []byte) (*Media, error) {
+	o := &ffprobeOutput{}
+	if err := json.Unmarshal(d, o); err != nil {
+		return nil, err
+	}
+	if len(o.Streams) == 0 {
+		return nil, errNoAudioTrack
+	}
+
+	s := o.Streams[0]
+	m := &Media{Codec: s.Codec, Channels: s.Channels}
+	m.SampleRate, _ = strconv.Atoi(s.SampleRate)
+
+	// Containers like ogg and adts only report these for the whole file.
+	for _, v := range []string{s.Bitrate, o.Format.Bitrate} {
+		if b, err := strconv.Atoi(v); err == nil {
+			m.Bitrate = b
+			break
+		}
+	}
+	for _, v := range []string{s.Duration, o.Format.Duration} {
+		if d, err := strconv.ParseFloat(v, 64); err == nil {
+			m.Seconds = d
+			break
+		}
+	}
+	for _, f := range strings.Split(o.Format.Name, ",") {
+		if indexed[f] {
+			m.Exact = m.Seconds > 0
+		}
+	}
+
+	return m, nil
+}
@@ -0,0 +1,60 @@
+package audio
+
+import "testing"
+
+func TestParseFFProbe(t *testing.T) {
+	tests := []struct {
+		json  string
+		media Media
+	}{
+		{
+			`{"streams":[{"codec_name":"aac","channels":2,"sample_rate":"44100","bit_rate":"128000","duration":"61.5"}],
+			"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","bit_rate":"130000","duration":"61.6"}}`,
+			Media{"aac", 128000, 2, 44100, 61.5, true},
+		},
+		{
+			`{"streams":[{"codec_name":"aac","channels":2,"sample_rate":"44100"}],
+			"format":{"format_name":"aac","bit_rate":"129000","duration":"60.9"}}`,
+			Media{"aac", 129000, 2, 44100, 60.9, false},
+		},
+		{
+			`{"streams":[{"codec_name":"opus","channels":2,"sample_rate":"48000"}],
+			"format":{"format_name":"ogg","duration":"12"}}`,
+			Media{"opus", 0, 2, 48000, 12, true},
+		},
+		{
+			`{"streams":[{"codec_name":"opus","channels":1,"sample_rate":"48000"}],
+			"format":{"format_name":"matroska,webm"}}`,
+			Media{"opus", 0, 1, 48000, 0, false},
+		},
+	}
+
+	for _, test := range tests {
+		m, err := parseFFProbe([]byte(test.json))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *m != test.media {
+			t.Errorf("%+v, expected %+v", *m, test.media)
+		}
+	}
+
+	if _, err := parseFFProbe([]byte(`{"streams":[]}`)); err == nil {
+		t.Error("expected an error without audio streams")
+	}
+}
+
+func TestParsePackets(t *testing.T) {
+	tests := map[string]float64{
+		"":                                       0,
+		"0.000000,0.023220\n0.023220,0.023220\n": 0.04644,
+		"0.000000,0.023220\n0.046440,N/A\n":      0.04644,
+		"N/A,N/A\n1.500000,0.500000\r\n":         2,
+	}
+
+	for csv, end := range tests {
+		if e := parsePackets(csv); e < end-1e-9 || e > end+1e-9 {
+			t.Errorf("%q: %f, expected %f", csv, e, end)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+type Splitter interface {
+	Name() string
+	// Cut copies the part of file between start and end to dest without
+	// re-encoding, an end of 0 means until the end of file.
+	Cut(file, dest string, start, end time.Duration) error
+	Supported() bool
+}
+
+func FindSupportedSplitter(splitters ...Splitter) (Splitter, error) {
+	for _, s := range splitters {
+		if s.Supported() {
+			return s, nil
+		}
+	}
+
+	return nil, errors.New("No supported splitter found")
+}
+
+type FFMPEGSplitter struct{}
+
+func NewFFMPEGSplitter() *FFMPEGSplitter {
+	return &FFMPEGSplitter{}
+}
+
+func (f *FFMPEGSplitter) Name() string { return "ffmpeg" }
+
+func (f *FFMPEGSplitter) Supported() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+func (f *FFMPEGSplitter) Cut(file, dest string, start, end time.Duration) error {
+	secs := func(d time.Duration) string {
+		return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+	}
+
+	args := []string{"-nostats", "-hide_banner", "-n", "-i", file, "-ss", secs(start)}
+	if end > 0 {
+		args = append(args, "-to", secs(end))
+	}
+	args = append(args, "-map", "0:a", "-c", "copy", dest)
+
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = buf
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err, lastLine(buf.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Tags are written to an audio file by a Tagger.
+type Tags struct {
+	Title  string
+	Artist string
+	Date   string
+	URL    string
+	// Cover is the path or url of the cover art image.
+	Cover string
+}
+
+type Tagger interface {
+	Name() string
+	// Tag writes a copy of file to out with t replacing the existing tags.
+	Tag(file, out string, t *Tags) error
+	Supported() bool
+}
+
+func FindSupportedTagger(taggers ...Tagger) (Tagger, error) {
+	for _, t := range taggers {
+		if t.Supported() {
+			return t, nil
+		}
+	}
+
+	return nil, errors.New("No supported tagger found")
+}
+
+type FFMPEGTagger struct{}
+
+func NewFFMPEGTagger() *FFMPEGTagger {
+	return &FFMPEGTagger{}
+}
+
+func (f *FFMPEGTagger) Name() string { return "ffmpeg" }
+
+func (f *FFMPEGTagger) Supported() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+// Tag remuxes file with the new tags, raw aac gets an id3v2 header
+// without cover art.
+func (f *FFMPEGTagger) Tag(file, out string, t *Tags) error {
+	ext := strings.ToLower(filepath.Ext(file))
+	var cover bool
+	switch ext {
+	case ".mp3", ".flac", ".m4a", ".mp4":
+		cover = t.Cover != ""
+	}
+
+	args := []string{"-y", "-nostats", "-hide_banner", "-i", file}
+	if cover {
+		args = append(args, "-i", t.Cover, "-map", "0:a", "-map", "1:v")
+		args = append(args, "-disposition:v", "attached_pic")
+		if ext == ".mp3" {
+			args = append(args, "-id3v2_version", "3")
+		}
+	} else {
+		args = append(args, "-map", "0:a")
+	}
+
+	args = append(args, "-c", "copy", "-map_metadata", "-1")
+	meta := [][2]string{
+		{"title", t.Title},
+		{"artist", t.Artist},
+		{"date", t.Date},
+		{"comment", t.URL},
+	}
+	for _, m := range meta {
+		if m[1] != "" {
+			args = append(args, "-metadata", fmt.Sprintf("%s=%s", m[0], m[1]))
+		}
+	}
+
+	if ext == ".aac" {
+		args = append(args, "-f", "adts", "-write_id3v2", "1")
+	}
+	args = append(args, out)
+
+	buf := bytes.NewBuffer(nil)
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stderr = buf
+	if err := cmd.Run(); err != nil {
+		os.Remove(out)
+		return fmt.Errorf("%s: %s", err, lastLine(buf.String()))
+	}
+
+	return nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "\n"); i != -1 {
+		return s[i+1:]
+	}
+
+	return s
+}
@@ -0,0 +1,373 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Matroska element ids.
+const (
+	ebmlSegment      = 0x18538067
+	ebmlTracks       = 0x1654ae6b
+	ebmlTrackEntry   = 0xae
+	ebmlTrackNumber  = 0xd7
+	ebmlTrackType    = 0x83
+	ebmlCodecID      = 0x86
+	ebmlCodecPrivate = 0x63a2
+	ebmlCluster      = 0x1f43b675
+	ebmlBlockGroup   = 0xa0
+	ebmlBlock        = 0xa1
+	ebmlSimpleBlock  = 0xa3
+
+	ebmlUnknownSize = -1
+)
+
+var errLacing = errors.New("Invalid block lacing")
+
+type webmTrack struct {
+	number  uint64
+	typ     uint64
+	codec   string
+	private []byte
+}
+
+type webmDemuxer struct {
+	w      io.Writer
+	tracks []*webmTrack
+	audio  *webmTrack
+	ogg    *oggWriter
+	pos    int64
+}
+
+func demuxWebM(r *reader, w io.Writer) error {
+	d := &webmDemuxer{w: w}
+	var track *webmTrack
+	for {
+		id, err := ebmlID(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		size, err := ebmlSize(r)
+		if err != nil {
+			return err
+		}
+
+		switch id {
+		case ebmlSegment, ebmlTracks, ebmlCluster, ebmlBlockGroup:
+			// Descend.
+			continue
+		case ebmlTrackEntry:
+			track = &webmTrack{}
+			d.tracks = append(d.tracks, track)
+			continue
+		}
+
+		if size == ebmlUnknownSize {
+			return fmt.Errorf("Unknown size for webm element 0x%x", id)
+		}
+
+		switch id {
+		case ebmlTrackNumber, ebmlTrackType, ebmlCodecID, ebmlCodecPrivate:
+			data, err := r.full(size)
+			if err != nil {
+				return err
+			}
+			if track == nil {
+				continue
+			}
+			switch id {
+			case ebmlTrackNumber:
+				track.number = ebmlUint(data)
+			case ebmlTrackType:
+				track.typ = ebmlUint(data)
+			case ebmlCodecID:
+				track.codec = string(data)
+			case ebmlCodecPrivate:
+				track.private = data
+			}
+
+		case ebmlBlock, ebmlSimpleBlock:
+			data, err := r.full(size)
+			if err != nil {
+				return err
+			}
+			if err := d.block(data); err != nil {
+				return err
+			}
+
+		default:
+			if err := r.skip(size); err != nil {
+				return err
+			}
+		}
+	}
+
+	if d.ogg == nil {
+		return errNoAudioTrack
+	}
+
+	return d.ogg.close()
+}
+
+func (d *webmDemuxer) init() error {
+	for _, t := range d.tracks {
+		if t.typ != 2 {
+			continue
+		}
+		if t.codec != "A_OPUS" {
+			return fmt.Errorf("Unsupported webm audio codec '%s'", t.codec)
+		}
+		if len(t.private) < 19 || string(t.private[:8]) != "OpusHead" {
+			return errors.New("Invalid OpusHead")
+		}
+
+		d.audio = t
+		d.ogg = newOggWriter(d.w, uint32(t.number))
+		if err := d.ogg.packet(t.private, 0, true); err != nil {
+			return err
+		}
+
+		tags := make([]byte, 8+4+2+4)
+		copy(tags, "OpusTags")
+		binary.LittleEndian.PutUint32(tags[8:], 2)
+		copy(tags[12:], "ym")
+		return d.ogg.packet(tags, 0, true)
+	}
+
+	return errNoAudioTrack
+}
+
+func (d *webmDemuxer) block(b []byte) error {
+	track, n := ebmlVint(b)
+	if n == 0 || len(b) < n+3 {
+		return errors.New("Invalid webm block")
+	}
+
+	if d.audio == nil {
+		if err := d.init(); err != nil {
+			return err
+		}
+	}
+	if track != d.audio.number {
+		return nil
+	}
+
+	flags := b[n+2]
+	frames, err := unlace(b[n+3:], (flags>>1)&3)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range frames {
+		d.pos += opusSamples(f)
+		if err := d.ogg.packet(f, d.pos, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unlace(b []byte, lacing byte) ([][]byte, error) {
+	if lacing == 0 {
+		return [][]byte{b}, nil
+	}
+	if len(b) < 1 {
+		return nil, errLacing
+	}
+
+	count := int(b[0]) + 1
+	b = b[1:]
+	sizes := make([]int, count)
+	switch lacing {
+	case 1:
+		// Xiph.
+		for i := 0; i < count-1; i++ {
+			for {
+				if len(b) == 0 {
+					return nil, errLacing
+				}
+				v := b[0]
+				b = b[1:]
+				sizes[i] += int(v)
+				if v != 255 {
+					break
+				}
+			}
+		}
+	case 3:
+		// EBML.
+		v, n := ebmlVint(b)
+		if n == 0 {
+			return nil, errLacing
+		}
+		b = b[n:]
+		sizes[0] = int(v)
+		for i := 1; i < count-1; i++ {
+			v, n := ebmlVint(b)
+			if n == 0 {
+				return nil, errLacing
+			}
+			b = b[n:]
+			// Signed difference with the previous size.
+			diff := int64(v) - (int64(1)<<(uint(7*n)-1) - 1)
+			sizes[i] = sizes[i-1] + int(diff)
+		}
+	case 2:
+		// Fixed.
+		if len(b)%count != 0 {
+			return nil, errLacing
+		}
+		for i := range sizes {
+			sizes[i] = len(b) / count
+		}
+	}
+
+	if lacing != 2 {
+		total := 0
+		for _, s := range sizes[:count-1] {
+			if s < 0 {
+				return nil, errLacing
+			}
+			total += s
+		}
+		if total > len(b) {
+			return nil, errLacing
+		}
+		sizes[count-1] = len(b) - total
+	}
+
+	frames := make([][]byte, count)
+	for i, s := range sizes {
+		frames[i], b = b[:s], b[s:]
+	}
+
+	return frames, nil
+}
+
+// opusSamples returns the duration of an opus packet in 48kHz samples.
+func opusSamples(p []byte) int64 {
+	if len(p) == 0 {
+		return 0
+	}
+
+	toc := p[0]
+	config := toc >> 3
+	var frame int64
+	switch {
+	case config < 12:
+		frame = []int64{480, 960, 1920, 2880}[config&3]
+	case config < 16:
+		frame = []int64{480, 960}[config&1]
+	default:
+		frame = []int64{120, 240, 480, 960}[config&3]
+	}
+
+	switch toc & 3 {
+	case 0:
+		return frame
+	case 1, 2:
+		return frame * 2
+	}
+
+	if len(p) < 2 {
+		return 0
+	}
+	return frame * int64(p[1]&0x3f)
+}
+
+func ebmlID(r *reader) (uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	l := ebmlLength(b)
+	if l == 0 || l > 4 {
+		return 0, errors.New("Invalid webm element id")
+	}
+
+	id := uint64(b)
+	for i := 1; i < l; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		id = id<<8 | uint64(b)
+	}
+
+	return id, nil
+}
+
+func ebmlSize(r *reader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	l := ebmlLength(b)
+	if l == 0 {
+		return 0, errors.New("Invalid webm element size")
+	}
+
+	v := uint64(b) & (0xff >> uint(l))
+	unknown := v == 0xff>>uint(l)
+	for i := 1; i < l; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<8 | uint64(b)
+		unknown = unknown && b == 0xff
+	}
+
+	if unknown {
+		return ebmlUnknownSize, nil
+	}
+
+	return int64(v), nil
+}
+
+// ebmlVint decodes a variable length integer from b, returning its value
+// and length, the length is 0 if b is invalid.
+func ebmlVint(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+
+	l := ebmlLength(b[0])
+	if l == 0 || l > len(b) {
+		return 0, 0
+	}
+
+	v := uint64(b[0]) & (0xff >> uint(l))
+	for i := 1; i < l; i++ {
+		v = v<<8 | uint64(b[i])
+	}
+
+	return v, l
+}
+
+func ebmlLength(b byte) int {
+	for i := 0; i < 8; i++ {
+		if b&(0x80>>uint(i)) != 0 {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+
+	return v
+}
@@ -0,0 +1,103 @@
+package audio
+
+import "testing"
+
+func TestUnlace(t *testing.T) {
+	tests := []struct {
+		lacing byte
+		in     []byte
+		sizes  []int
+	}{
+		{0, []byte("abc"), []int{3}},
+		{1, append([]byte{2, 255, 1, 2}, make([]byte, 256+2+3)...), []int{256, 2, 3}},
+		{2, []byte{2, 1, 2, 3, 4, 5, 6}, []int{2, 2, 2}},
+		// 3, 3+2, rest
+		{3, append([]byte{2, 0x83, 0xc1}, make([]byte, 3+5+1)...), []int{3, 5, 1}},
+	}
+
+	for _, test := range tests {
+		frames, err := unlace(test.in, test.lacing)
+		if err != nil {
+			t.Errorf("lacing %d: %s", test.lacing, err)
+			continue
+		}
+		var sizes []int
+		for _, f := range frames {
+			sizes = append(sizes, len(f))
+		}
+		if len(sizes) != len(test.sizes) {
+			t.Errorf("lacing %d: sizes %v, expected %v", test.lacing, sizes, test.sizes)
+			continue
+		}
+		for i := range sizes {
+			if sizes[i] != test.sizes[i] {
+				t.Errorf("lacing %d: sizes %v, expected %v", test.lacing, sizes, test.sizes)
+				break
+			}
+		}
+	}
+
+	invalid := []struct {
+		lacing byte
+		in     []byte
+	}{
+		{1, nil},
+		{1, []byte{1, 255}},
+		{1, []byte{1, 10, 0}},
+		{2, []byte{2, 1, 2, 3, 4}},
+		{3, []byte{1, 0}},
+	}
+	for _, test := range invalid {
+		if _, err := unlace(test.in, test.lacing); err == nil {
+			t.Errorf("lacing %d %x: expected an error", test.lacing, test.in)
+		}
+	}
+}
+
+func TestOpusSamples(t *testing.T) {
+	tests := []struct {
+		p []byte
+		n int64
+	}{
+		{nil, 0},
+		// SILK 10ms, 20ms
+		{[]byte{0 << 3}, 480},
+		{[]byte{1 << 3}, 960},
+		// hybrid 20ms, 2 frames
+		{[]byte{13<<3 | 1}, 1920},
+		// CELT 2.5ms, 5 frames
+		{[]byte{16<<3 | 3, 5}, 600},
+		{[]byte{19<<3 | 3}, 0},
+	}
+
+	for _, test := range tests {
+		if n := opusSamples(test.p); n != test.n {
+			t.Errorf("%x: %d samples, expected %d", test.p, n, test.n)
+		}
+	}
+}
+
+func TestEBMLSize(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		size int64
+	}{
+		{[]byte{0x81}, 1},
+		{[]byte{0x40, 0x02}, 2},
+		{[]byte{0xff}, ebmlUnknownSize},
+		{[]byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, ebmlUnknownSize},
+		{[]byte{0x01, 0, 0, 0, 0, 0, 0x01, 0x00}, 256},
+	}
+
+	for _, test := range tests {
+		r := &reader{r: bufioReader(test.in)}
+		size, err := ebmlSize(r)
+		if err != nil {
+			t.Errorf("%x: %s", test.in, err)
+			continue
+		}
+		if size != test.size {
+			t.Errorf("%x: size %d, expected %d", test.in, size, test.size)
+		}
+	}
+}
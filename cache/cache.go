@@ -11,18 +11,51 @@ import (
 	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/frizinak/ym/audio"
+	"github.com/frizinak/ym/search"
+)
+
+var (
+	errNoAnalyzer  = errors.New("No analyzer configured")
+	errNoInspector = errors.New("No inspector configured")
+	errNotCached   = errors.New("Not cached")
 )
 
 type Transcoder interface {
 	Transcode(video io.Reader, audio io.Writer) error
 	Ext() string
+	Preset() string
+	Filters() string
+}
+
+// ExtTranscoder is a Transcoder of which the output format depends
+// on the input, e.g.: audio.Native.
+type ExtTranscoder interface {
+	TranscodeExt(video io.Reader, audio io.Writer) (string, error)
 }
 
+// FilterTranscoder is a Transcoder with filters that depend on the
+// duration of the input (e.g.: fading out) or that remove audio from the
+// start or end (e.g.: trimming silence). The end is cut once the output
+// is written.
+type FilterTranscoder interface {
+	NeedsDuration() bool
+	TranscodeFilters(v io.Reader, a io.Writer, dur time.Duration) (audio.Silence, error)
+	Cut(file string, length time.Duration) error
+}
+
+// presetNone is recorded for entries that were stored as downloaded.
+const presetNone = "none"
+
 type Entry struct {
-	id  string
-	ext string
-	url *url.URL
+	id   string
+	ext  string
+	url  *url.URL
+	info search.Info
 }
 
 func (e *Entry) ID() string    { return e.id }
@@ -30,7 +63,7 @@ func (e *Entry) Ext() string   { return e.ext }
 func (e *Entry) URL() *url.URL { return e.url }
 
 func NewEntry(id, ext string, url *url.URL) *Entry {
-	return &Entry{id, ext, url}
+	return &Entry{id: id, ext: ext, url: url}
 }
 
 type Cached struct {
@@ -48,8 +81,26 @@ func (c *Cached) Path() string {
 
 type Cache struct {
 	t       Transcoder
+	a       Analyzer
+	i       Inspector
+	tagger  Tagger
 	dir     string
 	tempdir string
+
+	metaSem sync.Mutex
+	metas   map[string]*Meta
+
+	flightSem sync.Mutex
+	inflight  map[string]*flight
+
+	metrics cacheMetrics
+}
+
+// flight is a download in progress, concurrent downloads of the same
+// id wait for it instead.
+type flight struct {
+	done chan struct{}
+	err  error
 }
 
 func New(t Transcoder, dir, tempdir string) (*Cache, error) {
@@ -61,7 +112,13 @@ func New(t Transcoder, dir, tempdir string) (*Cache, error) {
 		return nil, err
 	}
 
-	return &Cache{t, dir, tempdir}, nil
+	return &Cache{
+		t:        t,
+		dir:      dir,
+		tempdir:  tempdir,
+		metas:    make(map[string]*Meta),
+		inflight: make(map[string]*flight),
+	}, nil
 }
 
 func (c *Cache) Get(id string) *Cached {
@@ -70,11 +127,53 @@ func (c *Cache) Get(id string) *Cached {
 	}
 
 	r, err := filepath.Glob(path.Join(c.dir, hashFn(id, true)+"*"))
-	if err != nil || len(r) == 0 {
+	if err != nil {
 		return nil
 	}
 
-	return &Cached{id, r[0]}
+	for _, f := range r {
+		if !strings.HasSuffix(f, metaExt) {
+			if m := c.Meta(id); m.Preset != "" &&
+				(m.Preset != c.preset() || m.Filters != c.filters()) {
+				// Produced by a different preset or filter chain, recache.
+				return nil
+			}
+			return &Cached{id, f}
+		}
+	}
+
+	return nil
+}
+
+// preset returns the name of the preset new entries are stored with.
+func (c *Cache) preset() string {
+	if c.t == nil {
+		return presetNone
+	}
+
+	return c.t.Preset()
+}
+
+func (c *Cache) filters() string {
+	if c.t == nil {
+		return ""
+	}
+
+	return c.t.Filters()
+}
+
+// removeStale removes all files of id except keep and its metadata.
+func (c *Cache) removeStale(id, keep string) {
+	r, err := filepath.Glob(path.Join(c.dir, hashFn(id, true)+"*"))
+	if err != nil {
+		return
+	}
+
+	for _, f := range r {
+		if f != keep && !strings.HasSuffix(f, metaExt) {
+			os.Remove(f)
+		}
+	}
 }
 
 func (c *Cache) Set(e *Entry) error {
@@ -95,19 +194,62 @@ func (c *Cache) SetProgress(e *Entry, progress func(written, total int64)) error
 		return errors.New("id cannot be empty")
 	}
 
+	c.flightSem.Lock()
+	if f, ok := c.inflight[id]; ok {
+		c.flightSem.Unlock()
+		<-f.done
+		return f.err
+	}
+	f := &flight{done: make(chan struct{})}
+	c.inflight[id] = f
+	c.flightSem.Unlock()
+
+	f.err = c.set(e, progress)
+	c.flightSem.Lock()
+	delete(c.inflight, id)
+	c.flightSem.Unlock()
+	close(f.done)
+
+	return f.err
+}
+
+func (c *Cache) set(e *Entry, progress func(written, total int64)) error {
+	u, id := e.URL(), e.ID()
 	tmp := path.Join(
 		c.tempdir,
 		hashFn(id, false)+"."+strconv.FormatInt(time.Now().UnixNano(), 36),
 	)
 
-	if err := download(c.t, u.String(), tmp, progress); err != nil {
+	var dur time.Duration
+	if t, ok := c.t.(FilterTranscoder); ok && t.NeedsDuration() {
+		dur = c.sourceDuration(e)
+	}
+
+	start := time.Now()
+	tExt, n, silence, err := download(c.t, u.String(), tmp, dur, progress)
+	if err == nil && silence.Trailing > 0 {
+		err = c.t.(FilterTranscoder).Cut(tmp, silence.Trailing)
+	}
+	c.metrics.bytes.Add(float64(n))
+	if err != nil {
+		os.Remove(tmp)
+		c.metrics.errors.Inc()
 		return err
 	}
+	c.metrics.duration.Observe(time.Since(start).Seconds())
+
+	if c.a != nil {
+		// Not critical, retried on playback.
+		c.Analyze(id, tmp)
+	}
 
 	ext := e.Ext()
 	if c.t != nil {
 		ext = c.t.Ext()
 	}
+	if tExt != "" {
+		ext = tExt
+	}
 
 	dest := path.Join(c.dir, c.Base(id)+"."+ext)
 	dir := path.Dir(dest)
@@ -117,12 +259,51 @@ func (c *Cache) SetProgress(e *Entry, progress func(written, total int64)) error
 
 	if err := os.Rename(tmp, dest); err != nil {
 		defer os.Remove(tmp)
-		return copy(dest, tmp)
+		if err := copy(dest, tmp); err != nil {
+			return err
+		}
+	}
+
+	if c.tagger != nil && e.info != nil {
+		// Not critical, can be applied later with ym-cache retag.
+		c.tag(dest, e.info)
+	}
+
+	c.removeStale(id, dest)
+	preset, filters := c.preset(), c.filters()
+	err = c.UpdateMeta(id, func(m *Meta) {
+		m.Preset = preset
+		m.Filters = filters
+		m.Trimmed = silence.Leading
+		m.Media = nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.i != nil {
+		// Not critical, retried on playback.
+		c.inspect(id, dest)
 	}
 
 	return nil
 }
 
+// sourceDuration returns the duration of the remote file of e, probed if
+// possible.
+func (c *Cache) sourceDuration(e *Entry) time.Duration {
+	if c.i != nil {
+		if m, err := c.i.Inspect(e.URL().String()); err == nil && m.Exact {
+			return m.Duration()
+		}
+	}
+	if e.info != nil {
+		return e.info.Duration()
+	}
+
+	return 0
+}
+
 func (c *Cache) Base(id string) string {
 	return hashFn(id, true)
 }
@@ -162,6 +343,17 @@ func copy(dest, src string) error {
 	return err
 }
 
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countReader) Read(d []byte) (n int, err error) {
+	n, err = c.r.Read(d)
+	c.n += int64(n)
+	return
+}
+
 type progressWriter struct {
 	w       io.WriteCloser
 	size    int64
@@ -187,12 +379,19 @@ func (p *progressWriter) Close() error {
 	return p.w.Close()
 }
 
-func download(t Transcoder, u, dest string, progress func(int64, int64)) error {
+// download returns the extension reported by an ExtTranscoder, if any,
+// and the amount of bytes downloaded.
+func download(
+	t Transcoder,
+	u, dest string,
+	dur time.Duration,
+	progress func(int64, int64),
+) (ext string, n int64, silence audio.Silence, err error) {
 	_f, err := os.Create(dest)
 	f := &progressWriter{_f, 0, 0, progress}
 	defer f.Close()
 	if err != nil {
-		return err
+		return "", 0, silence, err
 	}
 
 	res, err := http.Get(u)
@@ -200,16 +399,28 @@ func download(t Transcoder, u, dest string, progress func(int64, int64)) error {
 		defer res.Body.Close()
 	}
 
+	if err != nil {
+		return "", 0, silence, err
+	}
+
 	f.size = res.ContentLength
+	body := &countReader{r: res.Body}
+	defer func() { n = body.n }()
 
-	if err != nil {
-		return err
+	if et, ok := t.(ExtTranscoder); ok {
+		ext, err = et.TranscodeExt(body, f)
+		return ext, 0, silence, err
+	}
+
+	if ft, ok := t.(FilterTranscoder); ok {
+		silence, err = ft.TranscodeFilters(body, f, dur)
+		return "", 0, silence, err
 	}
 
 	if t != nil {
-		return t.Transcode(res.Body, f)
+		return "", 0, silence, t.Transcode(body, f)
 	}
 
-	_, err = io.Copy(f, res.Body)
-	return err
+	_, err = io.Copy(f, body)
+	return "", 0, silence, err
 }
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"time"
+
+	"github.com/frizinak/ym/audio"
+)
+
+const metaExt = ".meta"
+
+type Analyzer interface {
+	Analyze(input string) (*audio.Loudness, error)
+}
+
+type Inspector interface {
+	Inspect(file string) (*audio.Media, error)
+}
+
+// Meta is stored alongside a cache entry, or on its own for items
+// that were only ever streamed.
+type Meta struct {
+	// Preset is the name of the transcoder preset that produced the entry.
+	Preset string `json:"preset,omitempty"`
+	// Filters is the audio filter chain applied to the entry.
+	Filters string `json:"filters,omitempty"`
+	// Trimmed is the duration of silence the filters removed from the
+	// start, positions in the source are this much earlier in the entry.
+	Trimmed  time.Duration   `json:"trimmed,omitempty"`
+	Loudness *audio.Loudness `json:"loudness,omitempty"`
+	// Media is only set for cached entries.
+	Media *audio.Media `json:"media,omitempty"`
+}
+
+func (c *Cache) SetAnalyzer(a Analyzer) {
+	if c != nil {
+		c.a = a
+	}
+}
+
+func (c *Cache) SetInspector(i Inspector) {
+	if c != nil {
+		c.i = i
+	}
+}
+
+// Meta returns the metadata stored for id, never nil.
+func (c *Cache) Meta(id string) *Meta {
+	if c == nil {
+		return &Meta{}
+	}
+
+	c.metaSem.Lock()
+	defer c.metaSem.Unlock()
+	m := c.meta(id)
+	cp := *m
+	return &cp
+}
+
+// UpdateMeta atomically modifies and persists the metadata of id.
+func (c *Cache) UpdateMeta(id string, cb func(m *Meta)) error {
+	c.metaSem.Lock()
+	defer c.metaSem.Unlock()
+	m := c.meta(id)
+	cb(m)
+
+	f := c.metaFile(id)
+	if err := os.MkdirAll(path.Dir(f), 0755); err != nil {
+		return err
+	}
+
+	d, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	// outside of dir so Get never sees it
+	tmp := path.Join(c.tempdir, hashFn(id, false)+metaExt)
+	fh, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = fh.Write(d); err != nil {
+		fh.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err = fh.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, f); err != nil {
+		defer os.Remove(tmp)
+		return copy(f, tmp)
+	}
+
+	return nil
+}
+
+// Analyze measures the loudness of input (a file or url) and stores
+// it as the loudness of id.
+func (c *Cache) Analyze(id, input string) (*audio.Loudness, error) {
+	if c == nil || c.a == nil {
+		return nil, errNoAnalyzer
+	}
+
+	l, err := c.a.Analyze(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, c.UpdateMeta(id, func(m *Meta) { m.Loudness = l })
+}
+
+func (c *Cache) meta(id string) *Meta {
+	if m, ok := c.metas[id]; ok {
+		return m
+	}
+
+	m := &Meta{}
+	if f, err := os.Open(c.metaFile(id)); err == nil {
+		json.NewDecoder(f).Decode(m)
+		f.Close()
+	}
+
+	c.metas[id] = m
+	return m
+}
+
+func (c *Cache) metaFile(id string) string {
+	return path.Join(c.dir, c.Base(id)+metaExt)
+}
+
+// Inspect stores the properties of the cached file of id.
+func (c *Cache) Inspect(id string) (*audio.Media, error) {
+	if c == nil || c.i == nil {
+		return nil, errNoInspector
+	}
+
+	cached := c.Get(id)
+	if cached == nil {
+		return nil, errNotCached
+	}
+
+	return c.inspect(id, cached.Path())
+}
+
+func (c *Cache) inspect(id, file string) (*audio.Media, error) {
+	m, err := c.i.Inspect(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return m, c.UpdateMeta(id, func(meta *Meta) { meta.Media = m })
+}
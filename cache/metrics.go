@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/frizinak/ym/metrics"
+)
+
+const sizeTTL = time.Second
+
+type cacheMetrics struct {
+	bytes    *metrics.Metric
+	duration *metrics.Metric
+	errors   *metrics.Metric
+	sizes    *sizes
+}
+
+// SetMetrics registers the cache metrics on r.
+func (c *Cache) SetMetrics(r *metrics.Registry) {
+	if c == nil {
+		return
+	}
+
+	c.metrics = cacheMetrics{
+		bytes: r.Counter(
+			"ym_cache_download_bytes_total",
+			"Bytes downloaded into the cache.",
+		),
+		duration: r.Summary(
+			"ym_cache_download_duration_seconds",
+			"Duration of successful downloads, including transcoding.",
+		),
+		errors: r.Counter(
+			"ym_cache_download_errors_total",
+			"Failed downloads.",
+		),
+		sizes: &sizes{},
+	}
+
+	r.GaugeFunc("ym_cache_entries", "Amount of cached items.", func() float64 {
+		n, _ := c.metrics.sizes.get(c)
+		return float64(n)
+	})
+	r.GaugeFunc("ym_cache_bytes", "Size of the cached items.", func() float64 {
+		_, size := c.metrics.sizes.get(c)
+		return float64(size)
+	})
+}
+
+// sizes remembers Size for sizeTTL so both gauges of a scrape share a
+// single walk of the cache.
+type sizes struct {
+	sem     sync.Mutex
+	at      time.Time
+	entries int
+	size    int64
+}
+
+func (s *sizes) get(c *Cache) (int, int64) {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	if time.Since(s.at) > sizeTTL {
+		s.entries, s.size = c.Size()
+		s.at = time.Now()
+	}
+
+	return s.entries, s.size
+}
+
+// Size returns the amount of cached files and their total size,
+// metadata and files that are being written are not counted.
+func (c *Cache) Size() (entries int, size int64) {
+	filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path == filepath.Clean(c.tempdir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() ||
+			strings.HasSuffix(path, metaExt) ||
+			strings.HasPrefix(info.Name(), ".") {
+			return nil
+		}
+		entries++
+		size += info.Size()
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/frizinak/ym/metrics"
+)
+
+func TestSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ym-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := New(nil, dir, filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]int{
+		"ab/abcdef.opus":      100,
+		"ab/abcdef.meta":      10,
+		"cd/cdef01.m4a":       50,
+		"cd/.cdef01.m4a":      50,
+		"tmp/abcdef.meta":     10,
+		"tmp/tag.cdef01.m4a":  50,
+		"tmp/cdef01.kwr3jx9s": 50,
+	}
+	for name, size := range files {
+		f := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, size := c.Size()
+	if entries != 2 || size != 150 {
+		t.Errorf("%d entries of %d bytes, expected 2 of 150", entries, size)
+	}
+
+	r := metrics.NewRegistry()
+	c.SetMetrics(r)
+	buf := bytes.NewBuffer(nil)
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{"ym_cache_entries 2\n", "ym_cache_bytes 150\n"} {
+		if !strings.Contains(buf.String(), l) {
+			t.Errorf("%q missing from\n%s", l, buf.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/frizinak/ym/audio"
+	"github.com/frizinak/ym/search"
+)
+
+type Tagger interface {
+	Tag(file, out string, t *audio.Tags) error
+}
+
+func (c *Cache) SetTagger(t Tagger) {
+	if c != nil {
+		c.tagger = t
+	}
+}
+
+// WithInfo makes Set tag the stored file using i.
+func (e *Entry) WithInfo(i search.Info) *Entry {
+	e.info = i
+	return e
+}
+
+// Tag writes the tags derived from i to the cached file of id.
+func (c *Cache) Tag(id string, i search.Info) error {
+	if c == nil || c.tagger == nil {
+		return errors.New("No tagger configured")
+	}
+
+	cached := c.Get(id)
+	if cached == nil {
+		return errNotCached
+	}
+
+	return c.tag(cached.Path(), i)
+}
+
+func (c *Cache) tag(file string, i search.Info) error {
+	t := &audio.Tags{
+		Title:  i.Title(),
+		Artist: i.Author(),
+	}
+	if d := i.Created(); !d.IsZero() {
+		t.Date = d.Format("2006-01-02")
+	}
+	if u := i.PageURL(); u != nil {
+		t.URL = u.String()
+	}
+	if u := i.Thumbnail(); u != nil {
+		t.Cover = u.String()
+	}
+
+	// written outside of dir so Get never sees it and copied into file
+	// so hardlinks to it see the tags as well
+	tmp := path.Join(c.tempdir, "tag."+filepath.Base(file))
+	if err := c.tagger.Tag(file, tmp, t); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	return copy(file, tmp)
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/audio"
+	"github.com/frizinak/ym/search"
+)
+
+type testTagger struct{}
+
+func (testTagger) Tag(file, out string, t *audio.Tags) error {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(out, append(d, []byte(" "+t.Artist+" - "+t.Title)...), 0644)
+}
+
+type testInfo struct{}
+
+func (testInfo) ID() string                 { return "video" }
+func (testInfo) PageURL() *url.URL          { return nil }
+func (testInfo) Title() string              { return "Track" }
+func (testInfo) Created() time.Time         { return time.Time{} }
+func (testInfo) Formats() []*search.Format  { return nil }
+func (testInfo) Author() string             { return "Artist" }
+func (testInfo) Duration() time.Duration    { return 0 }
+func (testInfo) Thumbnail() *url.URL        { return nil }
+func (testInfo) Chapters() []search.Chapter { return nil }
+
+func TestTagHardlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ym-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := New(nil, filepath.Join(dir, "cache"), filepath.Join(dir, "tmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTagger(testTagger{})
+
+	file := path.Join(c.dir, hashFn("video", true)+".opus")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// e.g.: ym-files
+	link := filepath.Join(dir, "Track.opus")
+	if err := os.Link(file, link); err != nil {
+		t.Skip(err)
+	}
+
+	if err := c.Tag("video", testInfo{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{file, link} {
+		d, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(d) != "audio Artist - Track" {
+			t.Errorf("%s: %q, expected the tagged file", f, d)
+		}
+	}
+
+	if tmp, _ := ioutil.ReadDir(c.tempdir); len(tmp) != 0 {
+		t.Errorf("%d temporary files left", len(tmp))
+	}
+}
@@ -1,40 +1,295 @@
 package config
 
 import (
-	"os/user"
-	"path"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/frizinak/ym/audio"
 	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/scrobble"
+	"github.com/frizinak/ym/search"
 )
 
 var (
-	CacheDir   string
-	Playlist   string
-	Downloads  string
+	// Downloads and temporary files are stored in CacheDir
+	// ($XDG_CACHE_HOME/ym), the queue and other user data in DataDir
+	// ($XDG_DATA_HOME/ym).
+	CacheDir  string
+	DataDir   string
+	TempDir   string
+	Playlist  string
+	Downloads string
+	// Unix socket the player listens on for ym-ctl and other instances of ym,
+	// in $XDG_RUNTIME_DIR/ym if set.
+	Socket     string
 	Preflights = 10
+	// Amount of simultaneous preflight requests and the deadline of each.
+	PreflightConcurrency = 4
+	PreflightTimeout     = time.Second * 5
+
+	// Search engines in order of preference, only youtube is supported,
+	// and the deadline of their requests.
+	Engines       = []string{"youtube"}
+	SearchTimeout = time.Second * 5
+
+	// Players and extractors in order of preference, the first one that
+	// is installed is used and gets the extra arguments.
+	// libmpv takes mpv options, e.g.: --cache-secs=60.
+	Players       = []string{"libmpv", "mpv", "mplayer", "ffplay"}
+	PlayerArgs    []string
+	Extractors    = []string{"ffmpeg", "mencoder", "native"}
+	ExtractorArgs []string
+
+	// Amount of items downloaded to the cache at once.
+	DownloadConcurrency = 1
+
+	// Amount of searches kept in the search history.
+	History = 20
+
+	// Address of the mpd compatible status server, empty to disable.
+	MPDAddr = "127.0.0.1:6600"
+
+	// Loudness normalization: off, track or album.
+	Normalize = "track"
+	// Target loudness in LUFS.
+	NormalizeTarget = -14.0
+
+	// Playback positions of items that are at least ResumeMin long
+	// are stored in Positions.
+	Positions string
+	ResumeMin = time.Minute * 20
+
+	// Duration over which the volume is faded out before the sleep
+	// timer pauses playback.
+	SleepFade = time.Second * 30
+
+	// Hand the next item to the player before the current one ends
+	// (libmpv and mpv only).
+	Gapless = false
+	// Overlap consecutive items, disables Gapless (libmpv and mpv only).
+	Crossfade time.Duration
+
+	// Resolve stream urls of the next Prefetch items in the background
+	// and download them to the cache first if PrefetchCache is set.
+	Prefetch      = 3
+	PrefetchCache = false
+
+	// Stream format preference, see search.FormatPolicy.
+	FormatAudioOnly  = true
+	FormatCodecs     = []string{"opus", "vorbis", "aac"}
+	FormatContainers = []string{"webm", "mp4"}
+	// Maximum audio bitrate in kbps, e.g.: for metered connections.
+	FormatMaxBitrate = 0
+
+	// Transcoder preset used for cached items, see audio.Presets.
+	// Entries cached with a different preset are downloaded again.
+	Preset = "aac"
+
+	// Audio filters applied when transcoding with ffmpeg,
+	// entries cached with different filters are downloaded again.
+	TrimStart     = true
+	TrimEnd       = false
+	TrimThreshold = -90.0
+	TrimDuration  time.Duration
+	FadeIn        time.Duration
+	FadeOut       time.Duration
+	Mono          = false
+	SampleRate    = 0
+
+	// Maximum amount of items loaded from a playlist, 0 for no limit.
+	PlaylistMax = 500
+
+	// Interval at which the queue is synced with its linked playlist
+	// (see :sync), 0 to only sync manually. Items removed from the playlist
+	// are removed from the queue if SyncRemove is set, which loads the
+	// whole playlist regardless of PlaylistMax.
+	SyncInterval = time.Minute * 10
+	SyncRemove   = false
+
+	// Address of the http api (e.g.: 127.0.0.1:6680), empty to disable.
+	// Requests need APIToken as a bearer token or token parameter if set.
+	APIAddr  = ""
+	APIToken = ""
+
+	// Address of the prometheus metrics endpoint (e.g.: 127.0.0.1:9680),
+	// served at /metrics, empty to disable.
+	MetricsAddr = ""
+
+	// Expose the player to desktop media keys and widgets (linux only).
+	MPRIS = true
+
+	// Shell commands run on track-start, track-end, pause, resume,
+	// playlist-changed and error, e.g.:
+	// Hooks = map[string][]string{"track-start": {`notify-send "$YM_TITLE"`}}
+	// See hooks.Hooks for the environment variables they get.
+	Hooks = map[string][]string{}
+	// Maximum amount of hooks running at once and their deadline.
+	HookConcurrency = 2
+	HookTimeout     = time.Second * 10
+
+	// Scrobble to ListenBrainz, or a compatible server at ScrobbleURL,
+	// if ScrobbleToken is set. Listens are queued in Scrobbles while offline.
+	ScrobbleURL     = scrobble.ListenBrainzURL
+	ScrobbleToken   = ""
+	ScrobbleTimeout = time.Second * 10
+	Scrobbles       string
+
+	// Mode the prompt starts in: insert, where keys type commands,
+	// or normal, where they are bound to actions (vi-style).
+	KeyMode = "insert"
+	// Key bindings per mode, merged with the defaults, an empty action
+	// removes a binding, e.g.:
+	// Keys = map[string]map[string]string{"normal": {"J": ":vol -1", "s": ""}}
+	// See :help in ym for the active bindings and available actions.
+	Keys = map[string]map[string]string{}
+
+	// Write title, artist, date, url and cover art to cached files
+	// (not supported by the aac preset).
+	Tag = true
 )
 
 func init() {
-	if user, err := user.Current(); err == nil {
-		CacheDir = path.Join(user.HomeDir, ".cache", "ym")
+	CacheDir = xdg("XDG_CACHE_HOME", ".cache")
+	DataDir = xdg("XDG_DATA_HOME", ".local", "share")
+	for p, v := range paths() {
+		*p = v
+	}
+}
+
+func Preflight() *search.Preflight {
+	return search.NewPreflight(Preflights, PreflightConcurrency, PreflightTimeout)
+}
+
+func FormatPolicy() *search.FormatPolicy {
+	return &search.FormatPolicy{
+		AudioOnly:  FormatAudioOnly,
+		Codecs:     FormatCodecs,
+		Containers: FormatContainers,
+		MaxBitrate: FormatMaxBitrate,
+	}
+}
+
+func Filters() *audio.Filters {
+	return &audio.Filters{
+		TrimStart:     TrimStart,
+		TrimEnd:       TrimEnd,
+		TrimThreshold: TrimThreshold,
+		TrimDuration:  TrimDuration,
+		FadeIn:        FadeIn,
+		FadeOut:       FadeOut,
+		Mono:          Mono,
+		SampleRate:    SampleRate,
+	}
+}
+
+func Engine() (search.Engine, error) {
+	for _, name := range Engines {
+		switch name {
+		case "youtube":
+			return search.NewYoutube(SearchTimeout)
+		default:
+			return nil, fmt.Errorf("Unknown engine %s", name)
+		}
 	}
-	Playlist = filepath.Join(CacheDir, "playlist")
-	Downloads = filepath.Join(CacheDir, "downloads")
+
+	return nil, errors.New("No engine configured")
 }
 
+// Extractor returns audio.ErrNoExtractor if none of Extractors is
+// supported, other errors mean Preset or the filters are invalid and
+// should not be ignored.
 func Extractor() (audio.Extractor, error) {
-	return audio.FindSupportedExtractor(
-		audio.NewFFMPEG(),
-		audio.NewMEncoder(),
+	p, err := audio.ParsePreset(Preset)
+	if err != nil {
+		return nil, err
+	}
+	f := Filters()
+	if err := f.Validate(p); err != nil {
+		return nil, err
+	}
+
+	native := audio.NewNative()
+	native.SetTempDir(TempDir)
+	all := map[string]audio.Extractor{
+		"ffmpeg":   audio.NewFFMPEGPreset(p, f),
+		"mencoder": audio.NewMEncoder(),
+		"native":   native,
+	}
+	list := make([]audio.Extractor, 0, len(Extractors))
+	for _, name := range Extractors {
+		e, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown extractor %s", name)
+		}
+		list = append(list, e)
+	}
+
+	e, err := audio.FindSupportedExtractor(list...)
+	if a, ok := e.(audio.ArgSetter); ok && len(ExtractorArgs) != 0 {
+		a.SetArgs(ExtractorArgs)
+	}
+
+	return e, err
+}
+
+func Tagger() (audio.Tagger, error) {
+	if !Tag {
+		return nil, errors.New("Tagging disabled")
+	}
+
+	return audio.FindSupportedTagger(
+		audio.NewFFMPEGTagger(),
 	)
 }
 
-func Player(volumeChan chan int, seekChan chan *player.Pos) (player.Player, error) {
-	return player.FindSupportedPlayer(
-		player.NewLibMPV(volumeChan, seekChan),
-		player.NewMPlayer(),
-		player.NewFFPlay(),
+func Inspector() (audio.Inspector, error) {
+	return audio.FindSupportedInspector(
+		audio.NewFFProbe(),
 	)
 }
+
+func Splitter() (audio.Splitter, error) {
+	return audio.FindSupportedSplitter(
+		audio.NewFFMPEGSplitter(),
+	)
+}
+
+func Analyzer() (audio.Analyzer, error) {
+	if Normalize == "off" || Normalize == "" {
+		return nil, errors.New("Normalization disabled")
+	}
+
+	return audio.FindSupportedAnalyzer(
+		audio.NewFFMPEGAnalyzer(),
+	)
+}
+
+func Player(volumeChan chan int, seekChan chan *player.Pos) (player.Player, error) {
+	list := make([]player.Player, 0, len(Players))
+	for _, name := range Players {
+		var p player.Player
+		switch name {
+		case "libmpv":
+			p = player.NewLibMPV(volumeChan, seekChan)
+		case "mpv":
+			m := player.NewMPV(volumeChan, seekChan)
+			m.SetSocketDir(runtimeDir())
+			p = m
+		case "mplayer":
+			p = player.NewMPlayer()
+		case "ffplay":
+			p = player.NewFFPlay()
+		default:
+			return nil, fmt.Errorf("Unknown player %s", name)
+		}
+		list = append(list, p)
+	}
+
+	p, err := player.FindSupportedPlayer(list...)
+	if a, ok := p.(player.ArgSetter); ok && len(PlayerArgs) != 0 {
+		a.SetArgs(PlayerArgs)
+	}
+
+	return p, err
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is the json config file, it is read from $YM_CONFIG or
+// $XDG_CONFIG_HOME/ym/config. Its keys are the option names below.
+var File string
+
+// option is a setting that can be changed in File, in the environment
+// as YM_<NAME> and with the -<name> flag (with dashes).
+// Maps can only be set in File.
+type option struct {
+	name string
+	v    interface{}
+	help string
+}
+
+var options = []option{
+	{"cache_dir", &CacheDir, "directory of downloads"},
+	{"data_dir", &DataDir, "directory of the queue and other user data"},
+	{"temp_dir", &TempDir, "directory of downloads in progress"},
+	{"playlist", &Playlist, "queue file"},
+	{"downloads", &Downloads, "download cache directory"},
+	{"positions", &Positions, "resume positions file"},
+	{"scrobbles", &Scrobbles, "queued scrobbles file"},
+	{"socket", &Socket, "unix socket for ym-ctl and other instances"},
+
+	{"engines", &Engines, "search engines"},
+	{"search_timeout", &SearchTimeout, "search request deadline"},
+	{"players", &Players, "players in order of preference"},
+	{"player_args", &PlayerArgs, "extra player arguments"},
+	{"extractors", &Extractors, "extractors in order of preference"},
+	{"extractor_args", &ExtractorArgs, "extra extractor arguments"},
+	{"download_concurrency", &DownloadConcurrency, "simultaneous downloads"},
+	{"history", &History, "amount of searches to remember"},
+
+	{"mpd_addr", &MPDAddr, "mpd status server address"},
+	{"api_addr", &APIAddr, "http api address"},
+	{"api_token", &APIToken, "http api bearer token"},
+	{"metrics_addr", &MetricsAddr, "prometheus metrics address"},
+	{"mpris", &MPRIS, "expose the player over MPRIS"},
+
+	{"preflights", &Preflights, "maximum stream urls to try"},
+	{"preflight_concurrency", &PreflightConcurrency, "simultaneous preflight requests"},
+	{"preflight_timeout", &PreflightTimeout, "preflight request deadline"},
+
+	{"normalize", &Normalize, "loudness normalization: off, track or album"},
+	{"normalize_target", &NormalizeTarget, "target loudness in LUFS"},
+	{"resume_min", &ResumeMin, "minimum length of items to resume"},
+	{"sleep_fade", &SleepFade, "sleep timer fade out"},
+	{"gapless", &Gapless, "gapless playback"},
+	{"crossfade", &Crossfade, "crossfade duration"},
+	{"prefetch", &Prefetch, "amount of items to resolve ahead"},
+	{"prefetch_cache", &PrefetchCache, "download prefetched items"},
+
+	{"format_audio_only", &FormatAudioOnly, "prefer audio only streams"},
+	{"format_codecs", &FormatCodecs, "preferred codecs"},
+	{"format_containers", &FormatContainers, "preferred containers"},
+	{"format_max_bitrate", &FormatMaxBitrate, "maximum audio bitrate in kbps"},
+	{"preset", &Preset, "transcoder preset"},
+	{"trim_start", &TrimStart, "trim leading silence"},
+	{"trim_end", &TrimEnd, "trim trailing silence"},
+	{"trim_threshold", &TrimThreshold, "silence threshold in dB"},
+	{"trim_duration", &TrimDuration, "minimum silence duration"},
+	{"fade_in", &FadeIn, "fade in duration"},
+	{"fade_out", &FadeOut, "fade out duration"},
+	{"mono", &Mono, "downmix to mono"},
+	{"sample_rate", &SampleRate, "resample to this rate"},
+	{"tag", &Tag, "tag cached files"},
+
+	{"playlist_max", &PlaylistMax, "maximum items loaded from a playlist"},
+	{"sync_interval", &SyncInterval, "linked playlist sync interval"},
+	{"sync_remove", &SyncRemove, "remove items removed from the linked playlist, syncs the whole playlist regardless of playlist_max"},
+
+	{"hooks", &Hooks, "event hooks"},
+	{"hook_concurrency", &HookConcurrency, "simultaneous hooks"},
+	{"hook_timeout", &HookTimeout, "hook deadline"},
+
+	{"scrobble_url", &ScrobbleURL, "ListenBrainz compatible server"},
+	{"scrobble_token", &ScrobbleToken, "ListenBrainz user token"},
+	{"scrobble_timeout", &ScrobbleTimeout, "scrobble request deadline"},
+
+	{"key_mode", &KeyMode, "initial prompt mode: insert or normal"},
+	{"keys", &Keys, "key bindings per mode"},
+}
+
+func init() {
+	File = os.Getenv("YM_CONFIG")
+	if File != "" {
+		return
+	}
+
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		if user, err := user.Current(); err == nil {
+			dir = filepath.Join(user.HomeDir, ".config")
+		}
+	}
+	File = filepath.Join(dir, "ym", "config")
+}
+
+// Load applies File, the environment and the flags in args, in that order.
+// Flags of the binary itself should be defined on fs beforehand.
+func Load(fs *flag.FlagSet, args []string) error {
+	defaults := paths()
+	if err := loadFile(File); err != nil {
+		return fmt.Errorf("Could not load %s: %s", File, err)
+	}
+
+	for _, o := range options {
+		env := "YM_" + strings.ToUpper(o.name)
+		if v, ok := os.LookupEnv(env); ok {
+			if err := o.set(v); err != nil {
+				return fmt.Errorf("Invalid %s: %s", env, err)
+			}
+		}
+
+		if !o.fileOnly() {
+			fs.Var(o, strings.Replace(o.name, "_", "-", -1), o.help)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// paths that weren't set follow CacheDir and DataDir
+	current := paths()
+	for p, v := range defaults {
+		if *p == v {
+			*p = current[p]
+		}
+	}
+
+	return nil
+}
+
+func paths() map[*string]string {
+	return map[*string]string{
+		&Playlist:  filepath.Join(DataDir, "playlist"),
+		&Positions: filepath.Join(DataDir, "positions"),
+		&Scrobbles: filepath.Join(DataDir, "scrobbles"),
+		&Downloads: filepath.Join(CacheDir, "downloads"),
+		// same filesystem as Downloads so finished downloads can be moved
+		&TempDir: filepath.Join(CacheDir, "tmp"),
+		&Socket:  filepath.Join(runtimeDir(), "ym.sock"),
+	}
+}
+
+func loadFile(file string) error {
+	d, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	raw := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(d, &raw); err != nil {
+		return err
+	}
+
+	for _, o := range options {
+		v, ok := raw[o.name]
+		if !ok {
+			continue
+		}
+		delete(raw, o.name)
+
+		if _, ok := o.v.(*time.Duration); ok {
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return fmt.Errorf("%s: %s", o.name, err)
+			}
+			if err := o.set(s); err != nil {
+				return fmt.Errorf("%s: %s", o.name, err)
+			}
+			continue
+		}
+
+		if err := json.Unmarshal(v, o.v); err != nil {
+			return fmt.Errorf("%s: %s", o.name, err)
+		}
+	}
+
+	for k := range raw {
+		return fmt.Errorf("Unknown option %s", k)
+	}
+
+	return nil
+}
+
+func (o option) set(s string) (err error) {
+	switch v := o.v.(type) {
+	case *string:
+		*v = s
+	case *int:
+		*v, err = strconv.Atoi(s)
+	case *float64:
+		*v, err = strconv.ParseFloat(s, 64)
+	case *bool:
+		*v, err = strconv.ParseBool(s)
+	case *time.Duration:
+		*v, err = time.ParseDuration(s)
+	case *[]string:
+		*v = strings.FieldsFunc(s, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	default:
+		err = fmt.Errorf("%s can only be set in %s", o.name, File)
+	}
+
+	return
+}
+
+func (o option) fileOnly() bool {
+	switch o.v.(type) {
+	case *map[string][]string, *map[string]map[string]string:
+		return true
+	}
+
+	return false
+}
+
+// Set and String implement flag.Value.
+func (o option) Set(s string) error { return o.set(s) }
+
+func (o option) String() string {
+	switch v := o.v.(type) {
+	case nil:
+		return ""
+	case *[]string:
+		return strings.Join(*v, ",")
+	}
+
+	return fmt.Sprint(deref(o.v))
+}
+
+// IsBoolFlag allows -mpris instead of -mpris=true.
+func (o option) IsBoolFlag() bool {
+	_, ok := o.v.(*bool)
+	return ok
+}
+
+func deref(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *string:
+		return *v
+	case *[]string:
+		return *v
+	case *int:
+		return *v
+	case *float64:
+		return *v
+	case *bool:
+		return *v
+	case *time.Duration:
+		return *v
+	case *map[string][]string:
+		return *v
+	case *map[string]map[string]string:
+		return *v
+	}
+
+	return v
+}
+
+// Print writes the effective configuration in the format of File.
+func Print(w io.Writer) error {
+	buf := bytes.NewBufferString("{\n")
+	for i, o := range options {
+		v := deref(o.v)
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+
+		d, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if i == len(options)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(buf, "  %q: %s%s\n", o.name, d, sep)
+	}
+	buf.WriteString("}\n")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// xdg returns $env/ym or ~/fallback/ym if env is not set.
+func xdg(env string, fallback ...string) string {
+	if dir := os.Getenv(env); dir != "" {
+		return filepath.Join(dir, "ym")
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(append(append([]string{user.HomeDir}, fallback...), "ym")...)
+}
+
+// runtimeDir is where the socket goes, $XDG_RUNTIME_DIR/ym or CacheDir.
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ym")
+	}
+
+	return CacheDir
+}
+
+// Prepare creates DataDir, TempDir and the runtime directory and, once,
+// moves the user data that used to be stored in CacheDir or ~/.cache/ym
+// to DataDir.
+func Prepare() error {
+	dirs := []string{DataDir, TempDir, filepath.Dir(Socket), runtimeDir()}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	old := []string{CacheDir}
+	if user, err := user.Current(); err == nil {
+		old = append(old, filepath.Join(user.HomeDir, ".cache", "ym"))
+	}
+
+	for _, dir := range old {
+		for _, f := range [][2]string{
+			{filepath.Join(dir, "playlist"), Playlist},
+			{filepath.Join(dir, "playlist.link"), Playlist + ".link"},
+			{filepath.Join(dir, "playlist.linked"), Playlist + ".linked"},
+			{filepath.Join(dir, "positions"), Positions},
+			{filepath.Join(dir, "scrobbles"), Scrobbles},
+		} {
+			if err := migrate(f[0], f[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// migrate moves old to new unless new exists.
+func migrate(old, new string) error {
+	if old == new {
+		return nil
+	}
+	if _, err := os.Stat(old); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(new); !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(new), 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(old, new); err == nil {
+		return nil
+	}
+
+	// different filesystems
+	src, err := os.Open(old)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := new + ".migrate"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, new); err != nil {
+		return err
+	}
+
+	return os.Remove(old)
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"sync"
 	"time"
 
+	"github.com/frizinak/ym/audio"
 	"github.com/frizinak/ym/cache"
 	"github.com/frizinak/ym/cmd/config"
 	"github.com/frizinak/ym/playlist"
@@ -16,11 +18,11 @@ import (
 )
 
 func handle(workerIndex int, r search.Result, dls *cache.Cache) error {
-	us, err := r.DownloadURLs()
+	us, err := r.DownloadURLs(config.FormatPolicy())
 	if err != nil {
 		return err
 	}
-	u, err := us.Find(config.Preflights)
+	u, err := us.FindContext(context.Background(), config.Preflight())
 	if err != nil {
 		return err
 	}
@@ -53,24 +55,71 @@ func handle(workerIndex int, r search.Result, dls *cache.Cache) error {
 		)
 	}
 
-	return dls.SetProgress(cache.NewEntry(r.ID(), "mp4", u), progress)
+	e := cache.NewEntry(r.ID(), search.Ext(u), u)
+	if i, err := r.Info(); err == nil {
+		e.WithInfo(i)
+	}
+
+	return dls.SetProgress(e, progress)
+}
+
+func retag(workerIndex int, r search.Result, dls *cache.Cache) error {
+	i, err := r.Info()
+	if err != nil {
+		return err
+	}
+
+	return dls.Tag(r.ID(), i)
 }
 
 func main() {
-	e, _ := config.Extractor()
-	dls, err := cache.New(e, config.Downloads, filepath.Join(os.TempDir(), "ym"))
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "ym-cache [flags] [retag] [workers]")
+		flag.PrintDefaults()
+	}
+	if err := config.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := config.Prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	e, err := config.Extractor()
+	if err != nil && err != audio.ErrNoExtractor {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	dls, err := cache.New(e, config.Downloads, config.TempDir)
 	if err != nil {
 		panic(err)
 	}
+	if a, err := config.Analyzer(); err == nil {
+		dls.SetAnalyzer(a)
+	}
+	if t, err := config.Tagger(); err == nil {
+		dls.SetTagger(t)
+	}
+	if i, err := config.Inspector(); err == nil {
+		dls.SetInspector(i)
+	}
 
 	pl := playlist.New(config.Playlist, 100, nil)
 	if err := pl.Load(); err != nil {
 		panic(err)
 	}
 
+	// ym-cache [retag] [workers]
+	args := flag.Args()
+	h, cached := handle, false
+	if len(args) != 0 && args[0] == "retag" {
+		h, cached = retag, true
+		args = args[1:]
+	}
+
 	workers := runtime.NumCPU()
-	if len(os.Args) == 2 {
-		w, _ := strconv.Atoi(os.Args[1])
+	if len(args) == 1 {
+		w, _ := strconv.Atoi(args[0])
 		if w > 0 {
 			workers = w
 		}
@@ -98,7 +147,7 @@ func main() {
 		wg.Add(1)
 		go func(i int) {
 			for r := range work {
-				if err := handle(i, r, dls); err != nil {
+				if err := h(i, r, dls); err != nil {
 					fmt.Fprintf(
 						os.Stderr,
 						"\033[30;41m ERR: %s \n %s \n %s \033[0m\n",
@@ -117,7 +166,7 @@ func main() {
 	fmt.Printf("\033[2;J")
 	for _, e := range list {
 		r := e.Result()
-		if dls.Get(r.ID()) != nil {
+		if (dls.Get(r.ID()) != nil) != cached {
 			done <- struct{}{}
 			continue
 		}
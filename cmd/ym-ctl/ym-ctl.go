@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/frizinak/ym/cmd/config"
+	"github.com/frizinak/ym/ym"
+)
+
+func usage() {
+	fmt.Println("Control a running ym (see ym -daemon)")
+	fmt.Println("ym-ctl [flags] <command> [args]")
+	for _, l := range [][2]string{
+		{"status", "now playing as json"},
+		{"list", "queue as json"},
+		{"search <query>", "search results as json"},
+		{"add <id|url>", "add a video to the queue"},
+		{"del [index]", "delete the item at index, or clear the queue"},
+		{"move <from> <to>", "move an item in the queue"},
+		{"play [index]", "resume or play the item at index"},
+		{"pause", "pause"},
+		{"toggle", "pause / play"},
+		{"next, prev", "next or previous item"},
+		{"seek <pos>", "seek to pos (e.g.: 90, 1m30s) or relative (e.g.: +10, -10)"},
+		{"volume <delta>", "change the volume by delta steps"},
+		{"sync [id|url|off]", "link the queue to a youtube playlist and sync it"},
+		{"cmd <command>", "any command ym accepts (e.g.: ':sleep 30m')"},
+		{"events", "stream events"},
+	} {
+		fmt.Printf("  %-20s %s\n", l[0], l[1])
+	}
+	fmt.Println("flags (see ym -h):")
+	flag.PrintDefaults()
+}
+
+func exit(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+func intArg(args []string, i int) int {
+	if len(args) <= i {
+		exit(fmt.Errorf("Missing argument"))
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil {
+		exit(err)
+	}
+	return n
+}
+
+// videoID returns the v parameter of a watch url or s itself.
+func videoID(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	if strings.HasSuffix(u.Host, "youtu.be") {
+		return strings.Trim(u.Path, "/")
+	}
+
+	return u.Query().Get("v")
+}
+
+func main() {
+	flag.Usage = usage
+	if err := config.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		exit(err)
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(0)
+	}
+
+	c := ym.NewClient("unix", config.Socket, "")
+	cmd, args := args[0], args[1:]
+	rest := strings.Join(args, " ")
+
+	var v interface{}
+	var err error
+	switch cmd {
+	case "status":
+		v, err = c.Status()
+	case "list":
+		v, err = c.Playlist()
+	case "search":
+		v, err = c.Search(rest, 0)
+	case "add":
+		v, err = c.Add(videoID(rest), "")
+	case "del":
+		if len(args) == 0 {
+			err = c.Do(http.MethodDelete, "/playlist", nil, nil)
+			break
+		}
+		err = c.Do(http.MethodDelete, "/playlist?index="+strconv.Itoa(intArg(args, 0)), nil, nil)
+	case "move":
+		err = c.Do(
+			http.MethodPost,
+			"/playlist/move",
+			map[string]int{"from": intArg(args, 0), "to": intArg(args, 1)},
+			nil,
+		)
+	case "play":
+		var body interface{}
+		if len(args) != 0 {
+			body = map[string]int{"index": intArg(args, 0)}
+		}
+		err = c.Do(http.MethodPost, "/play", body, nil)
+	case "pause":
+		err = c.Do(http.MethodPost, "/pause", nil, nil)
+	case "toggle":
+		err = c.Command(".")
+	case "next":
+		err = c.Do(http.MethodPost, "/next", nil, nil)
+	case "prev":
+		err = c.Do(http.MethodPost, "/prev", nil, nil)
+	case "seek":
+		if strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "-") {
+			n, perr := strconv.ParseFloat(rest, 64)
+			if perr != nil {
+				exit(perr)
+			}
+			err = c.Do(http.MethodPost, "/seek", map[string]float64{"relative": n}, nil)
+			break
+		}
+		err = c.Command(":seek " + rest)
+	case "volume":
+		err = c.Do(http.MethodPost, "/volume", map[string]int{"delta": intArg(args, 0)}, nil)
+	case "sync":
+		v, err = c.Sync(rest)
+	case "cmd":
+		err = c.Command(rest)
+	case "events":
+		err = c.Events(context.Background(), func(event string, data []byte) error {
+			fmt.Printf("%s %s\n", event, data)
+			return nil
+		})
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		exit(err)
+	}
+
+	if v != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			exit(err)
+		}
+	}
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/frizinak/ym/audio"
 	"github.com/frizinak/ym/cache"
 	"github.com/frizinak/ym/cmd/config"
 	"github.com/frizinak/ym/playlist"
@@ -15,20 +19,45 @@ import (
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	split := flag.Bool("split", false, "split items with chapters into a directory of tracks")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Hardlink all cached items to the provided directory")
+		fmt.Fprintln(flag.CommandLine.Output(), "ym-files [flags] <dir>")
+		flag.PrintDefaults()
+	}
+	if err := config.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := config.Prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	args := flag.Args()
+	if len(args) < 1 {
 		fmt.Fprintln(os.Stderr, "Specify a directory to hardlink cached items to.")
 		os.Exit(1)
 	}
 
-	path := os.Args[1]
-	if path == "-h" || path == "--help" {
-		fmt.Println("Hardlink all cached items to the provided directory")
-		os.Exit(0)
+	path := args[0]
+
+	var splitter audio.Splitter
+	if *split {
+		var err error
+		if splitter, err = config.Splitter(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
 
 	fn := regexp.MustCompile("[\\/:*?\"<>|\\0]+")
-	e, _ := config.Extractor()
-	dls, err := cache.New(e, config.Downloads, filepath.Join(os.TempDir(), "ym"))
+	e, err := config.Extractor()
+	if err != nil && err != audio.ErrNoExtractor {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	dls, err := cache.New(e, config.Downloads, config.TempDir)
 	if err != nil {
 		panic(err)
 	}
@@ -66,6 +95,13 @@ func main() {
 				if c == nil {
 					continue
 				}
+				if splitter != nil {
+					trimmed := dls.Meta(r.ID()).Trimmed
+					if err := splitChapters(splitter, r, c.Path(), path, trimmed, clean); err != nil {
+						fmt.Fprintf(os.Stderr, "\n%s: %s\n", r.Title(), err)
+					}
+				}
+
 				hardlink := c.Path()
 				symlink := filepath.Join(path, clean(r.Title())+filepath.Ext(hardlink))
 				if err := os.Link(hardlink, symlink); err != nil && !os.IsExist(err) {
@@ -87,3 +123,53 @@ func main() {
 	fmt.Println("\ndone")
 
 }
+
+// splitChapters cuts file, which had trimmed removed from its start, into
+// a directory of tracks if r has chapters.
+func splitChapters(
+	s audio.Splitter,
+	r search.Result,
+	file, path string,
+	trimmed time.Duration,
+	clean func(string) string,
+) error {
+	info, err := r.Info()
+	if err != nil {
+		return err
+	}
+
+	chapters, err := search.FetchChapters(context.Background(), info)
+	if err != nil {
+		return err
+	}
+	chapters = search.ShiftChapters(chapters, trimmed)
+	if len(chapters) == 0 {
+		return nil
+	}
+
+	dir := filepath.Join(path, clean(r.Title()))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for i, ch := range chapters {
+		dest := filepath.Join(
+			dir,
+			fmt.Sprintf("%02d %s%s", i+1, clean(ch.Title), filepath.Ext(file)),
+		)
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+
+		var end time.Duration
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+
+		if err := s.Cut(file, dest, ch.Start, end); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
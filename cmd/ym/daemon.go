@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/frizinak/ym/cmd/config"
+)
+
+// daemon runs a session without a ui until it receives SIGINT or SIGTERM.
+func daemon() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	out := newOutput()
+	s, err := newSession(out)
+	if err != nil {
+		return err
+	}
+	if err := s.listen(config.Socket); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-signals:
+			s.Close()
+			return nil
+		case err := <-out.errs:
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		case st := <-out.titles:
+			fmt.Println(st.msg)
+		case <-out.current:
+		case <-out.state:
+		case <-out.pos:
+		case <-out.volume:
+		case <-out.sleep:
+		case <-out.chapter:
+		case <-out.playlist:
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	termbox "github.com/nsf/termbox-go"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/keymap"
+)
+
+// Modes of the prompt, in insert mode unbound keys are typed and
+// single character bindings only apply to an empty prompt.
+const (
+	modeInsert = "insert"
+	modeNormal = "normal"
+)
+
+// actions that act on the prompt, anything else is run as a command
+// (e.g.: ":shuffle", ">"). insert takes the text to type as an argument
+// (e.g.: "insert :").
+var actions = map[string]string{
+	"submit":      "run the prompt",
+	"clear":       "clear the prompt",
+	"backspace":   "delete the last character",
+	"insert":      "insert mode",
+	"normal":      "normal mode",
+	"scroll-up":   "scroll up (single item)",
+	"scroll-down": "scroll down (single item)",
+	"page-up":     "scroll up (half page)",
+	"page-down":   "scroll down (half page)",
+}
+
+var commandHelp = map[string]string{
+	">":        "next song",
+	"<":        "previous song",
+	"]":        "seek forward",
+	"[":        "seek backward",
+	"}":        "next chapter (or song)",
+	"{":        "previous chapter",
+	".":        "pause / play",
+	":vol +1":  "volume up",
+	":vol -1":  "volume down",
+	":exit":    "quit",
+	":queue":   "open queue",
+	":back":    "previous search",
+	":forward": "next search",
+	":help":    "help",
+	":shuffle": "toggle shuffle",
+	":repeat":  "toggle repeating the queue",
+	":restart": "restart current song",
+}
+
+var defaultKeys = map[string]map[string]string{
+	modeInsert: {
+		"<Enter>": "submit",
+		"<BS>":    "backspace",
+		"<C-c>":   "clear",
+		"<Esc>":   "normal",
+		"<C-q>":   ":exit",
+		"<C-k>":   "scroll-up",
+		"<C-j>":   "scroll-down",
+		"<C-u>":   "page-up",
+		"<C-d>":   "page-down",
+		"<Up>":    ":vol +1",
+		"<Down>":  ":vol -1",
+		"<Left>":  "<",
+		"<Right>": ">",
+		"<":       "<",
+		">":       ">",
+		"[":       "[",
+		"]":       "]",
+		"{":       "{",
+		"}":       "}",
+		".":       ".",
+		"<Space>": ".",
+	},
+	modeNormal: {
+		"i":       "insert",
+		"a":       "insert",
+		":":       "insert :",
+		"/":       "insert /",
+		"!":       "insert !",
+		"<Esc>":   "normal",
+		"<C-q>":   ":exit",
+		"ZZ":      ":exit",
+		"k":       "scroll-up",
+		"j":       "scroll-down",
+		"<C-k>":   "scroll-up",
+		"<C-j>":   "scroll-down",
+		"<C-u>":   "page-up",
+		"<C-d>":   "page-down",
+		"+":       ":vol +1",
+		"-":       ":vol -1",
+		"<Up>":    ":vol +1",
+		"<Down>":  ":vol -1",
+		"h":       "<",
+		"l":       ">",
+		"<Left>":  "<",
+		"<Right>": ">",
+		"[":       "[",
+		"]":       "]",
+		"{":       "{",
+		"}":       "}",
+		".":       ".",
+		"<Space>": ".",
+		"s":       ":shuffle",
+		"r":       ":repeat",
+		"R":       ":restart",
+		"gq":      ":queue",
+		"gb":      ":back",
+		"gf":      ":forward",
+		"?":       ":help",
+	},
+}
+
+func init() {
+	for i := '1'; i <= '9'; i++ {
+		defaultKeys[modeNormal][string(i)] = "insert " + string(i)
+	}
+}
+
+func splitAction(action string) (name, arg string) {
+	if i := strings.IndexByte(action, ' '); i != -1 {
+		return action[:i], action[i+1:]
+	}
+
+	return action, ""
+}
+
+func validAction(action string) bool {
+	if name, _ := splitAction(action); actions[name] != "" {
+		return true
+	}
+
+	return !command.New([]rune(action)).IsText()
+}
+
+// newKeymap creates the keymap starting in mode with keys bound over
+// the defaults.
+func newKeymap(mode string, keys map[string]map[string]string) (*keymap.Keymap, error) {
+	if _, ok := defaultKeys[mode]; !ok {
+		return nil, fmt.Errorf("Unknown key mode %s", mode)
+	}
+
+	km := keymap.New(mode)
+	for m, bindings := range defaultKeys {
+		for seq, action := range bindings {
+			if err := km.Bind(m, seq, action); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for m, bindings := range keys {
+		if _, ok := defaultKeys[m]; !ok {
+			return nil, fmt.Errorf("Unknown key mode %s", m)
+		}
+		for seq, action := range bindings {
+			if action != "" && !validAction(action) {
+				return nil, fmt.Errorf("Unknown action %s for %s", action, seq)
+			}
+			if err := km.Bind(m, seq, action); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return km, nil
+}
+
+func describe(action string) string {
+	name, arg := splitAction(action)
+	switch {
+	case name == "insert" && arg != "":
+		return fmt.Sprintf("insert mode, type %s", arg)
+	case actions[name] != "":
+		return actions[name]
+	case commandHelp[action] != "":
+		return commandHelp[action]
+	}
+
+	return "run " + action
+}
+
+// keyHelp lists the bindings of all modes of km.
+func keyHelp(km *keymap.Keymap) []string {
+	var help []string
+	for _, mode := range km.Modes() {
+		title := strings.ToUpper(mode) + " MODE"
+		if mode == modeInsert {
+			title += " (single characters on an empty prompt)"
+		}
+		help = append(help, title, "")
+
+		// keys that type themselves share a line
+		group := make(map[string][]string)
+		for action, seqs := range km.Bindings(mode) {
+			for _, seq := range seqs {
+				d := describe(action)
+				if action == "insert "+seq {
+					d = "insert mode, type the key"
+				}
+				group[d] = append(group[d], seq)
+			}
+		}
+
+		lines := make([]string, 0, len(group))
+		for d, seqs := range group {
+			sort.Strings(seqs)
+			lines = append(lines, fmt.Sprintf("%-20s %s", strings.Join(seqs, ", "), d))
+		}
+		sort.Strings(lines)
+		help = append(help, lines...)
+		help = append(help, "")
+	}
+
+	return help
+}
+
+var termKeys = map[termbox.Key]string{
+	termbox.KeyEnter:      "<Enter>",
+	termbox.KeyTab:        "<Tab>",
+	termbox.KeyEsc:        "<Esc>",
+	termbox.KeyBackspace:  "<BS>",
+	termbox.KeyBackspace2: "<BS>",
+	termbox.KeySpace:      "<Space>",
+	termbox.KeyDelete:     "<Del>",
+	termbox.KeyInsert:     "<Insert>",
+	termbox.KeyArrowUp:    "<Up>",
+	termbox.KeyArrowDown:  "<Down>",
+	termbox.KeyArrowLeft:  "<Left>",
+	termbox.KeyArrowRight: "<Right>",
+	termbox.KeyHome:       "<Home>",
+	termbox.KeyEnd:        "<End>",
+	termbox.KeyPgup:       "<PageUp>",
+	termbox.KeyPgdn:       "<PageDown>",
+}
+
+func init() {
+	for i := termbox.Key(0); i < 12; i++ {
+		termKeys[termbox.KeyF1-i] = fmt.Sprintf("<F%d>", i+1)
+	}
+}
+
+// keyName returns the keymap name of the key pressed in e.
+func keyName(e termbox.Event) string {
+	if e.Ch != 0 {
+		return keymap.Char(e.Ch)
+	}
+	if n, ok := termKeys[e.Key]; ok {
+		return n
+	}
+	if e.Key >= termbox.KeyCtrlA && e.Key <= termbox.KeyCtrlZ {
+		return keymap.Ctrl(rune('a' + e.Key - termbox.KeyCtrlA))
+	}
+
+	return ""
+}
+
+// char returns the character typed by key.
+func char(key string) (rune, bool) {
+	if key == "<Space>" {
+		return ' ', true
+	}
+
+	r, n := utf8.DecodeRuneInString(key)
+	return r, n != 0 && n == len(key)
+}
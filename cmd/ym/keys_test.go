@@ -0,0 +1,63 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyHelp(t *testing.T) {
+	km, err := newKeymap(modeNormal, map[string]map[string]string{
+		modeNormal: {"gq": "", "Q": ":exit"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	help := keyHelp(km)
+
+	if help[0] != "NORMAL MODE" {
+		t.Errorf("expected the initial mode first, got %q", help[0])
+	}
+	if !contains(help, "INSERT MODE (single characters on an empty prompt)") {
+		t.Error("insert mode is missing")
+	}
+
+	for _, l := range []string{
+		"!, /, 1, 2, 3, 4, 5, 6, 7, 8, 9, : insert mode, type the key",
+		"<C-q>, Q, ZZ         quit",
+		"<C-j>, j             scroll down (single item)",
+		"gb                   previous search",
+		"a, i                 insert mode",
+		"<C-c>                clear the prompt",
+	} {
+		if !contains(help, l) {
+			t.Errorf("missing %q", l)
+		}
+	}
+
+	for _, l := range help {
+		if strings.HasPrefix(l, "gq ") {
+			t.Errorf("unbound key listed: %s", l)
+		}
+	}
+}
+
+func TestNewKeymapInvalid(t *testing.T) {
+	for _, keys := range []map[string]map[string]string{
+		{"visual": {"v": "normal"}},
+		{modeNormal: {"v": "visual"}},
+	} {
+		if _, err := newKeymap(modeNormal, keys); err == nil {
+			t.Errorf("%v: expected an error", keys)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
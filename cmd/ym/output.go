@@ -10,6 +10,7 @@ import (
 	termbox "github.com/nsf/termbox-go"
 
 	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/keymap"
 	"github.com/frizinak/ym/player"
 	"github.com/frizinak/ym/playlist"
 	"github.com/frizinak/ym/search"
@@ -80,12 +81,37 @@ func printSeeker(posChan <-chan *player.Pos, statusChan <-chan string) {
 	}
 }
 
+func sleepString(remaining time.Duration, atEnd, ok bool) string {
+	switch {
+	case !ok:
+		return ""
+	case atEnd && remaining < 0:
+		return "⏾ end"
+	case remaining < 0:
+		remaining = 0
+	}
+
+	return "⏾ " + durationString(remaining, remaining.Hours() >= 1)
+}
+
+func chapterString(title string, index, total int, ok bool) string {
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("§ %d/%d %s", index+1, total, title)
+}
+
 func printStatus(
 	q <-chan *status,
 	r <-chan search.Result,
 	v <-chan int,
+	sl <-chan string,
+	ch <-chan string,
 ) {
 	var volume int
+	var sleep string
+	var chapter string
 	var lstatus string
 	lstatusChan := make(chan string)
 	var result search.Result
@@ -101,6 +127,13 @@ func printStatus(
 			lstatus = "-"
 		}
 
+		if chapter != "" {
+			title = fmt.Sprintf("[%s] %s", chapter, title)
+		}
+		if sleep != "" {
+			title = fmt.Sprintf("[%s] %s", sleep, title)
+		}
+
 		left := fmt.Sprintf(" %s ", strings.TrimSpace(lstatus))
 		right := fmt.Sprintf(" [🔊%d%%] %s ", volume, title)
 		lw := runewidth.StringWidth(left)
@@ -149,6 +182,10 @@ func printStatus(
 			print()
 		case volume = <-v:
 			print()
+		case sleep = <-sl:
+			print()
+		case chapter = <-ch:
+			print()
 		}
 	}
 }
@@ -187,7 +224,7 @@ func printResults(c <-chan []search.Result) {
 	}
 }
 
-func printPlaylist(pl *playlist.Playlist, c <-chan struct{}) {
+func printPlaylist(pl *playlist.Playlist, c <-chan struct{}, partial func(id string) bool) {
 	for range c {
 		w, h := termSize()
 		h -= 4
@@ -202,23 +239,29 @@ func printPlaylist(pl *playlist.Playlist, c <-chan struct{}) {
 		pad := intLen + 3
 
 		for i := range results {
+			lbl, lblW := "", 0
+			if partial(results[i].ID()) {
+				lbl, lblW = "\033[30;43m ◔ \033[0m ", 4
+			}
+
 			title := runewidth.Truncate(
 				results[i].Title(),
-				w-pad,
+				w-pad-lblW,
 				"…",
 			)
 
 			if ix == i {
 				title = fmt.Sprintf(
-					"\033[30;42m%-"+strconv.Itoa(w-pad)+"s\033[0m",
+					"\033[30;42m%-"+strconv.Itoa(w-pad-lblW)+"s\033[0m",
 					title,
 				)
 			}
 
 			fmt.Printf(
-				"\033[%d;0f\033[K\033[1;41m %0"+strconv.Itoa(intLen)+"d \033[0m %s\n",
+				"\033[%d;0f\033[K\033[1;41m %0"+strconv.Itoa(intLen)+"d \033[0m %s%s\n",
 				i+2,
 				offset+i+1,
+				lbl,
 				title,
 			)
 		}
@@ -252,7 +295,8 @@ func printInfo(c <-chan search.Info) {
 		h -= 3
 		for j, f := range formats {
 			items[j+5] = fmt.Sprintf(
-				"%s: %s | %s: %d kbps",
+				"%s %s: %s | %s: %d kbps",
+				f.Extension,
 				f.VideoEncoding,
 				f.Resolution,
 				f.AudioEncoding,
@@ -273,13 +317,13 @@ func printInfo(c <-chan search.Info) {
 	}
 }
 
-func printHelp(version, player, encoder string, c <-chan struct{}) {
-	help := command.Help()
-	all := make([]string, len(help)+2)
-	all[0] = fmt.Sprintf("Version: %s [player: %s, encoder: %s]", version, player, encoder)
-	for i := range help {
-		all[i+2] = help[i]
+func printHelp(version, player, encoder string, keys []string, c <-chan struct{}) {
+	all := []string{
+		fmt.Sprintf("Version: %s [player: %s, encoder: %s]", version, player, encoder),
+		"",
 	}
+	all = append(all, keys...)
+	all = append(all, command.Help()...)
 	amount := len(all) + 1
 
 	for range c {
@@ -340,18 +384,64 @@ func closeTerm() {
 	termbox.Close()
 }
 
-func prompt(c *command.Command) (*command.Command, error) {
+// prompt reads keys until a command is complete. Keys are looked up in km,
+// actions that don't act on the prompt itself are returned as commands.
+func prompt(km *keymap.Keymap, c *command.Command) (*command.Command, error) {
 	if c == nil || c.Done() {
 		c = command.New(make([]rune, 0, 1))
 	}
+	if len(c.Buffer()) != 0 {
+		km.SetMode(modeInsert)
+	}
 
 	_, height := termbox.Size()
 	print := func(str string) {
-		termbox.SetCursor(2+runewidth.StringWidth(str), height-2)
-		fmt.Printf("\033[%d;0f> \033[K%s\033[s", height-1, str)
+		prefix := "> "
+		if km.Mode() != modeInsert {
+			prefix, str = "-- "+km.Mode()+" -- ", km.Pending()
+		}
+		termbox.SetCursor(runewidth.StringWidth(prefix+str), height-2)
+		fmt.Printf("\033[%d;0f%s\033[K%s\033[s", height-1, prefix, str)
 		termbox.Flush()
 	}
 
+	scroll := func(n int) *command.Command {
+		return command.New([]rune(fmt.Sprintf(":scroll %d", n))).SetDone()
+	}
+
+	run := func(action string) *command.Command {
+		name, arg := splitAction(action)
+		switch name {
+		case "submit":
+			km.Reset()
+			return c.SetDone()
+		case "clear":
+			c.Truncate()
+		case "backspace":
+			c.Pop()
+		case "insert":
+			km.SetMode(modeInsert)
+			for _, r := range arg {
+				c.Append(r)
+			}
+		case "normal":
+			km.SetMode(modeNormal)
+			c.Truncate()
+		case "scroll-up":
+			return scroll(-1)
+		case "scroll-down":
+			return scroll(1)
+		case "page-up":
+			return scroll(-height / 2)
+		case "page-down":
+			return scroll(height / 2)
+		default:
+			return command.New([]rune(action)).SetDone()
+		}
+
+		return nil
+	}
+
 	print(c.String())
 	for {
 		e := termbox.PollEvent()
@@ -359,60 +449,36 @@ func prompt(c *command.Command) (*command.Command, error) {
 		case termbox.EventInterrupt:
 			return command.New([]rune(":exit")), nil
 		case termbox.EventKey:
-			switch e.Key {
-			case termbox.KeyCtrlQ:
-				return command.New([]rune(":exit")).SetDone(), nil
-
-			case termbox.KeyCtrlK:
-				return command.New([]rune(fmt.Sprintf(":scroll %d", -1))).SetDone(), nil
-
-			case termbox.KeyCtrlJ:
-				return command.New([]rune(fmt.Sprintf(":scroll %d", 1))).SetDone(), nil
-
-			case termbox.KeyCtrlU:
-				return command.New([]rune(fmt.Sprintf(":scroll %d", -height/2))).SetDone(), nil
-
-			case termbox.KeyCtrlD:
-				return command.New([]rune(fmt.Sprintf(":scroll %d", height/2))).SetDone(), nil
-
-			case termbox.KeyBackspace, termbox.KeyBackspace2:
-				c.Pop()
-
-			case termbox.KeyCtrlC:
-				c.Truncate()
-
-			case termbox.KeyEnter:
-				e.Ch = 0
-				c.SetDone()
-
-			case termbox.KeySpace:
-				e.Ch = ' '
+			key := keyName(e)
+			if key == "" {
+				continue
+			}
 
-			case termbox.KeyArrowLeft:
-				if len(c.Buffer()) == 0 {
-					e.Ch = '<'
-				}
+			insert := km.Mode() == modeInsert
+			_, typed := char(key)
+			var action string
+			var unbound []string
+			if insert && typed && len(c.Buffer()) != 0 && km.Pending() == "" {
+				unbound = []string{key}
+			} else {
+				action, unbound = km.Press(key)
+			}
 
-			case termbox.KeyArrowRight:
-				if len(c.Buffer()) == 0 {
-					e.Ch = '>'
+			if insert {
+				for _, k := range unbound {
+					if r, ok := char(k); ok {
+						c.Append(r)
+					}
 				}
-
-			case termbox.KeyArrowUp:
-				return command.New([]rune(":vol +1")).SetDone(), nil
-
-			case termbox.KeyArrowDown:
-				return command.New([]rune(":vol -1")).SetDone(), nil
 			}
 
-			if e.Ch != 0 {
-				c.Append(e.Ch)
+			if action != "" {
+				if cmd := run(action); cmd != nil {
+					print("")
+					return cmd, nil
+				}
 			}
 
-			if c.Done() {
-				print("")
-				return c, nil
-			}
 			print(c.String())
 		case termbox.EventResize:
 			_, height = termbox.Size()
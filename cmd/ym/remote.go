@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/search"
+	"github.com/frizinak/ym/ym"
+)
+
+var stateStrings = map[string]string{
+	"play":  "▶",
+	"pause": "⏸",
+	"stop":  "■",
+}
+
+// remote is a session running in another process, the ui displays
+// a copy of its queue.
+type remote struct {
+	client   *ym.Client
+	out      *output
+	pl       *playlist.Playlist
+	commands chan func() error
+
+	sem     sync.Mutex
+	resume  map[string]bool
+	index   int
+	last    *ym.NowPlaying
+	current string
+	dur     time.Duration
+}
+
+func attach(client *ym.Client, out *output) *remote {
+	r := &remote{
+		client:   client,
+		out:      out,
+		pl:       playlist.New("", 100, out.playlist),
+		commands: make(chan func() error, 100),
+		resume:   make(map[string]bool),
+	}
+
+	go func() {
+		for fn := range r.commands {
+			if err := fn(); err != nil {
+				out.errs <- err
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			err := client.Events(context.Background(), r.event)
+			out.errs <- fmt.Errorf("Lost connection to ym: %s", err)
+			time.Sleep(time.Second * 2)
+		}
+	}()
+
+	return r
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func (r *remote) event(event string, data []byte) error {
+	switch event {
+	case "status":
+		n := &ym.NowPlaying{}
+		if err := json.Unmarshal(data, n); err != nil {
+			return err
+		}
+		r.status(n)
+	case "position":
+		var p struct {
+			Position float64 `json:"position"`
+		}
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		r.sem.Lock()
+		dur := r.dur
+		r.sem.Unlock()
+		r.out.pos <- &player.Pos{Cur: seconds(p.Position), Dur: dur}
+	case "playlist":
+		var items []*ym.Item
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		r.playlist(items)
+	}
+
+	return nil
+}
+
+func (r *remote) status(n *ym.NowPlaying) {
+	r.sem.Lock()
+	last := r.last
+	r.last = n
+	r.dur = seconds(n.Duration)
+	r.sem.Unlock()
+	if last == nil {
+		last = &ym.NowPlaying{Volume: -2}
+	}
+
+	var id string
+	var index int
+	if n.Current != nil {
+		id, index = n.Current.ID, n.Current.Index
+	}
+	if id != r.current {
+		r.current = id
+		var res search.Result
+		if n.Current != nil {
+			res = n.Current.Result()
+		}
+		r.out.current <- res
+	}
+
+	r.sem.Lock()
+	changed := index != r.index
+	r.index = index
+	r.sem.Unlock()
+	if changed {
+		r.pl.SetIndex(index)
+	}
+
+	if n.State != last.State {
+		r.out.state <- stateStrings[n.State]
+	}
+	if n.Volume != last.Volume {
+		r.out.volume <- n.Volume
+	}
+
+	var sleep string
+	if n.Sleep != nil {
+		sleep = sleepString(seconds(n.Sleep.Remaining), n.Sleep.AtEnd, true)
+	}
+	r.out.sleep <- sleep
+
+	var chapter string
+	if c := n.Chapter; c != nil {
+		chapter = chapterString(c.Title, c.Index, c.Total, true)
+	}
+	r.out.chapter <- chapter
+
+	if n.Current != nil {
+		r.out.pos <- &player.Pos{Cur: seconds(n.Position), Dur: r.dur}
+	}
+}
+
+func (r *remote) playlist(items []*ym.Item) {
+	list := make([]*command.Command, len(items))
+	resume := make(map[string]bool)
+	for i, it := range items {
+		list[i] = command.New(nil).SetResult(it.Result())
+		if it.Resume {
+			resume[it.ID] = true
+		}
+	}
+
+	r.sem.Lock()
+	r.resume = resume
+	index := r.index
+	r.sem.Unlock()
+
+	r.pl.Replace(list)
+	r.pl.SetIndex(index)
+}
+
+func (r *remote) Playlist() *playlist.Playlist { return r.pl }
+
+func (r *remote) Send(cmd *command.Command) {
+	if y := cmd.Scroll(); y != 0 {
+		r.pl.Scroll(y)
+		return
+	}
+
+	str := cmd.String()
+	r.commands <- func() error { return r.client.Command(str) }
+}
+
+func (r *remote) Add(res search.Result) {
+	r.commands <- func() error {
+		_, err := r.client.Add(res.ID(), res.Title())
+		return err
+	}
+}
+
+func (r *remote) Sync(link string) (playlist.Diff, error) {
+	var diff playlist.Diff
+	res, err := r.client.Sync(link)
+	if err != nil {
+		return diff, err
+	}
+
+	for _, i := range res.Added {
+		diff.Added = append(diff.Added, i.Result())
+	}
+	for _, i := range res.Removed {
+		diff.Removed = append(diff.Removed, i.Result())
+	}
+
+	return diff, nil
+}
+
+func (r *remote) Resumable(id string) bool {
+	r.sem.Lock()
+	defer r.sem.Unlock()
+	return r.resume[id]
+}
+
+func (r *remote) Names() (string, string) {
+	return "remote", "remote"
+}
+
+func (r *remote) Close() {}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/frizinak/ym/audio"
+	"github.com/frizinak/ym/cache"
+	"github.com/frizinak/ym/cmd/config"
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/hooks"
+	"github.com/frizinak/ym/metrics"
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/resume"
+	"github.com/frizinak/ym/scrobble"
+	"github.com/frizinak/ym/search"
+	"github.com/frizinak/ym/ym"
+)
+
+// output is everything the ui displays, it is fed by either a local
+// session or a remote one.
+type output struct {
+	current  chan search.Result
+	state    chan string
+	pos      chan *player.Pos
+	volume   chan int
+	sleep    chan string
+	chapter  chan string
+	errs     chan error
+	titles   chan *status
+	playlist chan struct{}
+}
+
+func newOutput() *output {
+	return &output{
+		current:  make(chan search.Result),
+		state:    make(chan string),
+		pos:      make(chan *player.Pos),
+		volume:   make(chan int),
+		sleep:    make(chan string),
+		chapter:  make(chan string),
+		errs:     make(chan error),
+		titles:   make(chan *status, 100),
+		playlist: make(chan struct{}, 1),
+	}
+}
+
+// backend is what the ui controls.
+type backend interface {
+	Playlist() *playlist.Playlist
+	// Send handles a command the way ym.YM.Play does.
+	Send(cmd *command.Command)
+	Add(r search.Result)
+	// Sync links the queue to link unless it is empty and syncs it,
+	// off removes the link.
+	Sync(link string) (playlist.Diff, error)
+	Resumable(id string) bool
+	Names() (player, extractor string)
+	Close()
+}
+
+// session owns the player, queue and cache, either in the ui process
+// or as a daemon.
+type session struct {
+	out       *output
+	pl        *playlist.Playlist
+	ym        *ym.YM
+	engine    search.Engine
+	player    player.Player
+	extractor audio.Extractor
+	positions *resume.Positions
+	cache     chan search.Result
+	priority  chan search.Result
+	queue     chan *command.Command
+	quit      chan struct{}
+	socket    net.Listener
+	sockPath  string
+	mpris     io.Closer
+}
+
+func getPlaylist(dataDir string, ch chan struct{}) (*playlist.Playlist, error) {
+	var e bool
+	if dataDir != "" {
+		_, err := os.Stat(config.Playlist)
+		if err == nil || !os.IsNotExist(err) {
+			e = true
+		}
+	}
+
+	pl := playlist.New(config.Playlist, 100, ch)
+	if e {
+		if err := pl.Load(); err != nil {
+			return pl, err
+		}
+	}
+
+	return pl, nil
+}
+
+func getCache(cacheDir string, e audio.Extractor) *cache.Cache {
+	dls, _ := cache.New(e, cacheDir, config.TempDir)
+	return dls
+}
+
+func newSession(out *output) (*session, error) {
+	yt, err := config.Engine()
+	if err != nil {
+		return nil, err
+	}
+
+	var reg *metrics.Registry
+	if config.MetricsAddr != "" {
+		reg = metrics.NewRegistry()
+	}
+	engine := search.NewEngineMetrics(reg).Measure("youtube", yt)
+
+	volumeChan := make(chan int)
+	playerSeekChan := make(chan *player.Pos)
+	p, err := config.Player(volumeChan, playerSeekChan)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := config.Extractor()
+	if err != nil && err != audio.ErrNoExtractor {
+		return nil, err
+	}
+	dls := getCache(config.Downloads, e)
+	dls.SetMetrics(reg)
+	if a, err := config.Analyzer(); err == nil {
+		dls.SetAnalyzer(a)
+	}
+	if t, err := config.Tagger(); err == nil {
+		dls.SetTagger(t)
+	}
+	if i, err := config.Inspector(); err == nil {
+		dls.SetInspector(i)
+	}
+
+	normalize, err := ym.ParseNormalize(config.Normalize)
+	if err != nil {
+		return nil, err
+	}
+	updates := make(chan struct{}, 1)
+	pl, err := getPlaylist(config.DataDir, updates)
+	if pl == nil {
+		return nil, err
+	}
+	if err != nil {
+		go func() {
+			out.errs <- errors.New("Could not load playlist " + err.Error())
+		}()
+	}
+
+	positions := resume.New(config.Positions)
+	if err := positions.Load(); err != nil {
+		return nil, err
+	}
+
+	s := &session{
+		out:       out,
+		pl:        pl,
+		engine:    engine,
+		player:    p,
+		extractor: e,
+		positions: positions,
+		cache:     make(chan search.Result, 2000),
+		priority:  make(chan search.Result, 100),
+		queue:     make(chan *command.Command, 100),
+		quit:      make(chan struct{}),
+	}
+
+	go func() {
+		for {
+			time.Sleep(time.Second * 5)
+			if err := pl.Save(true); err != nil {
+				panic(err)
+			}
+			if err := positions.Save(true); err != nil {
+				panic(err)
+			}
+		}
+	}()
+
+	var mpd *net.TCPAddr
+	if config.MPDAddr != "" {
+		if mpd, err = net.ResolveTCPAddr("tcp", config.MPDAddr); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < config.DownloadConcurrency; i++ {
+		go s.download(dls)
+	}
+
+	go func() {
+		for _, cmd := range pl.List() {
+			s.cache <- cmd.Result()
+		}
+	}()
+
+	s.ym = ym.New(
+		pl,
+		engine,
+		p,
+		dls,
+		mpd,
+		config.Preflight(),
+	)
+	s.ym.SetFormatPolicy(config.FormatPolicy())
+	s.ym.SetNormalization(normalize, config.NormalizeTarget)
+	s.ym.SetResume(positions, config.ResumeMin)
+
+	s.ym.SetSleepFade(config.SleepFade)
+	s.ym.SetGapless(config.Gapless, config.Crossfade)
+	var prefetch func(search.Result)
+	if config.PrefetchCache {
+		prefetch = s.prefetchResult
+	}
+	s.ym.SetPrefetch(config.Prefetch, prefetch)
+	s.ym.SetSync(config.PlaylistMax, config.SyncRemove)
+	s.ym.SetMetrics(reg)
+
+	go s.forward(s.ym.Events().Subscribe(10))
+
+	go func() {
+		var rev uint64
+		for range updates {
+			if r := pl.Revision(); r != rev {
+				rev = r
+				s.ym.Events().Publish(ym.PlaylistEvent{})
+				continue
+			}
+			// e.g.: scrolling, only redraw
+			select {
+			case s.out.playlist <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	go func() {
+		for pos := range playerSeekChan {
+			s.ym.SyncPosition(pos)
+		}
+	}()
+
+	go func() {
+		for v := range volumeChan {
+			s.ym.SyncVolume(v)
+		}
+	}()
+
+	if mpd != nil {
+		// ignore error
+		go s.ym.Listen()
+	}
+
+	go func() {
+		if err := s.ym.Play(s.queue, s.quit); err != nil {
+			out.errs <- err
+		}
+	}()
+
+	go func() {
+		var lastSleep, lastChapter string
+		for range time.Tick(time.Second) {
+			if str := sleepString(s.ym.Sleep()); str != lastSleep {
+				lastSleep = str
+				out.sleep <- str
+			}
+			if str := chapterString(s.ym.Chapter()); str != lastChapter {
+				lastChapter = str
+				out.chapter <- str
+			}
+		}
+	}()
+
+	if config.SyncInterval > 0 {
+		go func() {
+			for range time.Tick(config.SyncInterval) {
+				if !s.ym.Linked() {
+					continue
+				}
+				diff, err := s.Sync("")
+				if err != nil {
+					out.errs <- err
+					continue
+				}
+				out.titles <- &status{"Synced: " + diff.String(), time.Second * 5}
+			}
+		}()
+	}
+
+	if config.APIAddr != "" {
+		api := s.ym.API(config.APIToken, s.queue, s.cacheResult)
+		go func() {
+			if err := api.ListenAndServe(config.APIAddr); err != nil {
+				out.errs <- err
+			}
+		}()
+	}
+
+	if reg != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", reg)
+		go func() {
+			if err := http.ListenAndServe(config.MetricsAddr, mux); err != nil {
+				out.errs <- err
+			}
+		}()
+	}
+
+	if len(config.Hooks) != 0 {
+		h := hooks.New(config.Hooks, config.HookConcurrency, config.HookTimeout)
+		h.SetErrorHandler(func(err error) { out.errs <- err })
+		go s.ym.RunHooks(h)
+	}
+
+	if config.ScrobbleToken != "" {
+		sc := scrobble.New(
+			scrobble.NewListenBrainz(config.ScrobbleURL, config.ScrobbleToken, config.ScrobbleTimeout),
+			config.Scrobbles,
+		)
+		sc.SetErrorHandler(func(err error) { out.errs <- err })
+		if err := sc.Load(); err != nil {
+			return nil, err
+		}
+		go s.ym.Scrobble(sc)
+	}
+
+	if config.MPRIS {
+		// ignore error, there might be no session bus
+		s.mpris, _ = s.ym.ServeMPRIS(s.queue)
+	}
+
+	return s, nil
+}
+
+// download caches the results sent on s.priority and s.cache,
+// the former first.
+func (s *session) download(dls *cache.Cache) {
+	for {
+		var entry search.Result
+		select {
+		case entry = <-s.priority:
+		default:
+			select {
+			case entry = <-s.priority:
+			case entry = <-s.cache:
+			}
+		}
+
+		if entry == nil || dls.Get(entry.ID()) != nil {
+			continue
+		}
+
+		u, err := entry.DownloadURLs(config.FormatPolicy())
+		if err != nil {
+			continue
+		}
+		du, err := u.FindContext(context.Background(), config.Preflight())
+		if err != nil {
+			continue
+		}
+
+		e := cache.NewEntry(entry.ID(), search.Ext(du), du)
+		if i, err := entry.Info(); err == nil {
+			e.WithInfo(i)
+		}
+
+		if err := dls.Set(e); err != nil {
+			s.out.errs <- err
+		}
+	}
+}
+
+// forward feeds the output with events published by the player.
+func (s *session) forward(sub *ym.Subscription) {
+	for e := range sub.C {
+		switch e := e.(type) {
+		case ym.StateEvent:
+			s.out.state <- stateStrings[e.State.String()]
+		case ym.TrackEvent:
+			s.out.current <- e.Track
+		case ym.PositionEvent:
+			s.out.pos <- e.Pos
+		case ym.VolumeEvent:
+			s.out.volume <- e.Volume
+		case ym.PlaylistEvent:
+			select {
+			case s.out.playlist <- struct{}{}:
+			default:
+			}
+		case ym.ErrorEvent:
+			s.out.errs <- e.Err
+		}
+	}
+}
+
+func (s *session) cacheResult(r search.Result) {
+	s.cache <- r
+}
+
+// prefetchResult queues r to be downloaded before the rest, it is
+// dropped if too many are queued already.
+func (s *session) prefetchResult(r search.Result) {
+	select {
+	case s.priority <- r:
+	default:
+	}
+}
+
+// listen serves the api on a unix socket so ym-ctl and other uis
+// can control this session.
+func (s *session) listen(socket string) error {
+	if c, err := net.Dial("unix", socket); err == nil {
+		c.Close()
+		return errors.New("ym is already running")
+	}
+
+	// bind in a private directory so the socket is never reachable with
+	// the permissions of the umask, then move it into place
+	dir, err := ioutil.TempDir(filepath.Dir(socket), ".ym-sock-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	tmp := filepath.Join(dir, "ym.sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	os.Remove(socket)
+	if err := os.Rename(tmp, socket); err != nil {
+		l.Close()
+		return err
+	}
+
+	s.socket = l
+	s.sockPath = socket
+	go http.Serve(l, s.ym.API("", s.queue, s.cacheResult))
+	return nil
+}
+
+func (s *session) Playlist() *playlist.Playlist { return s.pl }
+
+func (s *session) Send(cmd *command.Command) { s.queue <- cmd }
+
+func (s *session) Add(r search.Result) {
+	s.cache <- r
+	s.pl.Add(command.New(nil).SetResult(r))
+}
+
+func (s *session) Sync(link string) (playlist.Diff, error) {
+	switch link {
+	case "":
+	case "off":
+		return playlist.Diff{}, s.ym.Link("")
+	default:
+		if err := s.ym.Link(link); err != nil {
+			return playlist.Diff{}, err
+		}
+	}
+
+	diff, err := s.ym.Sync()
+	for _, r := range diff.Added {
+		s.cache <- r
+	}
+
+	return diff, err
+}
+
+func (s *session) Resumable(id string) bool { return s.positions.Has(id) }
+
+func (s *session) Names() (string, string) {
+	return s.player.Name(), s.extractor.Name()
+}
+
+func (s *session) Close() {
+	s.quit <- struct{}{}
+	s.pl.Save(true)
+	s.positions.Save(true)
+	if s.socket != nil {
+		s.socket.Close()
+		os.Remove(s.sockPath)
+	}
+	if s.mpris != nil {
+		s.mpris.Close()
+	}
+}
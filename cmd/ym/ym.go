@@ -1,21 +1,17 @@
 package main
 
 import (
-	"errors"
+	"flag"
+	"fmt"
 	"math/rand"
 	"net"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"time"
 
-	"github.com/frizinak/ym/audio"
-	"github.com/frizinak/ym/cache"
 	"github.com/frizinak/ym/cmd/config"
 	"github.com/frizinak/ym/command"
 	"github.com/frizinak/ym/history"
-	"github.com/frizinak/ym/player"
-	"github.com/frizinak/ym/playlist"
 	"github.com/frizinak/ym/search"
 	"github.com/frizinak/ym/ym"
 )
@@ -29,114 +25,77 @@ const (
 
 var version = "unknown"
 
-func getPlaylist(cacheDir string, ch chan struct{}) (*playlist.Playlist, error) {
-	var e bool
-	if cacheDir != "" {
-		_, err := os.Stat(config.Playlist)
-		if err == nil || !os.IsNotExist(err) {
-			e = true
-		}
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	daemonMode := flag.Bool("daemon", false, "play in the background, control it with ym or ym-ctl")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "ym [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "config:", config.File)
+		flag.PrintDefaults()
+	}
+	if err := config.Load(flag.CommandLine, os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	pl := playlist.New(config.Playlist, 100, ch)
-	if e {
-		if err := pl.Load(); err != nil {
-			return pl, err
+	if *printConfig {
+		if err := config.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	return pl, nil
-}
-
-func getCache(cacheDir string, e audio.Extractor) *cache.Cache {
-	dls, _ := cache.New(e, cacheDir, filepath.Join(os.TempDir(), "ym"))
-	return dls
-}
-
-func main() {
-	rand.Seed(time.Now().UnixNano())
-	quit := make(chan struct{})
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
-
-	errChan := make(chan error)
+	if err := config.Prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	yt, err := search.NewYoutube(time.Second * 5)
-	if err != nil {
-		panic(err)
+	if *daemonMode {
+		if err := daemon(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	volumeChan := make(chan int)
-	seekChan := make(chan *player.Pos)
-	p, err := config.Player(volumeChan, seekChan)
+	km, err := newKeymap(config.KeyMode, config.Keys)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
-	e, _ := config.Extractor()
-	dls := getCache(config.Downloads, e)
-	playlistChan := make(chan struct{}, 1)
-	pl, err := getPlaylist(config.CacheDir, playlistChan)
-	if pl == nil {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	yt, err := config.Engine()
+	if err != nil {
 		panic(err)
 	}
 
-	go func() {
-		for {
-			time.Sleep(time.Second * 5)
-			if err := pl.Save(true); err != nil {
-				panic(err)
-			}
-		}
-	}()
-
-	cacheChan := make(chan search.Result, 2000)
-	go func() {
-		for entry := range cacheChan {
-			if entry == nil || dls.Get(entry.ID()) != nil {
-				continue
-			}
-
-			u, err := entry.DownloadURLs()
-			if err != nil {
-				continue
-			}
-			du, err := u.Find(config.Preflights)
-			if err != nil {
-				continue
-			}
-
-			err = dls.Set(cache.NewEntry(entry.ID(), "mp4", du))
-			if err != nil {
-				errChan <- err
-			}
+	out := newOutput()
+	var engine search.Engine = yt
+	var b backend
+	if c, err := net.Dial("unix", config.Socket); err == nil {
+		c.Close()
+		b = attach(ym.NewClient("unix", config.Socket, ""), out)
+	} else {
+		s, err := newSession(out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-	}()
-
-	go func() {
-		for _, cmd := range pl.List() {
-			cacheChan <- cmd.Result()
+		if err := s.listen(config.Socket); err != nil {
+			go func() {
+				out.errs <- err
+			}()
 		}
-	}()
-
-	if err != nil {
-		go func() {
-			errChan <- errors.New("Could not load playlist " + err.Error())
-		}()
+		b = s
+		engine = s.engine
 	}
-
-	ym := ym.New(
-		pl,
-		yt,
-		p,
-		dls,
-		&net.TCPAddr{IP: net.IP{127, 0, 0, 1}, Port: 6600},
-		config.Preflights,
-	)
-
-	// ignore error
-	go ym.Listen()
-	titleChan := make(chan *status, 100)
+	pl := b.Playlist()
 
 	if err := initTerm(); err != nil {
 		panic(err)
@@ -144,43 +103,37 @@ func main() {
 
 	go func() {
 		for range signals {
-			titleChan <- &status{msg: "Saving playlist and quitting"}
-			quit <- struct{}{}
-			pl.Save(true)
+			out.titles <- &status{msg: "Saving playlist and quitting"}
+			b.Close()
 			closeTerm()
 			os.Exit(0)
 		}
 	}()
 
 	var cmd *command.Command
-	history := history.New(20)
+	history := history.New(config.History)
 
-	playChan := make(chan *command.Command, 100)
-	currentChan := make(chan search.Result)
-
-	statusChan := make(chan string)
 	go func() {
-		err := ym.Play(
-			playChan,
-			currentChan,
-			statusChan,
-			errChan,
-			quit,
-		)
-		if err != nil {
-			closeTerm()
-			panic(err)
+		for err := range out.errs {
+			out.titles <- &status{"Error: " + err.Error(), time.Second * 5}
 		}
 	}()
 
-	go func() {
-		for err := range errChan {
-			titleChan <- &status{"Error: " + err.Error(), time.Second * 5}
+	syncQueue := func(link string) {
+		diff, err := b.Sync(link)
+		if err != nil {
+			out.errs <- err
+			return
 		}
-	}()
+		if link == "off" {
+			out.titles <- &status{"Unlinked", time.Second * 5}
+			return
+		}
+		out.titles <- &status{"Synced: " + diff.String(), time.Second * 5}
+	}
 
-	go printStatus(titleChan, currentChan, volumeChan)
-	go printSeeker(seekChan, statusChan)
+	go printStatus(out.titles, out.current, out.volume, out.sleep, out.chapter)
+	go printSeeker(out.pos, out.state)
 
 	resultsChan := make(chan []search.Result)
 	go printResults(resultsChan)
@@ -189,14 +142,15 @@ func main() {
 	go printInfo(infoChan)
 
 	playlistTriggerChan := make(chan struct{})
-	go printPlaylist(pl, playlistTriggerChan)
+	go printPlaylist(pl, playlistTriggerChan, b.Resumable)
 
 	helpTriggerChan := make(chan struct{})
-	go printHelp(version, p.Name(), e.Name(), helpTriggerChan)
+	playerName, extractorName := b.Names()
+	go printHelp(version, playerName, extractorName, keyHelp(km), helpTriggerChan)
 
 	view := ViewPlaylist
 	go func() {
-		for range playlistChan {
+		for range out.playlist {
 			if view == ViewPlaylist {
 				playlistTriggerChan <- struct{}{}
 			}
@@ -211,11 +165,11 @@ func main() {
 	for {
 		switch view {
 		case ViewPlaylist:
-			titleChan <- &status{msg: "Playlist"}
+			out.titles <- &status{msg: "Playlist"}
 			playlistTriggerChan <- struct{}{}
 		case ViewSearch:
 			title, r := history.Current()
-			titleChan <- &status{msg: title}
+			out.titles <- &status{msg: title}
 			resultsChan <- r
 		case ViewInfo:
 			if info == nil {
@@ -225,21 +179,21 @@ func main() {
 
 			i, err := info.Info()
 			if err != nil {
-				errChan <- err
+				out.errs <- err
 				view = ViewSearch
 				continue
 			}
 
 			infoChan <- i
-			titleChan <- &status{msg: "Info:" + info.Title()}
+			out.titles <- &status{msg: "Info:" + info.Title()}
 		case ViewHelp:
 			helpTriggerChan <- struct{}{}
-			titleChan <- &status{msg: "Help"}
+			out.titles <- &status{msg: "Help"}
 		}
 
 		//if len(cmds) == 0 {
 		//if cmd == nil {
-		if cmd, err = prompt(cmd); err != nil {
+		if cmd, err = prompt(km, cmd); err != nil {
 			closeTerm()
 			panic(err)
 		}
@@ -290,23 +244,36 @@ func main() {
 			}
 
 			view = ViewSearch
-			titleChan <- &status{msg: "Searching: " + qry}
-			r, err := ym.ExecSearch(qry, 60)
+			out.titles <- &status{msg: "Searching: " + qry}
+			r, err := search.Collect(engine, qry, 60)
 			if err != nil {
-				errChan <- err
+				out.errs <- err
 				continue
 			}
 			history.Write(qry, r)
 			continue
 		} else if u := cmd.URL(); u != "" {
 			view = ViewSearch
-			titleChan <- &status{msg: "Page: " + u}
-			r, err := ym.ExecPage(u)
+			out.titles <- &status{msg: "Page: " + u}
+			r, err := engine.Page(u, 0)
 			if err != nil {
-				errChan <- err
+				out.errs <- err
 				continue
 			}
-			history.Write("Page: "+u, r)
+			history.WriteMore("Page: "+u, r, func(page int) ([]search.Result, error) {
+				return engine.Page(u, page)
+			})
+			continue
+		} else if arg, ok := cmd.Sync(); ok {
+			out.titles <- &status{msg: "Syncing"}
+			go syncQueue(arg)
+			continue
+		} else if cmd.More() {
+			view = ViewSearch
+			out.titles <- &status{msg: "Loading more"}
+			if err := history.More(); err != nil {
+				out.errs <- err
+			}
 			continue
 		}
 
@@ -330,8 +297,8 @@ func main() {
 				r := cur[choice-1]
 				if r.IsPlayList() {
 					if len(choices) == 1 {
-						if cur, err = r.PlaylistResults(time.Second * 5); err != nil {
-							errChan <- err
+						if cur, err = r.PlaylistResults(time.Second*5, config.PlaylistMax); err != nil {
+							out.errs <- err
 							continue
 						}
 						history.Write("Playlist: "+r.Title(), cur)
@@ -339,8 +306,7 @@ func main() {
 					continue
 				}
 
-				cacheChan <- r
-				pl.Add(cmd.Clone().SetResult(r))
+				b.Add(r)
 			}
 			continue
 		case ViewPlaylist:
@@ -370,6 +336,6 @@ func main() {
 			continue
 		}
 
-		playChan <- cmd
+		b.Send(cmd)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/frizinak/ym/search"
 )
@@ -14,9 +15,9 @@ func Help() []string {
 		"",
 		"type a search query, press enter and switch to search view",
 		"",
-		fmt.Sprintf("%-28s clear prompt", "<C-c>"),
 		fmt.Sprintf("%-28s open queue", ":list, :queue, :playlist"),
-		fmt.Sprintf("%-28s quit", ":exit, :quit, :q, <C-q>"),
+		fmt.Sprintf("%-28s previous / next search", ":back, :forward"),
+		fmt.Sprintf("%-28s quit", ":exit, :quit, :q"),
 		"",
 		"SEARCH",
 		"",
@@ -24,23 +25,34 @@ func Help() []string {
 		fmt.Sprintf("%-20s add item at <index> to queue", "<index>"),
 		fmt.Sprintf("%-20s add items at <n>,<m>,... to queue", "<n>,<m>,..."),
 		fmt.Sprintf("%-20s add items in range <n>-<m> to queue", "<n>-<m>"),
+		fmt.Sprintf("%-20s list uploads of a channel (e.g.: !user/name)", "!<channel>"),
+		fmt.Sprintf("%-20s load more uploads of the channel", ":more"),
 		"",
 		"QUEUE",
 		"",
 		"type a / followed by a query to scroll to item in the queue",
 		"",
-		fmt.Sprintf("%-20s next song", ">, right arrow"),
-		fmt.Sprintf("%-20s previous song", "<, left arrow"),
+		fmt.Sprintf("%-20s next song", ">"),
+		fmt.Sprintf("%-20s previous song", "<"),
 		fmt.Sprintf("%-20s seek forward", "]"),
 		fmt.Sprintf("%-20s seek backward", "["),
-		fmt.Sprintf("%-20s pause / play", "., space"),
+		fmt.Sprintf("%-20s seek to <pos> (e.g.: 90, 1m30s)", ":seek <pos>"),
+		fmt.Sprintf("%-20s next chapter (or song)", "}"),
+		fmt.Sprintf("%-20s previous chapter", "{"),
+		fmt.Sprintf("%-20s pause / play", "."),
+		fmt.Sprintf("%-20s restart current song ignoring the remembered position", ":restart"),
+		fmt.Sprintf("%-20s fade out and pause after <duration> (e.g.: 30m)", ":sleep <duration>"),
+		fmt.Sprintf("%-20s fade out and stop after the current song", ":sleep end"),
+		fmt.Sprintf("%-20s cancel sleep timer", ":sleep off"),
+		fmt.Sprintf("%-20s toggle shuffle", ":shuffle, :random, :rand"),
+		fmt.Sprintf("%-20s toggle repeating the queue", ":repeat"),
+		fmt.Sprintf("%-20s link the queue to a youtube playlist and sync it", ":sync <id|url>"),
+		fmt.Sprintf("%-20s sync the queue with the linked playlist", ":sync"),
+		fmt.Sprintf("%-20s unlink the queue", ":sync off"),
 		fmt.Sprintf("%-20s information about item at <index>", ":<index>"),
 		fmt.Sprintf("%-20s move item at <from> in queue to <to>", ":move <from> <to>"),
 		fmt.Sprintf("%-20s delete item from queue at <index>", ":delete <index>"),
-		fmt.Sprintf("%-20s scroll up (single item)", "<C-k>"),
-		fmt.Sprintf("%-20s scroll down (single item)", "<C-j>"),
-		fmt.Sprintf("%-20s scroll up (half page)", "<C-u>"),
-		fmt.Sprintf("%-20s scroll down (half page)", "<C-d>"),
+		fmt.Sprintf("%-20s change the volume by <n> steps", ":vol <n>"),
 	}
 }
 
@@ -64,10 +76,9 @@ func (c *Command) Pop() *Command {
 	return c
 }
 
+// Done reports whether the command was submitted, see SetDone.
 func (c *Command) Done() bool {
-	return c.done ||
-		c.Next() || c.Prev() || c.Pause() ||
-		c.SeekForward() || c.SeekBack()
+	return c.done
 }
 
 func (c *Command) SetDone() *Command {
@@ -78,7 +89,8 @@ func (c *Command) SetDone() *Command {
 
 func (c *Command) IsText() bool {
 	return !(c.Next() || c.Prev() || c.Pause() ||
-		c.SeekForward() || c.SeekBack()) &&
+		c.SeekForward() || c.SeekBack() ||
+		c.NextChapter() || c.PrevChapter()) &&
 		(len(c.buf) == 0 || (c.buf[0] != ':' && c.buf[0] != '/' && c.buf[0] != '!')) &&
 		len(c.Choices()) == 0
 }
@@ -98,6 +110,9 @@ func (c *Command) Next() bool { return len(c.buf) == 1 && c.buf[0] == '>' }
 func (c *Command) SeekBack() bool    { return len(c.buf) == 1 && c.buf[0] == '[' }
 func (c *Command) SeekForward() bool { return len(c.buf) == 1 && c.buf[0] == ']' }
 
+func (c *Command) PrevChapter() bool { return len(c.buf) == 1 && c.buf[0] == '{' }
+func (c *Command) NextChapter() bool { return len(c.buf) == 1 && c.buf[0] == '}' }
+
 func (c *Command) Pause() bool {
 	return len(c.buf) == 1 && (c.buf[0] == '.' || c.buf[0] == ' ')
 }
@@ -201,15 +216,52 @@ func (c *Command) fields(start string, amount int) []string {
 	return s[1:]
 }
 
+// Seek returns the position of ':seek <seconds|duration>'.
+func (c *Command) Seek() (time.Duration, bool) {
+	s := strings.Fields(c.String())
+	if len(s) != 2 || s[0] != ":seek" {
+		return 0, false
+	}
+
+	if n, err := strconv.ParseFloat(s[1], 64); err == nil && n >= 0 {
+		return time.Duration(n * float64(time.Second)), true
+	}
+
+	d, err := time.ParseDuration(s[1])
+	if err != nil || d < 0 {
+		return 0, false
+	}
+
+	return d, true
+}
+
+// Sleep returns the argument of ':sleep <duration|end|off>'.
+func (c *Command) Sleep() string {
+	s := c.fields("sleep", 1)
+	if s == nil {
+		return ""
+	}
+
+	return s[0]
+}
+
 func (c *Command) Clear() bool {
 	return c.fields("clear", 0) != nil
 }
 
+func (c *Command) Restart() bool {
+	return c.String() == ":restart"
+}
+
 func (c *Command) Rand() bool {
 	str := c.String()
 	return str == ":rand" || str == ":random" || str == ":shuffle"
 }
 
+func (c *Command) Repeat() bool {
+	return c.String() == ":repeat"
+}
+
 func (c *Command) Playlist() bool {
 	str := c.String()
 	return str == ":list" || str == ":queue" || str == ":playlist"
@@ -230,6 +282,24 @@ func (c *Command) Exit() bool {
 	return str == ":exit" || str == ":q" || str == ":quit"
 }
 
+func (c *Command) More() bool {
+	return c.String() == ":more"
+}
+
+// Sync returns the argument of ':sync [id|url|off]' and whether
+// c is a sync command.
+func (c *Command) Sync() (string, bool) {
+	s := strings.Fields(c.String())
+	if len(s) == 0 || len(s) > 2 || s[0] != ":sync" {
+		return "", false
+	}
+	if len(s) == 1 {
+		return "", true
+	}
+
+	return s[1], true
+}
+
 func (c *Command) Help() bool {
 	str := c.String()
 	return str == ":h" || str == ":help"
@@ -6,6 +6,7 @@ replace github.com/rylio/ytdl v0.6.3-0.20200330092806-e979337a42fc => github.com
 
 require (
 	github.com/YouROK/go-mpv v0.0.0-20160721123233-ecdfd901e332
+	github.com/godbus/dbus/v5 v5.0.3
 	github.com/mattn/go-runewidth v0.0.9
 	github.com/nsf/termbox-go v0.0.0-20190624072549-eeb6cd0a1762
 	github.com/rs/zerolog v1.18.0 // indirect
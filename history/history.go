@@ -1,10 +1,24 @@
 package history
 
-import "github.com/frizinak/ym/search"
+import (
+	"errors"
+
+	"github.com/frizinak/ym/search"
+)
+
+// More fetches the given page of results.
+type More func(page int) ([]search.Result, error)
+
+var (
+	errNoMore    = errors.New("Nothing more to load")
+	errExhausted = errors.New("No more results")
+)
 
 type entry struct {
-	t string
-	r []search.Result
+	t    string
+	r    []search.Result
+	more More
+	page int
 }
 
 // History is not thread safe
@@ -26,6 +40,11 @@ func (h *History) Current() (string, []search.Result) {
 }
 
 func (h *History) Write(title string, r []search.Result) {
+	h.WriteMore(title, r, nil)
+}
+
+// WriteMore is Write for results that can be extended with More.
+func (h *History) WriteMore(title string, r []search.Result, more More) {
 	if h.i < len(h.h)-1 && h.h[h.i] != nil {
 		h.i++
 	}
@@ -36,7 +55,28 @@ func (h *History) Write(title string, r []search.Result) {
 		}
 	}
 
-	h.h[h.i] = &entry{title, r}
+	h.h[h.i] = &entry{title, r, more, 0}
+}
+
+// More appends the next page of results to the current entry.
+func (h *History) More() error {
+	e := h.h[h.i]
+	if e == nil || e.more == nil {
+		return errNoMore
+	}
+
+	r, err := e.more(e.page + 1)
+	if err != nil {
+		return err
+	}
+	if len(r) == 0 {
+		e.more = nil
+		return errExhausted
+	}
+
+	e.page++
+	e.r = append(e.r, r...)
+	return nil
 }
 
 func (h *History) Forward() {
@@ -0,0 +1,125 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// Events hooks can be configured for.
+const (
+	TrackStart      = "track-start"
+	TrackEnd        = "track-end"
+	Pause           = "pause"
+	Resume          = "resume"
+	PlaylistChanged = "playlist-changed"
+	Error           = "error"
+)
+
+const queueSize = 100
+
+type job struct {
+	event string
+	cmds  []string
+	env   func() map[string]string
+}
+
+// Hooks runs shell commands on events with at most concurrency of them
+// running at once, each is killed after timeout.
+// Events are dropped rather than waited for if too many are pending.
+//
+// Commands get YM_EVENT, YM_STATE (play, pause or stop) and
+// YM_PLAYLIST_LENGTH and, if something is playing, YM_ID, YM_TITLE,
+// YM_URL, YM_AUTHOR, YM_DURATION (seconds) and YM_FILE (the cached file).
+// Error hooks also get YM_ERROR.
+type Hooks struct {
+	cmds    map[string][]string
+	timeout time.Duration
+	jobs    chan job
+	errs    func(error)
+}
+
+// New starts concurrency workers that run cmds, a map of event to
+// shell commands.
+func New(cmds map[string][]string, concurrency int, timeout time.Duration) *Hooks {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	h := &Hooks{
+		cmds:    cmds,
+		timeout: timeout,
+		jobs:    make(chan job, queueSize),
+		errs:    func(error) {},
+	}
+
+	for i := 0; i < concurrency; i++ {
+		go h.work()
+	}
+
+	return h
+}
+
+// SetErrorHandler sets the function failing hooks are reported to.
+func (h *Hooks) SetErrorHandler(fn func(error)) {
+	h.errs = fn
+}
+
+// Run queues the hooks of event, env is called right before they run
+// and returns the variables passed to them (prefixed with YM_).
+// Run never blocks.
+func (h *Hooks) Run(event string, env func() map[string]string) {
+	cmds := h.cmds[event]
+	if len(cmds) == 0 {
+		return
+	}
+
+	select {
+	case h.jobs <- job{event, cmds, env}:
+	default:
+		h.errs(fmt.Errorf("Too many pending hooks, dropped %s", event))
+	}
+}
+
+func (h *Hooks) work() {
+	for j := range h.jobs {
+		env := os.Environ()
+		vars := j.env()
+		vars["EVENT"] = j.event
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env = append(env, "YM_"+k+"="+vars[k])
+		}
+
+		for _, c := range j.cmds {
+			if err := h.exec(c, env); err != nil {
+				h.errs(fmt.Errorf("Hook %s '%s' failed: %s", j.event, c, err))
+			}
+		}
+	}
+}
+
+func (h *Hooks) exec(c string, env []string) error {
+	ctx := context.Background()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", c)
+	cmd.Env = env
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.timeout)
+	}
+
+	return err
+}
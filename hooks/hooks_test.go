@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wait blocks until $YM_DIR/release exists or $YM_DIR is removed.
+const wait = `while [ -d "$YM_DIR" ] && [ ! -e "$YM_DIR/release" ]; do sleep 0.01; done`
+
+func testDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ym-hooks-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() {
+		release(t, dir)
+		os.RemoveAll(dir)
+	}
+}
+
+func release(t *testing.T, dir string) {
+	if err := ioutil.WriteFile(filepath.Join(dir, "release"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func env(dir string, n int) func() map[string]string {
+	return func() map[string]string {
+		return map[string]string{"DIR": dir, "N": strconv.Itoa(n)}
+	}
+}
+
+func started(t *testing.T, dir string) int {
+	m, err := filepath.Glob(filepath.Join(dir, "started-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(m)
+}
+
+func eventually(t *testing.T, what string, fn func() bool) {
+	timeout := time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(timeout) {
+			t.Fatal(what)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, done := testDir(t)
+	defer done()
+
+	h := New(map[string][]string{
+		TrackStart: {`echo "$YM_EVENT $YM_N" > "$YM_DIR/out"`},
+	}, 1, time.Second*5)
+	h.SetErrorHandler(func(err error) { t.Error(err) })
+
+	h.Run(TrackEnd, env(dir, 1))
+	h.Run(TrackStart, env(dir, 2))
+
+	var out []byte
+	eventually(t, "hook did not run", func() bool {
+		out, _ = ioutil.ReadFile(filepath.Join(dir, "out"))
+		return len(out) != 0
+	})
+	if s := strings.TrimSpace(string(out)); s != "track-start 2" {
+		t.Errorf("hook wrote %q", s)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir, done := testDir(t)
+	defer done()
+
+	errs := make(chan error, 1)
+	h := New(map[string][]string{Pause: {wait}}, 1, time.Millisecond*100)
+	h.SetErrorHandler(func(err error) { errs <- err })
+
+	h.Run(Pause, env(dir, 1))
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "timed out after 100ms") {
+			t.Errorf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("hook was not killed")
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	dir, done := testDir(t)
+	defer done()
+
+	const concurrency = 3
+	h := New(map[string][]string{
+		Resume: {`touch "$YM_DIR/started-$YM_N"; ` + wait + `; touch "$YM_DIR/done-$YM_N"`},
+	}, concurrency, time.Second*5)
+	h.SetErrorHandler(func(err error) { t.Error(err) })
+
+	for i := 0; i < concurrency+2; i++ {
+		h.Run(Resume, env(dir, i))
+	}
+
+	eventually(t, "hooks did not start", func() bool { return started(t, dir) == concurrency })
+	time.Sleep(time.Millisecond * 100)
+	if n := started(t, dir); n != concurrency {
+		t.Fatalf("%d hooks running, expected at most %d", n, concurrency)
+	}
+
+	release(t, dir)
+	eventually(t, "queued hooks did not run", func() bool {
+		m, _ := filepath.Glob(filepath.Join(dir, "done-*"))
+		return len(m) == concurrency+2
+	})
+}
+
+func TestQueueFull(t *testing.T) {
+	dir, done := testDir(t)
+	defer done()
+
+	var dropped int32
+	h := New(map[string][]string{PlaylistChanged: {wait}}, 1, time.Second*5)
+	h.SetErrorHandler(func(err error) {
+		if strings.HasPrefix(err.Error(), "Too many pending hooks") {
+			atomic.AddInt32(&dropped, 1)
+		}
+	})
+
+	// one job is taken by the worker, the rest fill the queue
+	start := time.Now()
+	for i := 0; i < queueSize+3; i++ {
+		h.Run(PlaylistChanged, env(dir, i))
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Run blocked for %s", d)
+	}
+
+	if n := atomic.LoadInt32(&dropped); n < 2 || n > 3 {
+		t.Errorf("%d hooks dropped, expected 2 or 3", n)
+	}
+}
@@ -0,0 +1,135 @@
+package keymap
+
+import (
+	"sort"
+	"strings"
+)
+
+const sep = "\x00"
+
+// Keymap maps keys and sequences of keys to actions, separately for each
+// mode. It is not thread safe.
+//
+// A binding fires as soon as its keys are pressed, so it shadows longer
+// bindings that start with the same keys.
+type Keymap struct {
+	// sequences are stored as their keys joined by sep
+	modes   map[string]map[string]string
+	initial string
+	mode    string
+	pending []string
+}
+
+// New creates an empty Keymap that starts in the initial mode.
+func New(initial string) *Keymap {
+	return &Keymap{
+		modes:   make(map[string]map[string]string),
+		initial: initial,
+		mode:    initial,
+	}
+}
+
+// Bind binds the key sequence seq (see Parse) in mode to action,
+// an empty action removes the binding.
+func (k *Keymap) Bind(mode, seq, action string) error {
+	keys, err := Parse(seq)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := k.modes[mode]; !ok {
+		k.modes[mode] = make(map[string]string)
+	}
+
+	s := strings.Join(keys, sep)
+	if action == "" {
+		delete(k.modes[mode], s)
+		return nil
+	}
+
+	k.modes[mode][s] = action
+	return nil
+}
+
+// Modes returns the names of all modes, the initial one first.
+func (k *Keymap) Modes() []string {
+	modes := []string{k.initial}
+	for m := range k.modes {
+		if m != k.initial {
+			modes = append(modes, m)
+		}
+	}
+	sort.Strings(modes[1:])
+
+	return modes
+}
+
+// Bindings returns the bindings of mode, action to key sequences.
+func (k *Keymap) Bindings(mode string) map[string][]string {
+	b := make(map[string][]string)
+	for seq, action := range k.modes[mode] {
+		b[action] = append(b[action], strings.Replace(seq, sep, "", -1))
+	}
+	for action := range b {
+		sort.Strings(b[action])
+	}
+
+	return b
+}
+
+func (k *Keymap) Mode() string { return k.mode }
+
+// SetMode switches to mode and discards pending keys.
+func (k *Keymap) SetMode(mode string) {
+	k.mode = mode
+	k.pending = nil
+}
+
+// Reset switches back to the initial mode.
+func (k *Keymap) Reset() { k.SetMode(k.initial) }
+
+// Pending returns the keys of an incomplete sequence.
+func (k *Keymap) Pending() string { return strings.Join(k.pending, "") }
+
+// Press handles key and returns the action it completes, if any, and the
+// keys that turned out not to be bound, in the order they were pressed.
+// A key that doesn't continue the pending sequence is looked up on its own.
+func (k *Keymap) Press(key string) (string, []string) {
+	var unbound []string
+	if len(k.pending) != 0 {
+		action, pending := k.lookup(append(k.pending, key))
+		if action != "" || pending {
+			return action, nil
+		}
+
+		unbound = k.pending
+		k.pending = nil
+	}
+
+	action, pending := k.lookup([]string{key})
+	if action == "" && !pending {
+		unbound = append(unbound, key)
+	}
+
+	return action, unbound
+}
+
+// lookup returns the action bound to keys or, if they start a longer
+// sequence, keeps them pending.
+func (k *Keymap) lookup(keys []string) (action string, pending bool) {
+	bindings := k.modes[k.mode]
+	s := strings.Join(keys, sep)
+	if action, ok := bindings[s]; ok {
+		k.pending = nil
+		return action, false
+	}
+
+	for seq := range bindings {
+		if strings.HasPrefix(seq, s+sep) {
+			k.pending = keys
+			return "", true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,158 @@
+package keymap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		seq  string
+		keys []string
+	}{
+		{"gg", []string{"g", "g"}},
+		{"<C-j>", []string{"<C-j>"}},
+		{"<c-J>", []string{"<C-j>"}},
+		{"<lt>", []string{"<"}},
+		{"<", []string{"<"}},
+		{"<lt>>", []string{"<", ">"}},
+		{"<Space>", []string{"<Space>"}},
+		{" ", []string{"<Space>"}},
+		{"a<CR>", []string{"a", "<Enter>"}},
+		{"<F12>", []string{"<F12>"}},
+		{"<foo>", []string{"<", "f", "o", "o", ">"}},
+		{"<C-1>", []string{"<", "C", "-", "1", ">"}},
+		{"é", []string{"é"}},
+	}
+
+	for _, test := range tests {
+		keys, err := Parse(test.seq)
+		if err != nil {
+			t.Errorf("%q: %s", test.seq, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%q: %q, expected %q", test.seq, keys, test.keys)
+		}
+	}
+
+	for _, seq := range []string{"", "a\xff"} {
+		if _, err := Parse(seq); err == nil {
+			t.Errorf("%q: expected an error", seq)
+		}
+	}
+}
+
+func testKeymap(t *testing.T) *Keymap {
+	k := New("normal")
+	for seq, action := range map[string]string{
+		"j":     "down",
+		"gg":    "top",
+		"gq":    "queue",
+		"z":     "zed",
+		"zz":    "center",
+		"<C-j>": "down",
+		"x":     "",
+	} {
+		if err := k.Bind("normal", seq, action); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := k.Bind("insert", "<Esc>", "normal"); err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+type press struct {
+	key     string
+	action  string
+	unbound []string
+	pending string
+}
+
+func TestPress(t *testing.T) {
+	tests := map[string][]press{
+		"single": {
+			{"j", "down", nil, ""},
+			{"<C-j>", "down", nil, ""},
+		},
+		"sequence": {
+			{"g", "", nil, "g"},
+			{"q", "queue", nil, ""},
+			{"g", "", nil, "g"},
+			{"g", "top", nil, ""},
+		},
+		"shadowed": {
+			{"z", "zed", nil, ""},
+			{"z", "zed", nil, ""},
+		},
+		"unbound": {
+			{"x", "", []string{"x"}, ""},
+			{"g", "", nil, "g"},
+			{"x", "", []string{"g", "x"}, ""},
+		},
+		"interrupted": {
+			{"g", "", nil, "g"},
+			{"j", "down", []string{"g"}, ""},
+			{"g", "", nil, "g"},
+			{"z", "zed", []string{"g"}, ""},
+		},
+		"restarted": {
+			{"g", "", nil, "g"},
+			{"<Esc>", "", []string{"g", "<Esc>"}, ""},
+		},
+	}
+
+	for name, presses := range tests {
+		k := testKeymap(t)
+		for i, p := range presses {
+			action, unbound := k.Press(p.key)
+			if action != p.action || !reflect.DeepEqual(unbound, p.unbound) {
+				t.Errorf("%s %d %s: %q %q, expected %q %q", name, i, p.key, action, unbound, p.action, p.unbound)
+			}
+			if k.Pending() != p.pending {
+				t.Errorf("%s %d %s: pending %q, expected %q", name, i, p.key, k.Pending(), p.pending)
+			}
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	k := testKeymap(t)
+	if modes := k.Modes(); !reflect.DeepEqual(modes, []string{"normal", "insert"}) {
+		t.Errorf("modes %v", modes)
+	}
+
+	k.Press("g")
+	k.SetMode("insert")
+	if k.Pending() != "" {
+		t.Errorf("pending %q after switching modes", k.Pending())
+	}
+	if action, unbound := k.Press("j"); action != "" || !reflect.DeepEqual(unbound, []string{"j"}) {
+		t.Errorf("j in insert mode: %q %q", action, unbound)
+	}
+	if action, _ := k.Press("<Esc>"); action != "normal" {
+		t.Errorf("<Esc> in insert mode: %q", action)
+	}
+
+	k.Reset()
+	if k.Mode() != "normal" {
+		t.Errorf("mode %s after reset", k.Mode())
+	}
+
+	if err := k.Bind("normal", "j", ""); err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string][]string{
+		"down":   {"<C-j>"},
+		"top":    {"gg"},
+		"queue":  {"gq"},
+		"zed":    {"z"},
+		"center": {"zz"},
+	}
+	if b := k.Bindings("normal"); !reflect.DeepEqual(b, exp) {
+		t.Errorf("bindings %v, expected %v", b, exp)
+	}
+}
@@ -0,0 +1,101 @@
+package keymap
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// names of special keys, lowercased, to their canonical form.
+var names = map[string]string{
+	"enter":     "<Enter>",
+	"cr":        "<Enter>",
+	"return":    "<Enter>",
+	"esc":       "<Esc>",
+	"escape":    "<Esc>",
+	"space":     "<Space>",
+	"tab":       "<Tab>",
+	"bs":        "<BS>",
+	"backspace": "<BS>",
+	"del":       "<Del>",
+	"delete":    "<Del>",
+	"insert":    "<Insert>",
+	"up":        "<Up>",
+	"down":      "<Down>",
+	"left":      "<Left>",
+	"right":     "<Right>",
+	"home":      "<Home>",
+	"end":       "<End>",
+	"pageup":    "<PageUp>",
+	"pgup":      "<PageUp>",
+	"pagedown":  "<PageDown>",
+	"pgdn":      "<PageDown>",
+	"lt":        "<",
+}
+
+func init() {
+	for i := 1; i <= 12; i++ {
+		names[fmt.Sprintf("f%d", i)] = fmt.Sprintf("<F%d>", i)
+	}
+}
+
+// Ctrl returns the name of ctrl+r.
+func Ctrl(r rune) string {
+	return "<C-" + string(r) + ">"
+}
+
+// Char returns the name of the key that types r.
+func Char(r rune) string {
+	if r == ' ' {
+		return "<Space>"
+	}
+
+	return string(r)
+}
+
+// special returns the canonical name of the key between < and >.
+func special(s string) (string, bool) {
+	l := strings.ToLower(s)
+	if n, ok := names[l]; ok {
+		return n, true
+	}
+
+	if strings.HasPrefix(l, "c-") {
+		r, n := utf8.DecodeRuneInString(l[2:])
+		if n != 0 && n == len(l)-2 && r >= 'a' && r <= 'z' {
+			return Ctrl(r), true
+		}
+	}
+
+	return "", false
+}
+
+// Parse splits a key sequence in vim notation (e.g.: gg, <C-j>, <Space>)
+// into key names. A < that doesn't start a key name is the < key itself.
+func Parse(seq string) ([]string, error) {
+	keys := make([]string, 0, len(seq))
+	for len(seq) != 0 {
+		if seq[0] == '<' {
+			if end := strings.IndexByte(seq, '>'); end > 1 {
+				if n, ok := special(seq[1:end]); ok {
+					keys = append(keys, n)
+					seq = seq[end+1:]
+					continue
+				}
+			}
+		}
+
+		r, n := utf8.DecodeRuneInString(seq)
+		if r == utf8.RuneError {
+			return nil, fmt.Errorf("Invalid key sequence %q", seq)
+		}
+		keys = append(keys, Char(r))
+		seq = seq[n:]
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("Empty key sequence")
+	}
+
+	return keys, nil
+}
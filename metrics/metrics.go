@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	typeSummary = "summary"
+)
+
+// Registry holds metrics and writes them in the prometheus text format.
+// All methods are thread safe and nil Registries and Metrics are no-ops,
+// so packages can be instrumented unconditionally.
+type Registry struct {
+	sem     sync.Mutex
+	metrics []*Metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Metric is a counter, gauge or summary with zero or more labels.
+type Metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	fn     func() float64
+
+	sem    sync.Mutex
+	values map[string]*value
+}
+
+type value struct {
+	labels []string
+	v      float64
+	count  uint64
+}
+
+func (r *Registry) add(m *Metric) *Metric {
+	if r == nil {
+		return nil
+	}
+
+	m.values = make(map[string]*value)
+	r.sem.Lock()
+	r.metrics = append(r.metrics, m)
+	r.sem.Unlock()
+	return m
+}
+
+// Counter registers a counter, it should be incremented with Add.
+func (r *Registry) Counter(name, help string, labels ...string) *Metric {
+	return r.add(&Metric{name: name, help: help, typ: typeCounter, labels: labels})
+}
+
+// Gauge registers a gauge, it should be updated with Set or Add.
+func (r *Registry) Gauge(name, help string, labels ...string) *Metric {
+	return r.add(&Metric{name: name, help: help, typ: typeGauge, labels: labels})
+}
+
+// Summary registers a summary, values should be added with Observe.
+// Only the sum and count are exported.
+func (r *Registry) Summary(name, help string, labels ...string) *Metric {
+	return r.add(&Metric{name: name, help: help, typ: typeSummary, labels: labels})
+}
+
+// CounterFunc registers a counter whose value is returned by fn.
+func (r *Registry) CounterFunc(name, help string, fn func() float64) {
+	r.add(&Metric{name: name, help: help, typ: typeCounter, fn: fn})
+}
+
+// GaugeFunc registers a gauge whose value is returned by fn.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	r.add(&Metric{name: name, help: help, typ: typeGauge, fn: fn})
+}
+
+func (m *Metric) value(labels []string) *value {
+	if len(labels) != len(m.labels) {
+		panic(fmt.Sprintf("metric %s needs %d labels", m.name, len(m.labels)))
+	}
+
+	key := strings.Join(labels, "\x00")
+	v, ok := m.values[key]
+	if !ok {
+		v = &value{labels: labels}
+		m.values[key] = v
+	}
+
+	return v
+}
+
+// Add adds n to the value with the given label values.
+func (m *Metric) Add(n float64, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.sem.Lock()
+	m.value(labels).v += n
+	m.sem.Unlock()
+}
+
+// Inc adds 1 to the value with the given label values.
+func (m *Metric) Inc(labels ...string) {
+	m.Add(1, labels...)
+}
+
+// Set sets the value with the given label values.
+func (m *Metric) Set(n float64, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.sem.Lock()
+	m.value(labels).v = n
+	m.sem.Unlock()
+}
+
+// Value returns the value with the given label values, the sum for
+// summaries.
+func (m *Metric) Value(labels ...string) float64 {
+	if m == nil {
+		return 0
+	}
+
+	m.sem.Lock()
+	defer m.sem.Unlock()
+	if v, ok := m.values[strings.Join(labels, "\x00")]; ok {
+		return v.v
+	}
+
+	return 0
+}
+
+// Observe adds a sample to a summary.
+func (m *Metric) Observe(n float64, labels ...string) {
+	if m == nil {
+		return
+	}
+
+	m.sem.Lock()
+	v := m.value(labels)
+	v.v += n
+	v.count++
+	m.sem.Unlock()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (m *Metric) labelString(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	l := make([]string, len(values))
+	for i := range values {
+		l[i] = fmt.Sprintf(`%s="%s"`, m.labels[i], escape(values[i]))
+	}
+
+	return "{" + strings.Join(l, ",") + "}"
+}
+
+func (m *Metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escape(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+	if m.fn != nil {
+		fmt.Fprintf(w, "%s %s\n", m.name, format(m.fn()))
+		return
+	}
+
+	m.sem.Lock()
+	values := make([]*value, 0, len(m.values))
+	for _, v := range m.values {
+		values = append(values, &value{v.labels, v.v, v.count})
+	}
+	m.sem.Unlock()
+
+	sort.Slice(values, func(i, j int) bool {
+		return strings.Join(values[i].labels, "\x00") < strings.Join(values[j].labels, "\x00")
+	})
+	if len(values) == 0 && len(m.labels) == 0 {
+		values = append(values, &value{})
+	}
+
+	for _, v := range values {
+		l := m.labelString(v.labels)
+		if m.typ == typeSummary {
+			fmt.Fprintf(w, "%s_sum%s %s\n", m.name, l, format(v.v))
+			fmt.Fprintf(w, "%s_count%s %d\n", m.name, l, v.count)
+			continue
+		}
+		fmt.Fprintf(w, "%s%s %s\n", m.name, l, format(v.v))
+	}
+}
+
+// Write writes all metrics in the prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.sem.Lock()
+	metrics := make([]*Metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.sem.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+
+	return buf.Flush()
+}
+
+// ServeHTTP serves the metrics in the prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
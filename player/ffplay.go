@@ -6,11 +6,18 @@ func NewFFPlay() *GenericPlayer {
 		paramMap: map[Param][]string{
 			ParamNoVideo: {"-vn", "-nodisp"},
 			ParamSilent:  {"-loglevel", "quiet"},
+			ParamGain:    {"-af", "volume=%sdB"},
+			ParamStart:   {"-ss", "%s"},
 		},
 		commandMap: map[Command][]byte{
-			CmdPause: []byte(" "),
-			CmdNext:  []byte("\033[C"),
-			CmdPrev:  []byte("\033[D"),
+			CmdPause:   []byte(" "),
+			CmdVolUp:   []byte("0"),
+			CmdVolDown: []byte("9"),
+			CmdNext:    []byte("\033[C"),
+			CmdPrev:    []byte("\033[D"),
+
+			CmdSeekForward:  []byte("\033[C"),
+			CmdSeekBackward: []byte("\033[D"),
 		},
 	}
 }
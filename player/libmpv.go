@@ -3,70 +3,47 @@
 package player
 
 import (
-	"time"
+	"strings"
+	"sync"
 
 	"github.com/YouROK/go-mpv/mpv"
 )
 
 type LibMPV struct {
-	cmdPause   map[bool]string
-	volume     float64
-	volumeChan chan<- int
-	seekChan   chan<- *Pos
-	pos        pos
-}
-
-type pos struct {
-	timePos float64
-	timeEnd float64
-}
-
-func (p pos) Pos() *Pos {
-	return &Pos{
-		time.Duration(p.timePos * float64(time.Second)),
-		time.Duration(p.timeEnd * float64(time.Second)),
-	}
+	*mpvPlayer
 }
 
 func NewLibMPV(volume chan<- int, seek chan<- *Pos) Player {
-	return &LibMPV{
-		map[bool]string{
-			true:  "yes",
-			false: "no",
-		},
-		100,
-		volume,
-		seek,
-		pos{0, 0},
-	}
+	return &LibMPV{newMPVPlayer(volume, seek, connectLibMPV)}
 }
 
 func (m *LibMPV) Name() string {
 	return "libmpv"
 }
 
-func (m *LibMPV) Spawn(file string, params []Param) (chan Command, func(), error) {
-	commands := make(chan Command)
-	done := make(chan struct{})
+func (m *LibMPV) Supported() bool {
+	return true
+}
 
-	p := mpv.Create()
-	m.adjustVolume(p, 0)
+type libMPVConn struct {
+	p      *mpv.Mpv
+	events chan string
+	once   sync.Once
 
-	c := make(chan *mpv.Event)
-	go func() {
-		for {
-			e := p.WaitEvent(.05)
-			if e.Event_Id == mpv.EVENT_END_FILE ||
-				e.Event_Id == mpv.EVENT_SHUTDOWN {
-				done <- struct{}{}
-				p.TerminateDestroy()
-				close(c)
-				return
-			}
-			c <- e
-		}
-	}()
+	sem       sync.RWMutex
+	destroyed bool
+}
 
+func connectLibMPV(params []Param, args []string) (mpvConn, error) {
+	p := mpv.Create()
+	p.SetOptionString("idle", "yes")
+	for _, arg := range args {
+		kv := strings.SplitN(strings.TrimLeft(arg, "-"), "=", 2)
+		if len(kv) == 1 {
+			kv = append(kv, "yes")
+		}
+		p.SetOptionString(kv[0], kv[1])
+	}
 	for _, par := range params {
 		switch par {
 		case ParamNoVideo:
@@ -77,154 +54,75 @@ func (m *LibMPV) Spawn(file string, params []Param) (chan Command, func(), error
 	}
 
 	if err := p.Initialize(); err != nil {
-		return nil, nil, err
+		p.TerminateDestroy()
+		return nil, err
 	}
 
+	c := &libMPVConn{p: p, events: make(chan string, 8)}
 	go func() {
-		paused := false
-		closed := false
-		quickTO := time.Millisecond * 200
-		slowTO := time.Second * 2
-		quick := time.After(slowTO)
-		slow := time.After(quickTO)
+		defer close(c.events)
 		for {
-			select {
-			case <-done:
-				closed = true
-			case command, ok := <-commands:
-				if !ok && closed {
-					return
-				}
-
-				if closed {
-					continue
-				}
-
-				switch command {
-				case CmdPause:
-					paused = !paused
-					p.SetPropertyString("pause", m.cmdPause[paused])
-
-				case CmdStop:
-					p.Command([]string{"quit"})
-
-				case CmdVolDown:
-					m.adjustVolume(p, -5)
-
-				case CmdVolUp:
-					m.adjustVolume(p, 5)
-
-				case CmdSeekBackward:
-					m.seek(p, -time.Second*10)
-
-				case CmdSeekForward:
-					m.seek(p, time.Second*10)
-				}
-			case <-quick:
-				quick = time.After(quickTO)
-				if closed {
-					continue
-				}
-
-				m.positionQuick(p)
-			case <-slow:
-				slow = time.After(slowTO)
-				if closed {
-					continue
-				}
-
-				m.position(p)
+			e := p.WaitEvent(.05)
+			if e == nil {
+				continue
 			}
-		}
-	}()
 
-	wait := func() {
-		for range c {
+			switch e.Event_Id {
+			case mpv.EVENT_START_FILE:
+				c.events <- "start-file"
+			case mpv.EVENT_FILE_LOADED:
+				c.events <- "file-loaded"
+			case mpv.EVENT_END_FILE:
+				c.events <- "end-file"
+			case mpv.EVENT_SHUTDOWN:
+				c.events <- "shutdown"
+				return
+			}
 		}
-	}
-
-	p.Command([]string{"loadfile", file})
-	return commands, wait, nil
-}
+	}()
 
-func (m *LibMPV) Supported() bool {
-	return true
+	return c, nil
 }
 
-func (m *LibMPV) seek(p *mpv.Mpv, adjustment time.Duration) error {
-	_cur, err := p.GetProperty("time-pos", mpv.FORMAT_INT64)
-	if err != nil {
-		return err
-	}
-
-	if adjustment != 0 {
-		cur := _cur.(int64) + int64(adjustment.Seconds())
-		if cur < 0 {
-			cur = 0
-		}
-
-		p.SetProperty("time-pos", mpv.FORMAT_INT64, cur)
+func (c *libMPVConn) Command(args ...string) error {
+	c.sem.RLock()
+	defer c.sem.RUnlock()
+	if c.destroyed {
+		return errDestroyed
 	}
 
-	m.positionQuick(p)
-
-	return nil
+	return c.p.Command(args)
 }
 
-func (m *LibMPV) positionQuick(p *mpv.Mpv) error {
-	_cur, err := p.GetProperty("time-pos", mpv.FORMAT_DOUBLE)
-	if err != nil {
-		return err
-	}
-
-	m.pos.timePos = _cur.(float64)
-
-	select {
-	case m.seekChan <- m.pos.Pos():
-	default:
+func (c *libMPVConn) GetFloat(name string) (float64, error) {
+	c.sem.RLock()
+	defer c.sem.RUnlock()
+	if c.destroyed {
+		return 0, errDestroyed
 	}
 
-	return nil
-}
-
-func (m *LibMPV) position(p *mpv.Mpv) error {
-	_byteCur, err := p.GetProperty("stream-pos", mpv.FORMAT_DOUBLE)
-	if err != nil {
-		return err
-	}
-	_byteTotal, err := p.GetProperty("stream-end", mpv.FORMAT_DOUBLE)
+	v, err := c.p.GetProperty(name, mpv.FORMAT_DOUBLE)
 	if err != nil {
-		return err
-	}
-
-	byteTotal := _byteTotal.(float64)
-	if byteTotal < 1 {
-		byteTotal = 1
-	}
-
-	bytePos := _byteCur.(float64) / byteTotal
-	if bytePos > 1.0 {
-		bytePos = 1.0
+		return 0, err
 	}
 
-	m.pos.timeEnd = float64(m.pos.timePos) / bytePos
-	return m.positionQuick(p)
+	return v.(float64), nil
 }
 
-func (m *LibMPV) adjustVolume(p *mpv.Mpv, adjustment float64) error {
-	m.volume += adjustment
-	if m.volume < 0 {
-		m.volume = 0
-	} else if m.volume > 100 {
-		m.volume = 100
-	}
-
-	p.SetProperty("volume", mpv.FORMAT_DOUBLE, m.volume)
-
-	select {
-	case m.volumeChan <- int(m.volume):
-	default:
-	}
+func (c *libMPVConn) Events() <-chan string {
+	return c.events
+}
 
-	return nil
+func (c *libMPVConn) Close() {
+	c.once.Do(func() {
+		c.Command("quit")
+		go func() {
+			for range c.events {
+			}
+			c.sem.Lock()
+			c.destroyed = true
+			c.p.TerminateDestroy()
+			c.sem.Unlock()
+		}()
+	})
 }
@@ -6,6 +6,8 @@ func NewMPlayer() *GenericPlayer {
 		paramMap: map[Param][]string{
 			ParamNoVideo: {"-vo", "null"},
 			ParamSilent:  {"-really-quiet"},
+			ParamGain:    {"-af", "volume=%s"},
+			ParamStart:   {"-ss", "%s"},
 		},
 		commandMap: map[Command][]byte{
 			CmdPause:   []byte(" "),
@@ -13,6 +15,9 @@ func NewMPlayer() *GenericPlayer {
 			CmdPrev:    []byte("\033[D"),
 			CmdVolUp:   []byte("0"),
 			CmdVolDown: []byte("9"),
+
+			CmdSeekForward:  []byte("\033[C"),
+			CmdSeekBackward: []byte("\033[D"),
 		},
 	}
 }
@@ -0,0 +1,491 @@
+package player
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	errDestroyed  = errors.New("mpv instance was destroyed")
+	errNotRunning = errors.New("mpv is not running")
+)
+
+// mpvConn is a connection to a single mpv instance,
+// either through libmpv or mpv's json ipc.
+type mpvConn interface {
+	Command(args ...string) error
+	GetFloat(name string) (float64, error)
+	// Events returns the names of the events emitted by mpv,
+	// e.g.: start-file, end-file, file-loaded.
+	// The channel is closed when the instance shuts down.
+	Events() <-chan string
+	Close()
+}
+
+// mpvPlayer implements the mpv specific parts shared by LibMPV and MPV.
+type mpvPlayer struct {
+	connect func(params []Param, args []string) (mpvConn, error)
+	args    []string
+
+	cmdPause   map[bool]string
+	volume     float64
+	volumeChan chan<- int
+	seekChan   chan<- *Pos
+
+	sem     sync.Mutex
+	pos     pos
+	session *mpvSession
+}
+
+type pos struct {
+	timePos float64
+	timeEnd float64
+}
+
+func (p pos) Pos() *Pos {
+	return &Pos{
+		time.Duration(p.timePos * float64(time.Second)),
+		time.Duration(p.timeEnd * float64(time.Second)),
+	}
+}
+
+func newMPVPlayer(
+	volume chan<- int,
+	seek chan<- *Pos,
+	connect func(params []Param, args []string) (mpvConn, error),
+) *mpvPlayer {
+	return &mpvPlayer{
+		connect: connect,
+		cmdPause: map[bool]string{
+			true:  "yes",
+			false: "no",
+		},
+		volume:     100,
+		volumeChan: volume,
+		seekChan:   seek,
+	}
+}
+
+func (m *mpvPlayer) SetArgs(args []string) {
+	m.args = args
+}
+
+func (m *mpvPlayer) KeepsVolume() bool { return true }
+
+func (m *mpvPlayer) Spawn(file string, params []Param) (chan Command, func(), error) {
+	commands, _, wait, err := m.SpawnFade(file, params)
+	return commands, wait, err
+}
+
+func (m *mpvPlayer) SpawnFade(file string, params []Param) (chan Command, <-chan struct{}, func(), error) {
+	conn, err := m.connect(params, m.args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	s := &mpvSession{m: m, conn: conn}
+	f := newMPVFile(file, params)
+	s.files = []*mpvFile{f}
+	m.sem.Lock()
+	m.session = s
+	m.sem.Unlock()
+
+	m.adjustVolume(conn, 0)
+	go s.run()
+	go s.handle(f)
+	if err := conn.Command("loadfile", file); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+
+	return f.commands, f.fade, f.wait, nil
+}
+
+// Append queues file in the instance started by the last call to Spawn,
+// it is played without a gap once the current file ends.
+// Sending CmdStop before the file started removes it from the queue.
+func (m *mpvPlayer) Append(file string, params []Param) (chan Command, func(), error) {
+	m.sem.Lock()
+	s := m.session
+	m.sem.Unlock()
+
+	f := newMPVFile(file, params)
+	if s == nil || !s.append(f) {
+		return nil, nil, errNotRunning
+	}
+
+	go s.handle(f)
+	return f.commands, f.wait, nil
+}
+
+func (m *mpvPlayer) SeekTo(pos time.Duration) error {
+	m.sem.Lock()
+	s := m.session
+	m.sem.Unlock()
+	if s == nil || s.current() == nil {
+		return errNotRunning
+	}
+
+	err := s.conn.Command(
+		"seek",
+		strconv.FormatFloat(pos.Seconds(), 'f', 3, 64),
+		"absolute",
+	)
+	if err != nil {
+		return err
+	}
+
+	return m.positionQuick(s.conn)
+}
+
+func (m *mpvPlayer) seek(conn mpvConn, adjustment time.Duration) error {
+	if adjustment != 0 {
+		err := conn.Command(
+			"seek",
+			strconv.FormatFloat(adjustment.Seconds(), 'f', 3, 64),
+			"relative",
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.positionQuick(conn)
+}
+
+func (m *mpvPlayer) positionQuick(conn mpvConn) error {
+	cur, err := conn.GetFloat("time-pos")
+	if err != nil {
+		return err
+	}
+
+	m.sem.Lock()
+	m.pos.timePos = cur
+	p := m.pos.Pos()
+	m.sem.Unlock()
+
+	select {
+	case m.seekChan <- p:
+	default:
+	}
+
+	return nil
+}
+
+func (m *mpvPlayer) position(conn mpvConn) error {
+	if dur, err := conn.GetFloat("duration"); err == nil && dur > 0 {
+		m.sem.Lock()
+		m.pos.timeEnd = dur
+		m.sem.Unlock()
+		return m.positionQuick(conn)
+	}
+
+	// Estimate from the stream position, e.g.: while the demuxer
+	// has not determined the duration yet.
+	byteCur, err := conn.GetFloat("stream-pos")
+	if err != nil {
+		return err
+	}
+	byteTotal, err := conn.GetFloat("stream-end")
+	if err != nil {
+		return err
+	}
+
+	if byteTotal < 1 {
+		byteTotal = 1
+	}
+
+	bytePos := byteCur / byteTotal
+	if bytePos > 1.0 {
+		bytePos = 1.0
+	}
+
+	m.sem.Lock()
+	m.pos.timeEnd = m.pos.timePos / bytePos
+	m.sem.Unlock()
+	return m.positionQuick(conn)
+}
+
+func (m *mpvPlayer) adjustVolume(conn mpvConn, adjustment float64) error {
+	m.sem.Lock()
+	m.volume += adjustment
+	if m.volume < 0 {
+		m.volume = 0
+	} else if m.volume > 100 {
+		m.volume = 100
+	}
+	v := m.volume
+	m.sem.Unlock()
+
+	conn.Command("set", "volume", strconv.FormatFloat(v, 'f', 0, 64))
+
+	select {
+	case m.volumeChan <- int(v):
+	default:
+	}
+
+	return nil
+}
+
+// mpvFile is a single file queued in an mpv instance.
+type mpvFile struct {
+	file      string
+	filters   []string
+	start     string
+	crossfade time.Duration
+
+	commands chan Command
+	done     chan struct{}
+	once     sync.Once
+	fade     chan struct{}
+	fading   bool
+}
+
+func newMPVFile(file string, params []Param) *mpvFile {
+	f := &mpvFile{
+		file:     file,
+		commands: make(chan Command),
+		done:     make(chan struct{}),
+		fade:     make(chan struct{}),
+	}
+
+	for _, p := range params {
+		p, v := p.Split()
+		switch p {
+		case ParamGain:
+			f.filters = append(f.filters, "volume="+v+"dB")
+		case ParamStart:
+			f.start = v
+		case ParamCrossfade:
+			d, _ := strconv.ParseFloat(v, 64)
+			f.crossfade = time.Duration(d * float64(time.Second))
+			f.filters = append(f.filters, "afade=t=in:d="+v)
+		}
+	}
+
+	return f
+}
+
+func (f *mpvFile) finish() {
+	f.once.Do(func() { close(f.done) })
+}
+
+func (f *mpvFile) finished() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *mpvFile) wait() {
+	<-f.done
+}
+
+func (f *mpvFile) af() string {
+	if len(f.filters) == 0 {
+		return ""
+	}
+
+	af := "lavfi=["
+	for i, filter := range f.filters {
+		if i != 0 {
+			af += ","
+		}
+		af += filter
+	}
+
+	return af + "]"
+}
+
+// mpvSession drives a single mpv instance and the files queued in it.
+type mpvSession struct {
+	m    *mpvPlayer
+	conn mpvConn
+
+	sem    sync.Mutex
+	files  []*mpvFile
+	dead   bool
+	paused bool
+}
+
+func (s *mpvSession) append(f *mpvFile) bool {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	if s.dead || len(s.files) == 0 || s.files[0].fading {
+		return false
+	}
+
+	if err := s.conn.Command("loadfile", f.file, "append"); err != nil {
+		return false
+	}
+
+	s.files = append(s.files, f)
+	return true
+}
+
+func (s *mpvSession) current() *mpvFile {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	if len(s.files) == 0 {
+		return nil
+	}
+
+	return s.files[0]
+}
+
+// remove drops a file that has not started yet.
+func (s *mpvSession) remove(f *mpvFile) bool {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	for i := 1; i < len(s.files); i++ {
+		if s.files[i] == f {
+			s.conn.Command("playlist-clear")
+			for _, f := range s.files[1:] {
+				f.finish()
+			}
+			s.files = s.files[:1]
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *mpvSession) run() {
+	quickTO := time.Millisecond * 200
+	slowTO := time.Second * 2
+	quick := time.After(quickTO)
+	slow := time.After(slowTO)
+	events := s.conn.Events()
+
+	defer func() {
+		s.sem.Lock()
+		s.dead = true
+		for _, f := range s.files {
+			f.finish()
+		}
+		s.files = nil
+		s.sem.Unlock()
+		s.conn.Close()
+	}()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+
+			switch e {
+			case "start-file":
+				if f := s.current(); f != nil {
+					s.conn.Command("set", "af", f.af())
+				}
+			case "file-loaded":
+				if f := s.current(); f != nil && f.start != "" {
+					s.conn.Command("seek", f.start, "absolute")
+				}
+			case "end-file":
+				s.sem.Lock()
+				if len(s.files) != 0 {
+					s.files[0].finish()
+					s.files = s.files[1:]
+				}
+				empty := len(s.files) == 0
+				s.sem.Unlock()
+				if empty {
+					return
+				}
+			case "shutdown":
+				return
+			}
+
+		case <-quick:
+			quick = time.After(quickTO)
+			if f := s.current(); f != nil && !f.fading {
+				s.m.positionQuick(s.conn)
+				s.crossfade(f)
+			}
+
+		case <-slow:
+			slow = time.After(slowTO)
+			if f := s.current(); f != nil && !f.fading {
+				s.m.position(s.conn)
+			}
+		}
+	}
+}
+
+// crossfade starts fading out f if it is about to end and reports it
+// so the next file can start fading in, f finishes once mpv is done.
+func (s *mpvSession) crossfade(f *mpvFile) {
+	if f.crossfade <= 0 {
+		return
+	}
+
+	cur, err := s.conn.GetFloat("time-pos")
+	if err != nil {
+		return
+	}
+	dur, err := s.conn.GetFloat("duration")
+	if err != nil || dur <= f.crossfade.Seconds() {
+		return
+	}
+
+	if dur-cur > f.crossfade.Seconds() {
+		return
+	}
+
+	s.sem.Lock()
+	f.fading = true
+	s.sem.Unlock()
+
+	d := strconv.FormatFloat(dur-cur, 'f', 3, 64)
+	s.conn.Command("af", "add", "lavfi=[afade=t=out:d="+d+"]")
+	close(f.fade)
+}
+
+// handle processes the commands sent for f.
+func (s *mpvSession) handle(f *mpvFile) {
+	for command := range f.commands {
+		if f.finished() {
+			continue
+		}
+
+		if command == CmdStop {
+			if !s.remove(f) {
+				s.conn.Command("quit")
+			}
+			continue
+		}
+
+		if s.current() != f {
+			continue
+		}
+
+		switch command {
+		case CmdPause:
+			s.sem.Lock()
+			s.paused = !s.paused
+			paused := s.paused
+			s.sem.Unlock()
+			s.conn.Command("set", "pause", s.m.cmdPause[paused])
+
+		case CmdVolDown:
+			s.m.adjustVolume(s.conn, -VolumeStep)
+
+		case CmdVolUp:
+			s.m.adjustVolume(s.conn, VolumeStep)
+
+		case CmdSeekBackward:
+			s.m.seek(s.conn, -SeekStep)
+
+		case CmdSeekForward:
+			s.m.seek(s.conn, SeekStep)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// +build !windows
+
+package player
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MPV controls the mpv binary through its json ipc.
+type MPV struct {
+	*mpvPlayer
+	dir string
+}
+
+func NewMPV(volume chan<- int, seek chan<- *Pos) *MPV {
+	m := &MPV{}
+	m.mpvPlayer = newMPVPlayer(volume, seek, func(params []Param, extra []string) (mpvConn, error) {
+		return connectMPV(m.dir, params, extra)
+	})
+	return m
+}
+
+// SetSocketDir sets the directory the ipc sockets are created in,
+// os.TempDir() by default.
+func (m *MPV) SetSocketDir(dir string) {
+	m.dir = dir
+}
+
+func (m *MPV) Name() string {
+	return "mpv"
+}
+
+func (m *MPV) Supported() bool {
+	return binaryInPath("mpv")
+}
+
+type ipcResponse struct {
+	Event     string      `json:"event"`
+	RequestID uint64      `json:"request_id"`
+	Error     string      `json:"error"`
+	Data      interface{} `json:"data"`
+}
+
+type ipcRequest struct {
+	Command   []string `json:"command"`
+	RequestID uint64   `json:"request_id"`
+}
+
+type mpvIPCConn struct {
+	cmd    *exec.Cmd
+	sock   string
+	conn   net.Conn
+	events chan string
+	once   sync.Once
+
+	sem     sync.Mutex
+	id      uint64
+	pending map[uint64]chan *ipcResponse
+}
+
+func connectMPV(dir string, params []Param, extra []string) (mpvConn, error) {
+	// a private directory so no one else can connect or take its place
+	dir, err := ioutil.TempDir(dir, "ym-mpv-")
+	if err != nil {
+		return nil, err
+	}
+	sock := filepath.Join(dir, "mpv.sock")
+
+	args := []string{
+		"--idle=yes",
+		"--no-terminal",
+		"--input-ipc-server=" + sock,
+	}
+	for _, p := range params {
+		switch p {
+		case ParamNoVideo:
+			args = append(args, "--vid=no")
+		case ParamSilent:
+			args = append(args, "--really-quiet")
+		}
+	}
+
+	args = append(args, extra...)
+	cmd := exec.Command("mpv", args...)
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		conn, err = net.Dial("unix", sock)
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	c := &mpvIPCConn{
+		cmd:     cmd,
+		sock:    sock,
+		conn:    conn,
+		events:  make(chan string, 8),
+		pending: make(map[uint64]chan *ipcResponse),
+	}
+
+	go c.read()
+	return c, nil
+}
+
+func (c *mpvIPCConn) read() {
+	defer close(c.events)
+	scan := bufio.NewScanner(c.conn)
+	for scan.Scan() {
+		res := &ipcResponse{}
+		if err := json.Unmarshal(scan.Bytes(), res); err != nil {
+			continue
+		}
+
+		if res.Event != "" {
+			c.events <- res.Event
+			continue
+		}
+
+		c.sem.Lock()
+		ch, ok := c.pending[res.RequestID]
+		delete(c.pending, res.RequestID)
+		c.sem.Unlock()
+		if ok {
+			ch <- res
+		}
+	}
+}
+
+func (c *mpvIPCConn) request(args ...string) (*ipcResponse, error) {
+	ch := make(chan *ipcResponse, 1)
+	c.sem.Lock()
+	c.id++
+	id := c.id
+	c.pending[id] = ch
+	c.sem.Unlock()
+
+	d, err := json.Marshal(&ipcRequest{args, id})
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(time.Second * 2))
+	if _, err := c.conn.Write(append(d, '\n')); err != nil {
+		c.sem.Lock()
+		delete(c.pending, id)
+		c.sem.Unlock()
+		return nil, err
+	}
+
+	select {
+	case res := <-ch:
+		if res.Error != "success" {
+			return res, errors.New(res.Error)
+		}
+		return res, nil
+	case <-time.After(time.Second * 2):
+		c.sem.Lock()
+		delete(c.pending, id)
+		c.sem.Unlock()
+		return nil, errors.New("mpv ipc timeout")
+	}
+}
+
+func (c *mpvIPCConn) Command(args ...string) error {
+	_, err := c.request(args...)
+	return err
+}
+
+func (c *mpvIPCConn) GetFloat(name string) (float64, error) {
+	res, err := c.request("get_property", name)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := res.Data.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+
+	return 0, fmt.Errorf("property %s is not a number", name)
+}
+
+func (c *mpvIPCConn) Events() <-chan string {
+	return c.events
+}
+
+func (c *mpvIPCConn) Close() {
+	c.once.Do(func() {
+		// Don't wait for a reply, mpv might exit before sending one.
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second * 2))
+		c.conn.Write([]byte(`{"command":["quit"]}` + "\n"))
+		go func() {
+			for range c.events {
+			}
+		}()
+		c.conn.Close()
+		c.cmd.Wait()
+		os.RemoveAll(filepath.Dir(c.sock))
+	})
+}
@@ -0,0 +1,27 @@
+// +build windows
+
+package player
+
+import "errors"
+
+// MPV controls the mpv binary through its json ipc.
+type MPV struct {
+}
+
+func NewMPV(volume chan<- int, seek chan<- *Pos) *MPV {
+	return &MPV{}
+}
+
+func (m *MPV) SetSocketDir(dir string) {}
+
+func (m *MPV) Spawn(file string, params []Param) (chan Command, func(), error) {
+	return nil, nil, errors.New("Not supported")
+}
+
+func (m *MPV) Name() string {
+	return "mpv"
+}
+
+func (m *MPV) Supported() bool {
+	return false
+}
@@ -2,7 +2,10 @@ package player
 
 import (
 	"errors"
+	"fmt"
 	"os/exec"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,12 +22,43 @@ const (
 
 	ParamNoVideo Param = "no-video"
 	ParamSilent  Param = "silent"
+	ParamGain    Param = "gain"
+	ParamStart   Param = "start"
+	// ParamCrossfade fades in and out over the given duration,
+	// see Crossfader.
+	ParamCrossfade Param = "crossfade"
+
+	// SeekStep is the amount CmdSeekForward and CmdSeekBackward seek.
+	SeekStep = time.Second * 10
+	// VolumeStep is the amount CmdVolUp and CmdVolDown change the volume.
+	VolumeStep = 5
 )
 
 type Command int
 
+// Param is a player option, params that take a value
+// are formatted as name=value, see Param.With.
 type Param string
 
+func (p Param) With(value string) Param {
+	return Param(string(p) + "=" + value)
+}
+
+// Split returns the name and value of p.
+func (p Param) Split() (Param, string) {
+	s := strings.SplitN(string(p), "=", 2)
+	if len(s) == 1 {
+		return p, ""
+	}
+
+	return Param(s[0]), s[1]
+}
+
+// Gain returns a ParamGain that adjusts the volume by the given dB.
+func Gain(db float64) Param {
+	return ParamGain.With(strconv.FormatFloat(db, 'f', 2, 64))
+}
+
 type Pos struct {
 	Cur time.Duration
 	Dur time.Duration
@@ -45,12 +79,51 @@ func (p *Pos) Pct() float64 {
 	return pct
 }
 
+// Crossfade returns a ParamCrossfade with the given duration.
+func Crossfade(d time.Duration) Param {
+	return ParamCrossfade.With(strconv.FormatFloat(d.Seconds(), 'f', 1, 64))
+}
+
 type Player interface {
 	Name() string
 	Spawn(file string, params []Param) (chan Command, func(), error)
 	Supported() bool
 }
 
+// Appender is implemented by players that can queue a file so it
+// starts without a gap once the currently playing one ends.
+// Append returns an error if no file is playing.
+type Appender interface {
+	Append(file string, params []Param) (chan Command, func(), error)
+}
+
+// Crossfader is implemented by players that support ParamCrossfade.
+// SpawnFade is Spawn but also returns a channel that is closed as soon
+// as the file starts fading out, the next file should be spawned then.
+// The wait func only returns once the fade out ended.
+type Crossfader interface {
+	SpawnFade(file string, params []Param) (commands chan Command, fading <-chan struct{}, wait func(), err error)
+}
+
+// Seeker is implemented by players that can seek to an absolute
+// position in the file that is currently playing.
+type Seeker interface {
+	SeekTo(pos time.Duration) error
+}
+
+// VolumeKeeper is implemented by players that keep the volume set with
+// CmdVolUp and CmdVolDown for the files spawned after it, other players
+// start every file at their default volume.
+type VolumeKeeper interface {
+	KeepsVolume() bool
+}
+
+// ArgSetter is implemented by players that accept extra command line
+// arguments (or mpv options in the --name=value form for libmpv).
+type ArgSetter interface {
+	SetArgs(args []string)
+}
+
 func FindSupportedPlayer(players ...Player) (Player, error) {
 	for _, p := range players {
 		if p.Supported() {
@@ -61,9 +134,15 @@ func FindSupportedPlayer(players ...Player) (Player, error) {
 	return nil, errors.New("No supported player found")
 }
 
+// Start returns a ParamStart that starts playback at the given position.
+func Start(pos time.Duration) Param {
+	return ParamStart.With(strconv.FormatFloat(pos.Seconds(), 'f', 1, 64))
+}
+
 type GenericPlayer struct {
-	cmd        string
-	args       []string
+	cmd  string
+	args []string
+	// arguments for each Param, %s is replaced with its value.
 	paramMap   map[Param][]string
 	commandMap map[Command][]byte
 }
@@ -72,6 +151,10 @@ func (m *GenericPlayer) Name() string {
 	return m.cmd
 }
 
+func (m *GenericPlayer) SetArgs(args []string) {
+	m.args = args
+}
+
 func (m *GenericPlayer) Supported() bool {
 	return binaryInPath(m.cmd)
 }
@@ -81,15 +164,21 @@ func (m *GenericPlayer) Spawn(file string, params []Param) (
 	func(),
 	error,
 ) {
-	args := m.args
-	if args == nil {
-		args = make([]string, 0, len(params)+1)
-	}
+	args := make([]string, 0, len(m.args)+len(params)+1)
+	args = append(args, m.args...)
 	for _, p := range params {
-		if a, ok := m.paramMap[p]; ok {
-			args = append(args, a...)
+		p, v := p.Split()
+		a, ok := m.paramMap[p]
+		if !ok {
 			continue
 		}
+
+		for _, arg := range a {
+			if strings.Contains(arg, "%s") {
+				arg = fmt.Sprintf(arg, v)
+			}
+			args = append(args, arg)
+		}
 	}
 
 	args = append(args, file)
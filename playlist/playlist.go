@@ -35,10 +35,17 @@ type Playlist struct {
 	i        int
 	last     int
 	changed  bool
+	revision uint64
 	update   chan<- struct{}
 	scroll   int
 	scrolled bool
 	rand     bool
+	repeat   bool
+	link     string
+	// ids of the items synced from the linked playlist.
+	linked map[string]struct{}
+	// random indexes drawn ahead of time so Upcoming can predict them.
+	shuffled []int
 }
 
 func New(file string, size int, updates chan<- struct{}) *Playlist {
@@ -103,9 +110,15 @@ func (p *Playlist) Save(onlyIfChanged bool) (err error) {
 		}
 	}
 
-	err = os.Rename(tmp, p.file)
-	p.changed = err != nil
-	return err
+	if err = os.Rename(tmp, p.file); err != nil {
+		return err
+	}
+	if err = writeLinked(p.file, p.linked); err != nil {
+		return err
+	}
+
+	p.changed = false
+	return nil
 }
 
 func (p *Playlist) Load() error {
@@ -156,6 +169,8 @@ func (p *Playlist) Load() error {
 	}
 
 	p.list = list
+	p.link = readLink(p.file)
+	p.linked = readLinked(p.file)
 	p.i = index - 1
 	if p.i < 0 {
 		p.i = 0
@@ -164,12 +179,48 @@ func (p *Playlist) Load() error {
 	return nonCritErr
 }
 
+// Revision is incremented on every change to the queue, unlike the
+// updates channel it ignores superficial changes like scrolling.
+func (p *Playlist) Revision() uint64 {
+	p.sem.RLock()
+	r := p.revision
+	p.sem.RUnlock()
+	return r
+}
+
 func (p *Playlist) ToggleRandom() {
 	p.sem.Lock()
 	p.rand = !p.rand
+	p.shuffled = nil
+	p.updated(true)
 	p.sem.Unlock()
 }
 
+func (p *Playlist) ToggleRepeat() {
+	p.sem.Lock()
+	p.repeat = !p.repeat
+	p.updated(true)
+	select {
+	case p.d <- struct{}{}:
+	default:
+	}
+	p.sem.Unlock()
+}
+
+func (p *Playlist) Random() bool {
+	p.sem.RLock()
+	r := p.rand
+	p.sem.RUnlock()
+	return r
+}
+
+func (p *Playlist) Repeat() bool {
+	p.sem.RLock()
+	r := p.repeat
+	p.sem.RUnlock()
+	return r
+}
+
 func (p *Playlist) Add(cmd *command.Command) {
 	if cmd.Result() == nil {
 		return
@@ -199,6 +250,12 @@ func (p *Playlist) Del(indexes []int) {
 	sort.Sort(ixs)
 
 	p.sem.Lock()
+	p.del(ixs)
+	p.sem.Unlock()
+}
+
+// del removes the items at the sorted indexes ixs.
+func (p *Playlist) del(ixs ints) {
 	done := make(map[int]struct{}, len(ixs))
 	amount := 0
 	for _, ix := range ixs {
@@ -211,6 +268,9 @@ func (p *Playlist) Del(indexes []int) {
 			if p.i > ix && p.i > 0 {
 				p.i--
 			}
+			if p.last > ix+1 {
+				p.last--
+			}
 			p.list = append(p.list[:ix], p.list[ix+1:]...)
 		}
 
@@ -219,14 +279,13 @@ func (p *Playlist) Del(indexes []int) {
 	}
 
 	if amount != 0 {
+		p.shuffled = nil
 		p.updated(false)
 		select {
 		case p.d <- struct{}{}:
 		default:
 		}
 	}
-
-	p.sem.Unlock()
 }
 
 func (p *Playlist) List() []*command.Command {
@@ -341,12 +400,29 @@ func (p *Playlist) Truncate() {
 	p.sem.Lock()
 	p.list = make([]*command.Command, 0, cap(p.list))
 	p.i = 0
+	p.shuffled = nil
+	p.updated(false)
+	p.sem.Unlock()
+}
+
+// Replace replaces all items, the index is clamped to the new length.
+func (p *Playlist) Replace(list []*command.Command) {
+	p.sem.Lock()
+	p.list = list
+	if p.i > len(p.list) {
+		p.i = len(p.list)
+	}
+	p.shuffled = nil
 	p.updated(false)
 	p.sem.Unlock()
 }
 
 func (p *Playlist) Read() *command.Command {
 	p.sem.Lock()
+	if p.i >= len(p.list) && p.repeat && len(p.list) != 0 {
+		p.i = 0
+	}
+
 	if p.i >= len(p.list) {
 		p.sem.Unlock()
 		<-p.d
@@ -357,13 +433,71 @@ func (p *Playlist) Read() *command.Command {
 	p.i++
 	p.last = p.i
 	if p.rand {
-		p.i = rand.Intn(len(p.list))
+		p.i = p.draw(0)
+		p.shuffled = p.shuffled[1:]
 	}
 	p.updated(false)
 	p.sem.Unlock()
 	return r
 }
 
+// Peek returns the item the next call to Read will return,
+// or nil if Read would block.
+func (p *Playlist) Peek() *command.Command {
+	u := p.Upcoming(1)
+	if len(u) == 0 {
+		return nil
+	}
+
+	return u[0]
+}
+
+// Upcoming returns the next amount of items Read will return taking
+// shuffle and repeat into account. Items that are added or removed
+// in the meantime will obviously invalidate this prediction.
+func (p *Playlist) Upcoming(amount int) []*command.Command {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	l := make([]*command.Command, 0, amount)
+	if len(p.list) == 0 {
+		return l
+	}
+
+	i := p.i
+	for n := 0; len(l) < amount; n++ {
+		if n != 0 {
+			switch {
+			case p.rand:
+				i = p.draw(n - 1)
+			case n >= len(p.list):
+				return l
+			default:
+				i++
+			}
+		}
+
+		if i >= len(p.list) {
+			if !p.repeat {
+				return l
+			}
+			i = 0
+		}
+
+		l = append(l, p.list[i])
+	}
+
+	return l
+}
+
+// draw returns the nth upcoming random index.
+func (p *Playlist) draw(n int) int {
+	for len(p.shuffled) <= n {
+		p.shuffled = append(p.shuffled, rand.Intn(len(p.list)))
+	}
+
+	return p.shuffled[n]
+}
+
 func (p *Playlist) At(ix int) *command.Command {
 	p.sem.RLock()
 	if ix < 0 || ix >= len(p.list) {
@@ -519,6 +653,8 @@ func (p *Playlist) Move(from, to int) {
 			p.i++
 		}
 
+		p.shuffled = nil
+
 		p.updated(false)
 	}
 	p.sem.Unlock()
@@ -534,6 +670,7 @@ func (p *Playlist) index() int {
 func (p *Playlist) updated(superficial bool) {
 	if !superficial {
 		p.changed = true
+		p.revision++
 	}
 	p.update <- struct{}{}
 }
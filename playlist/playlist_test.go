@@ -0,0 +1,40 @@
+package playlist
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/search"
+)
+
+func TestRevision(t *testing.T) {
+	updates := make(chan struct{})
+	n := make(chan int)
+	go func() {
+		i := 0
+		for range updates {
+			i++
+		}
+		n <- i
+	}()
+
+	p := New("", 10, updates)
+	for i := 0; i < 3; i++ {
+		p.Add(command.New(nil).SetResult(search.NewYoutubeResult("video"+strconv.Itoa(i), "Video")))
+	}
+	if r := p.Revision(); r != 3 {
+		t.Errorf("revision %d after adding 3 items", r)
+	}
+
+	p.ScrollTo(2)
+	p.ResetScroll()
+	if r := p.Revision(); r != 3 {
+		t.Errorf("scrolling changed the revision to %d", r)
+	}
+
+	close(updates)
+	if i := <-n; i != 5 {
+		t.Errorf("%d updates, expected 5", i)
+	}
+}
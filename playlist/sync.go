@@ -0,0 +1,188 @@
+package playlist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/search"
+)
+
+// Diff lists the items added and removed by Sync.
+type Diff struct {
+	Added   []search.Result
+	Removed []search.Result
+}
+
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("+%d -%d", len(d.Added), len(d.Removed))
+}
+
+func linkFile(file string) string {
+	return file + ".link"
+}
+
+func linkedFile(file string) string {
+	return file + ".linked"
+}
+
+func readLink(file string) string {
+	d, err := ioutil.ReadFile(linkFile(file))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(d))
+}
+
+func readLinked(file string) map[string]struct{} {
+	d, err := ioutil.ReadFile(linkedFile(file))
+	if err != nil {
+		return nil
+	}
+
+	ids := make(map[string]struct{})
+	for _, id := range strings.Fields(string(d)) {
+		ids[id] = struct{}{}
+	}
+
+	return ids
+}
+
+func writeLinked(file string, ids map[string]struct{}) error {
+	if len(ids) == 0 {
+		err := os.Remove(linkedFile(file))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	list := make([]string, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	sort.Strings(list)
+
+	tmp := linkedFile(file) + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strings.Join(list, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, linkedFile(file))
+}
+
+// Link returns the id of the remote playlist this playlist is linked to.
+func (p *Playlist) Link() string {
+	p.sem.RLock()
+	l := p.link
+	p.sem.RUnlock()
+	return l
+}
+
+// SetLink links this playlist to a remote playlist id and stores it
+// next to the playlist file, an empty id removes the link.
+// Items synced from a previously linked playlist are no longer
+// considered to be part of it.
+func (p *Playlist) SetLink(id string) error {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	if id != p.link {
+		p.linked = nil
+		if err := writeLinked(p.file, nil); err != nil {
+			return err
+		}
+	}
+
+	p.link = id
+	if id == "" {
+		err := os.Remove(linkFile(p.file))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return ioutil.WriteFile(linkFile(p.file), []byte(id+"\n"), 0644)
+}
+
+// Sync appends the items of remote that are not in the playlist yet,
+// in the order they appear in remote, and removes the items that were
+// synced earlier but are no longer in remote if remove is true.
+// Items added by hand, the order of the remaining items and the current
+// item are left untouched.
+func (p *Playlist) Sync(remote []search.Result, remove bool) Diff {
+	var diff Diff
+	ids := make(map[string]struct{}, len(remote))
+	for _, r := range remote {
+		ids[r.ID()] = struct{}{}
+	}
+
+	p.sem.Lock()
+	defer p.sem.Unlock()
+
+	local := make(map[string]struct{}, len(p.list))
+	var del ints
+	cur := p.index()
+	for i, c := range p.list {
+		r := c.Result()
+		if r == nil {
+			continue
+		}
+		local[r.ID()] = struct{}{}
+		_, upstream := ids[r.ID()]
+		_, linked := p.linked[r.ID()]
+		if remove && linked && !upstream && i != cur {
+			del = append(del, i)
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+
+	p.del(del)
+
+	for _, r := range remote {
+		if _, ok := local[r.ID()]; ok {
+			continue
+		}
+		local[r.ID()] = struct{}{}
+		p.list = append(p.list, command.New(nil).SetResult(r))
+		diff.Added = append(diff.Added, r)
+	}
+
+	// Remember which items came from the remote playlist, including the
+	// ones removed from it that were kept.
+	linked := make(map[string]struct{}, len(p.list))
+	for _, c := range p.list {
+		r := c.Result()
+		if r == nil {
+			continue
+		}
+		_, upstream := ids[r.ID()]
+		_, was := p.linked[r.ID()]
+		if upstream || was {
+			linked[r.ID()] = struct{}{}
+		}
+	}
+	if !reflect.DeepEqual(linked, p.linked) {
+		p.linked = linked
+		p.changed = true
+	}
+
+	if len(diff.Added) != 0 {
+		p.updated(false)
+		select {
+		case p.d <- struct{}{}:
+		default:
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,125 @@
+package playlist
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/search"
+)
+
+func ids(results []search.Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID()
+	}
+
+	return ids
+}
+
+func TestSync(t *testing.T) {
+	// serves playlist-<version>.html
+	var version int32 = 1
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/playlist" || r.URL.Query().Get("list") != "PLtest" {
+			http.NotFound(w, r)
+			return
+		}
+		v := atomic.LoadInt32(&version)
+		http.ServeFile(w, r, filepath.Join("testdata", "playlist-"+string('0'+v)+".html"))
+	}))
+	defer s.Close()
+
+	y, err := search.NewYoutube(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.SetBaseURL(s.URL)
+
+	dir, err := ioutil.TempDir("", "ym-playlist-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	updates := make(chan struct{})
+	go func() {
+		for range updates {
+		}
+	}()
+	defer close(updates)
+
+	p := New(filepath.Join(dir, "playlist"), 10, updates)
+	p.Add(command.New(nil).SetResult(search.NewYoutubeResult("local", "Local")))
+	p.SetIndex(1)
+
+	remote, err := y.Playlist("PLtest", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := p.Sync(remote, true)
+	if exp := []string{"video01", "video02", "video03", "video04"}; !reflect.DeepEqual(ids(diff.Added), exp) {
+		t.Errorf("added %v, expected %v", ids(diff.Added), exp)
+	}
+	// the current item is never removed
+	if len(diff.Removed) != 0 {
+		t.Errorf("removed %v, expected nothing", ids(diff.Removed))
+	}
+
+	if diff = p.Sync(remote, true); !diff.Empty() {
+		t.Errorf("expected no changes syncing twice, got %s", diff)
+	}
+
+	atomic.StoreInt32(&version, 2)
+	remote, err = y.Playlist("PLtest", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff = p.Sync(remote, false)
+	if exp := []string{"video05", "video06"}; !reflect.DeepEqual(ids(diff.Added), exp) {
+		t.Errorf("added %v, expected %v", ids(diff.Added), exp)
+	}
+	if len(diff.Removed) != 0 {
+		t.Errorf("removed %v without remove", ids(diff.Removed))
+	}
+
+	// the synced items survive a restart
+	if err := p.Save(false); err != nil {
+		t.Fatal(err)
+	}
+	p = New(filepath.Join(dir, "playlist"), 10, updates)
+	if err := p.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetIndex(3)
+	diff = p.Sync(remote, true)
+	// items added by hand are not removed
+	if exp := []string{"video04"}; !reflect.DeepEqual(ids(diff.Removed), exp) {
+		t.Errorf("removed %v, expected %v", ids(diff.Removed), exp)
+	}
+
+	exp := []string{"local", "video01", "video02", "video03", "video05", "video06"}
+	if got := ids(p.ResultList()); !reflect.DeepEqual(got, exp) {
+		t.Errorf("playlist %v, expected %v", got, exp)
+	}
+	if cur := p.At(p.Index()).Result().ID(); cur != "video02" {
+		t.Errorf("current item %s, expected video02", cur)
+	}
+
+	// linking another playlist forgets where the items came from
+	if err := p.SetLink("PLother"); err != nil {
+		t.Fatal(err)
+	}
+	if diff = p.Sync(remote[:1], true); !diff.Empty() {
+		t.Errorf("expected no changes after relinking, got %s", diff)
+	}
+}
@@ -0,0 +1,116 @@
+package resume
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Positions remembers playback positions by result id.
+// Positions is thread safe
+type Positions struct {
+	file    string
+	sem     sync.RWMutex
+	pos     map[string]time.Duration
+	changed bool
+}
+
+func New(file string) *Positions {
+	return &Positions{file: file, pos: make(map[string]time.Duration)}
+}
+
+func (p *Positions) Load() error {
+	f, err := os.Open(p.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	raw := make(map[string]float64)
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		return err
+	}
+
+	p.sem.Lock()
+	for id, s := range raw {
+		p.pos[id] = time.Duration(s * float64(time.Second))
+	}
+	p.sem.Unlock()
+
+	return nil
+}
+
+func (p *Positions) Save(onlyIfChanged bool) (err error) {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	if onlyIfChanged && !p.changed {
+		return nil
+	}
+
+	raw := make(map[string]float64, len(p.pos))
+	for id, d := range p.pos {
+		raw[id] = d.Seconds()
+	}
+
+	tmp := p.file + "." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err = json.NewEncoder(f).Encode(raw); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, p.file); err != nil {
+		return err
+	}
+
+	p.changed = false
+	return nil
+}
+
+// Get returns the stored position for id or 0 if there is none.
+func (p *Positions) Get(id string) time.Duration {
+	p.sem.RLock()
+	d := p.pos[id]
+	p.sem.RUnlock()
+	return d
+}
+
+func (p *Positions) Has(id string) bool {
+	p.sem.RLock()
+	_, ok := p.pos[id]
+	p.sem.RUnlock()
+	return ok
+}
+
+func (p *Positions) Set(id string, pos time.Duration) {
+	p.sem.Lock()
+	if p.pos[id] != pos {
+		p.pos[id] = pos
+		p.changed = true
+	}
+	p.sem.Unlock()
+}
+
+func (p *Positions) Del(id string) {
+	p.sem.Lock()
+	if _, ok := p.pos[id]; ok {
+		delete(p.pos, id)
+		p.changed = true
+	}
+	p.sem.Unlock()
+}
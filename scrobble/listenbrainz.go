@@ -0,0 +1,145 @@
+package scrobble
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const ListenBrainzURL = "https://api.listenbrainz.org"
+
+// StatusError is returned for requests the server rejected.
+type StatusError struct {
+	Code int
+	Msg  string
+}
+
+func (s *StatusError) Error() string {
+	return fmt.Sprintf("Scrobble failed: %d %s", s.Code, s.Msg)
+}
+
+// Permanent reports whether retrying won't help.
+// Authentication errors aren't, the token might be fixed.
+func (s *StatusError) Permanent() bool {
+	switch s.Code {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return s.Code >= 400 && s.Code < 500
+}
+
+// ListenBrainz submits listens to a ListenBrainz compatible server.
+type ListenBrainz struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func NewListenBrainz(base, token string, timeout time.Duration) *ListenBrainz {
+	return &ListenBrainz{
+		base:  strings.TrimRight(base, "/"),
+		token: token,
+		http:  &http.Client{Timeout: timeout},
+	}
+}
+
+type lbInfo struct {
+	DurationMS       int64  `json:"duration_ms,omitempty"`
+	OriginURL        string `json:"origin_url,omitempty"`
+	MediaPlayer      string `json:"media_player"`
+	SubmissionClient string `json:"submission_client"`
+	MusicService     string `json:"music_service,omitempty"`
+}
+
+type lbMeta struct {
+	Artist string `json:"artist_name"`
+	Track  string `json:"track_name"`
+	Info   lbInfo `json:"additional_info"`
+}
+
+type lbListen struct {
+	ListenedAt int64  `json:"listened_at,omitempty"`
+	Meta       lbMeta `json:"track_metadata"`
+}
+
+type lbSubmission struct {
+	Type    string      `json:"listen_type"`
+	Payload []*lbListen `json:"payload"`
+}
+
+func newLBListen(l *Listen, at bool) *lbListen {
+	lb := &lbListen{
+		Meta: lbMeta{
+			Artist: l.Artist,
+			Track:  l.Track,
+			Info: lbInfo{
+				DurationMS:       l.Duration.Milliseconds(),
+				OriginURL:        l.URL,
+				MediaPlayer:      "ym",
+				SubmissionClient: "ym",
+				MusicService:     "youtube.com",
+			},
+		},
+	}
+	if at {
+		lb.ListenedAt = l.At.Unix()
+	}
+
+	return lb
+}
+
+func (lb *ListenBrainz) NowPlaying(l *Listen) error {
+	return lb.submit(&lbSubmission{"playing_now", []*lbListen{newLBListen(l, false)}})
+}
+
+func (lb *ListenBrainz) Submit(listens []*Listen) error {
+	s := &lbSubmission{Type: "import"}
+	if len(listens) == 1 {
+		s.Type = "single"
+	}
+	for _, l := range listens {
+		s.Payload = append(s.Payload, newLBListen(l, true))
+	}
+
+	return lb.submit(s)
+}
+
+func (lb *ListenBrainz) submit(s *lbSubmission) error {
+	body, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, lb.base+"/1/submit-listens", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+lb.token)
+
+	res, err := lb.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusOK {
+		io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+
+	var msg struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(&msg)
+	if msg.Error == "" {
+		msg.Error = http.StatusText(res.StatusCode)
+	}
+
+	return &StatusError{res.StatusCode, msg.Error}
+}
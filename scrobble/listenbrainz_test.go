@@ -0,0 +1,142 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stub is a ListenBrainz server that answers submissions with status,
+// or 400 if they contain the track reject.
+type stub struct {
+	sem         sync.Mutex
+	status      int
+	reject      string
+	submissions []lbSubmission
+}
+
+func newStub(t *testing.T) (*stub, *httptest.Server) {
+	s := &stub{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/1/submit-listens" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if h := r.Header.Get("Authorization"); h != "Token token" {
+			t.Errorf("authorization %q", h)
+		}
+
+		var sub lbSubmission
+		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+			t.Error(err)
+		}
+
+		s.sem.Lock()
+		s.submissions = append(s.submissions, sub)
+		status := s.status
+		for _, l := range sub.Payload {
+			if l.Meta.Track == s.reject {
+				status = http.StatusBadRequest
+			}
+		}
+		s.sem.Unlock()
+
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			json.NewEncoder(w).Encode(map[string]string{"error": "stub error"})
+		}
+	}))
+
+	return s, srv
+}
+
+func (s *stub) setStatus(status int) {
+	s.sem.Lock()
+	s.status = status
+	s.sem.Unlock()
+}
+
+func (s *stub) setReject(track string) {
+	s.sem.Lock()
+	s.reject = track
+	s.sem.Unlock()
+}
+
+func (s *stub) received() []lbSubmission {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	return append([]lbSubmission{}, s.submissions...)
+}
+
+func TestListenBrainz(t *testing.T) {
+	s, srv := newStub(t)
+	defer srv.Close()
+
+	lb := NewListenBrainz(srv.URL+"/", "token", time.Second*5)
+	l := &Listen{
+		Artist:   "Artist",
+		Track:    "Track",
+		Duration: time.Minute * 3,
+		URL:      "https://www.youtube.com/watch?v=video",
+		At:       time.Unix(1588334400, 0),
+	}
+
+	if err := lb.NowPlaying(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := lb.Submit([]*Listen{l}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lb.Submit([]*Listen{l, l}); err != nil {
+		t.Fatal(err)
+	}
+
+	subs := s.received()
+	if len(subs) != 3 {
+		t.Fatalf("%d submissions, expected 3", len(subs))
+	}
+	for i, typ := range []string{"playing_now", "single", "import"} {
+		if subs[i].Type != typ {
+			t.Errorf("submission %d is %s, expected %s", i, subs[i].Type, typ)
+		}
+	}
+	if at := subs[0].Payload[0].ListenedAt; at != 0 {
+		t.Errorf("playing now listened at %d", at)
+	}
+
+	p := subs[1].Payload[0]
+	if p.ListenedAt != 1588334400 ||
+		p.Meta.Artist != "Artist" ||
+		p.Meta.Track != "Track" ||
+		p.Meta.Info.DurationMS != 180000 ||
+		p.Meta.Info.OriginURL != l.URL {
+		t.Errorf("unexpected listen %+v", p)
+	}
+
+	s.setStatus(http.StatusUnauthorized)
+	err := lb.Submit([]*Listen{l})
+	serr, ok := err.(*StatusError)
+	if !ok || serr.Code != http.StatusUnauthorized || serr.Msg != "stub error" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestStatusErrorPermanent(t *testing.T) {
+	for code, permanent := range map[int]bool{
+		http.StatusBadRequest:          true,
+		http.StatusUnauthorized:        false,
+		http.StatusForbidden:           false,
+		http.StatusNotFound:            true,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+		http.StatusServiceUnavailable:  false,
+	} {
+		if p := (&StatusError{Code: code}).Permanent(); p != permanent {
+			t.Errorf("%d permanent: %t", code, p)
+		}
+	}
+}
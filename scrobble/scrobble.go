@@ -0,0 +1,287 @@
+package scrobble
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MinDuration is the minimum length of a track to be scrobbled.
+	MinDuration = time.Second * 30
+	// MaxPlayed is played time after which a track is always scrobbled.
+	MaxPlayed = time.Minute * 4
+
+	batchSize     = 100
+	retryInterval = time.Minute
+)
+
+// Listen is a played track.
+type Listen struct {
+	Artist   string        `json:"artist"`
+	Track    string        `json:"track"`
+	Duration time.Duration `json:"duration"`
+	URL      string        `json:"url"`
+	At       time.Time     `json:"at"`
+}
+
+// Valid returns an error if l is incomplete and would be rejected,
+// e.g.: when the artist could not be looked up.
+func (l *Listen) Valid() error {
+	switch {
+	case strings.TrimSpace(l.Artist) == "":
+		return fmt.Errorf("Not scrobbling %s: no artist", l.URL)
+	case strings.TrimSpace(l.Track) == "":
+		return fmt.Errorf("Not scrobbling %s: no track", l.URL)
+	case l.At.IsZero():
+		return fmt.Errorf("Not scrobbling %s: no time", l.URL)
+	}
+
+	return nil
+}
+
+// Scrobblable reports whether a track of duration d that played for
+// played should be scrobbled: more than half of it or MaxPlayed.
+// Tracks of unknown duration (0) need MaxPlayed.
+func Scrobblable(d, played time.Duration) bool {
+	if d != 0 && d < MinDuration {
+		return false
+	}
+
+	return played >= MaxPlayed || (d != 0 && played >= d/2)
+}
+
+// Client submits listens to a server.
+type Client interface {
+	NowPlaying(l *Listen) error
+	Submit(listens []*Listen) error
+}
+
+// Scrobbler submits listens in the background. Listens that can't be
+// submitted are queued in file and retried every minute.
+// Scrobbler is thread safe
+type Scrobbler struct {
+	client Client
+	file   string
+	errs   func(error)
+	jobs   chan func()
+
+	sem     sync.Mutex
+	pending []*Listen
+}
+
+func New(client Client, file string) *Scrobbler {
+	s := &Scrobbler{
+		client: client,
+		file:   file,
+		errs:   func(error) {},
+		jobs:   make(chan func(), 100),
+	}
+	go s.work()
+
+	return s
+}
+
+// SetErrorHandler sets the function submission errors are reported to.
+func (s *Scrobbler) SetErrorHandler(fn func(error)) {
+	s.errs = fn
+}
+
+// Load reads the listens that were queued in a previous session.
+func (s *Scrobbler) Load() error {
+	f, err := os.Open(s.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	var pending []*Listen
+	if err := json.NewDecoder(f).Decode(&pending); err != nil {
+		return err
+	}
+
+	s.sem.Lock()
+	s.pending = append(pending, s.pending...)
+	s.sem.Unlock()
+	return nil
+}
+
+func (s *Scrobbler) save() error {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	if len(s.pending) == 0 {
+		err := os.Remove(s.file)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	tmp := s.file + "." + strconv.FormatInt(time.Now().UnixNano(), 36)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(s.pending); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, s.file)
+}
+
+func (s *Scrobbler) queue(fn func()) {
+	select {
+	case s.jobs <- fn:
+	default:
+	}
+}
+
+// NowPlaying announces l, it is not retried.
+func (s *Scrobbler) NowPlaying(l *Listen) {
+	if err := l.Valid(); err != nil {
+		s.errs(err)
+		return
+	}
+
+	s.queue(func() {
+		if err := s.client.NowPlaying(l); err != nil {
+			s.errs(err)
+		}
+	})
+}
+
+// Scrobble queues l and submits it with any other pending listens.
+func (s *Scrobbler) Scrobble(l *Listen) {
+	if err := l.Valid(); err != nil {
+		s.errs(err)
+		return
+	}
+
+	s.sem.Lock()
+	s.pending = append(s.pending, l)
+	s.sem.Unlock()
+	if err := s.save(); err != nil {
+		s.errs(err)
+	}
+
+	s.queue(s.flush)
+}
+
+// Pending returns the amount of queued listens.
+func (s *Scrobbler) Pending() int {
+	s.sem.Lock()
+	defer s.sem.Unlock()
+	return len(s.pending)
+}
+
+func (s *Scrobbler) flush() {
+	if err := s.Flush(); err != nil {
+		s.errs(err)
+	}
+}
+
+// Flush submits the queued listens. Listens the server rejects are
+// dropped, others are kept if the server can't be reached or the token
+// is not accepted.
+func (s *Scrobbler) Flush() error {
+	var invalid error
+	s.sem.Lock()
+	pending := make([]*Listen, 0, len(s.pending))
+	for _, l := range s.pending {
+		if err := l.Valid(); err != nil {
+			invalid = err
+			continue
+		}
+		pending = append(pending, l)
+	}
+	dropped := len(pending) != len(s.pending)
+	s.pending = pending
+	s.sem.Unlock()
+	if dropped {
+		if err := s.save(); err != nil {
+			return err
+		}
+	}
+
+	for {
+		s.sem.Lock()
+		batch := s.pending
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		s.sem.Unlock()
+		if len(batch) == 0 {
+			return invalid
+		}
+
+		n, err := s.submit(batch)
+		if n != 0 {
+			s.sem.Lock()
+			s.pending = s.pending[n:]
+			s.sem.Unlock()
+			if serr := s.save(); serr != nil {
+				return serr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// submit submits batch and returns the amount of listens that were
+// either accepted or rejected. If the server rejects a batch, its listens
+// are submitted one by one so only the rejected ones are dropped.
+func (s *Scrobbler) submit(batch []*Listen) (int, error) {
+	err := s.client.Submit(batch)
+	serr, ok := err.(*StatusError)
+	switch {
+	case err == nil:
+		return len(batch), nil
+	case !ok || !serr.Permanent():
+		return 0, err
+	case len(batch) == 1:
+		return 1, err
+	}
+
+	var rejected error
+	for i, l := range batch {
+		n, err := s.submit([]*Listen{l})
+		if n == 0 {
+			return i, err
+		}
+		if err != nil {
+			rejected = err
+		}
+	}
+
+	return len(batch), rejected
+}
+
+func (s *Scrobbler) work() {
+	tick := time.NewTicker(retryInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case fn := <-s.jobs:
+			fn()
+		case <-tick.C:
+			if s.Pending() != 0 {
+				s.flush()
+			}
+		}
+	}
+}
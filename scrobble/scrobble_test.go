@@ -0,0 +1,266 @@
+package scrobble
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScrobblable(t *testing.T) {
+	tests := []struct {
+		d, played time.Duration
+		ok        bool
+	}{
+		{time.Minute * 3, time.Second * 90, true},
+		{time.Minute * 3, time.Second * 89, false},
+		{time.Minute * 10, MaxPlayed, true},
+		{time.Minute * 10, MaxPlayed - time.Second, false},
+		{0, MaxPlayed, true},
+		{0, time.Minute * 3, false},
+		{MinDuration, MinDuration / 2, true},
+		{MinDuration - time.Second, MinDuration, false},
+	}
+
+	for _, test := range tests {
+		if ok := Scrobblable(test.d, test.played); ok != test.ok {
+			t.Errorf("%s of %s: %t, expected %t", test.played, test.d, ok, test.ok)
+		}
+	}
+}
+
+// pending copies testdata/pending.json to a temporary directory.
+func pending(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ym-scrobble-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "pending.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "scrobbles")
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+func TestLoad(t *testing.T) {
+	file, done := pending(t)
+	defer done()
+
+	s := New(nil, file)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pending() != 2 {
+		t.Fatalf("%d pending, expected 2", s.Pending())
+	}
+
+	l := s.pending[0]
+	if l.Artist != "Artist" ||
+		l.Track != "First" ||
+		l.Duration != time.Minute*3 ||
+		!l.At.Equal(time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected listen %+v", l)
+	}
+
+	if err := New(nil, file+".missing").Load(); err != nil {
+		t.Errorf("missing queue: %s", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		status      int
+		err         bool
+		pending     int
+		submissions int
+	}{
+		{http.StatusOK, false, 0, 1},
+		{http.StatusInternalServerError, true, 2, 1},
+		{http.StatusTooManyRequests, true, 2, 1},
+		{http.StatusUnauthorized, true, 2, 1},
+		{http.StatusForbidden, true, 2, 1},
+		// the batch and then each listen is rejected
+		{http.StatusBadRequest, true, 0, 3},
+	}
+
+	for _, test := range tests {
+		file, done := pending(t)
+		stub, srv := newStub(t)
+		stub.setStatus(test.status)
+
+		s := New(NewListenBrainz(srv.URL, "token", time.Second*5), file)
+		if err := s.Load(); err != nil {
+			t.Fatal(err)
+		}
+
+		err := s.Flush()
+		if (err != nil) != test.err {
+			t.Errorf("%d: unexpected error %v", test.status, err)
+		}
+		if s.Pending() != test.pending {
+			t.Errorf("%d: %d pending, expected %d", test.status, s.Pending(), test.pending)
+		}
+		if exists(file) != (test.pending != 0) {
+			t.Errorf("%d: queue file exists: %t", test.status, exists(file))
+		}
+		if n := len(stub.received()); n != test.submissions {
+			t.Errorf("%d: %d submissions, expected %d", test.status, n, test.submissions)
+		}
+
+		srv.Close()
+		done()
+	}
+}
+
+func TestFlushUnreachable(t *testing.T) {
+	file, done := pending(t)
+	defer done()
+
+	_, srv := newStub(t)
+	srv.Close()
+
+	s := New(NewListenBrainz(srv.URL, "token", time.Second*5), file)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err == nil {
+		t.Error("expected an error")
+	}
+	if s.Pending() != 2 || !exists(file) {
+		t.Errorf("%d pending, expected the queue to be kept", s.Pending())
+	}
+}
+
+func TestFlushRejected(t *testing.T) {
+	file, done := pending(t)
+	defer done()
+
+	stub, srv := newStub(t)
+	defer srv.Close()
+	stub.setReject("First")
+
+	s := New(NewListenBrainz(srv.URL, "token", time.Second*5), file)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	// e.g.: the info could not be fetched
+	s.pending = append(s.pending, &Listen{Track: "Third", At: time.Now()})
+
+	if err := s.Flush(); err == nil {
+		t.Error("expected an error")
+	}
+	if s.Pending() != 0 || exists(file) {
+		t.Errorf("%d pending, expected none", s.Pending())
+	}
+
+	var submitted []string
+	for _, sub := range stub.received() {
+		for _, l := range sub.Payload {
+			submitted = append(submitted, l.Meta.Track)
+		}
+	}
+	// the invalid listen is never submitted, only the rejected one is dropped
+	exp := []string{"First", "Second", "First", "Second"}
+	if !reflect.DeepEqual(submitted, exp) {
+		t.Errorf("submitted %v, expected %v", submitted, exp)
+	}
+}
+
+func TestScrobbleInvalid(t *testing.T) {
+	stub, srv := newStub(t)
+	defer srv.Close()
+
+	errs := make(chan error, 2)
+	s := New(NewListenBrainz(srv.URL, "token", time.Second*5), "")
+	s.SetErrorHandler(func(err error) { errs <- err })
+
+	l := &Listen{Track: "Track", URL: "https://www.youtube.com/watch?v=video", At: time.Now()}
+	s.NowPlaying(l)
+	s.Scrobble(l)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err.Error() != "Not scrobbling "+l.URL+": no artist" {
+			t.Errorf("unexpected error %s", err)
+		}
+	}
+	if s.Pending() != 0 || len(stub.received()) != 0 {
+		t.Error("invalid listen was queued")
+	}
+}
+
+func TestFlushBatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ym-scrobble-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stub, srv := newStub(t)
+	defer srv.Close()
+
+	s := New(NewListenBrainz(srv.URL, "token", time.Second*5), filepath.Join(dir, "scrobbles"))
+	for i := 0; i < batchSize+50; i++ {
+		s.pending = append(s.pending, &Listen{Artist: "Artist", Track: "Track", At: time.Now()})
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	subs := stub.received()
+	if len(subs) != 2 || len(subs[0].Payload) != batchSize || len(subs[1].Payload) != 50 {
+		t.Errorf("unexpected batches %d", len(subs))
+	}
+}
+
+func TestScrobbleOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ym-scrobble-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "scrobbles")
+
+	stub, srv := newStub(t)
+	defer srv.Close()
+	stub.setStatus(http.StatusServiceUnavailable)
+
+	errs := make(chan error, 1)
+	s := New(NewListenBrainz(srv.URL, "token", time.Second*5), file)
+	s.SetErrorHandler(func(err error) { errs <- err })
+	s.Scrobble(&Listen{Artist: "Artist", Track: "Track", At: time.Now()})
+
+	select {
+	case <-errs:
+	case <-time.After(time.Second * 5):
+		t.Fatal("the submission did not fail")
+	}
+
+	// next session
+	stub.setStatus(http.StatusOK)
+	s = New(NewListenBrainz(srv.URL, "token", time.Second*5), file)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pending() != 1 {
+		t.Fatalf("%d pending, expected the offline listen", s.Pending())
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s.Pending() != 0 || exists(file) {
+		t.Error("the queue was not emptied")
+	}
+}
@@ -0,0 +1,38 @@
+package scrobble
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	titleNoise = regexp.MustCompile(
+		`(?i)\s*[\(\[][^\)\]]*(official|video|audio|lyric|visuali[sz]er|hd|hq|4k|remaster|explicit|clip)[^\)\]]*[\)\]]`,
+	)
+	titleSeps   = []string{" - ", " – ", " — ", " -- ", " ~ ", " | "}
+	authorNoise = []string{" - Topic", "VEVO", "Official"}
+)
+
+// ParseTitle guesses the artist and track from a video title like
+// "Artist - Track (Official Video)", falling back to the uploader
+// (without " - Topic" or "VEVO") as the artist.
+func ParseTitle(title, author string) (artist, track string) {
+	title = strings.TrimSpace(titleNoise.ReplaceAllString(title, ""))
+	for _, sep := range titleSeps {
+		if i := strings.Index(title, sep); i > 0 {
+			artist = strings.TrimSpace(title[:i])
+			track = strings.TrimSpace(title[i+len(sep):])
+			break
+		}
+	}
+
+	if artist == "" || track == "" {
+		artist, track = author, title
+		for _, n := range authorNoise {
+			artist = strings.TrimSuffix(artist, n)
+		}
+		artist = strings.TrimSpace(artist)
+	}
+
+	return artist, strings.Trim(track, `"'“”`)
+}
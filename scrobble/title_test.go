@@ -0,0 +1,34 @@
+package scrobble
+
+import "testing"
+
+func TestParseTitle(t *testing.T) {
+	tests := []struct {
+		title, author string
+		artist, track string
+	}{
+		{"Artist - Track (Official Video)", "Label", "Artist", "Track"},
+		{"Artist – Track (Lyric Video) [4K]", "Label", "Artist", "Track"},
+		{"Artist | Track (Live)", "Label", "Artist", "Track (Live)"},
+		{"Artist ~ \"Track\"", "Label", "Artist", "Track"},
+		{"Track [HD]", "Artist - Topic", "Artist", "Track"},
+		{"Track (Official Audio)", "ArtistVEVO", "Artist", "Track"},
+		{"Track - ", "Artist", "Artist", "Track -"},
+		{"Track", "Artist", "Artist", "Track"},
+	}
+
+	for _, test := range tests {
+		artist, track := ParseTitle(test.title, test.author)
+		if artist != test.artist || track != test.track {
+			t.Errorf(
+				"%q by %q: got %q - %q, expected %q - %q",
+				test.title,
+				test.author,
+				artist,
+				track,
+				test.artist,
+				test.track,
+			)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chapter of an item.
+type Chapter struct {
+	Title string
+	Start time.Duration
+}
+
+var (
+	chapterTimestamp = regexp.MustCompile(`(?:^|[^\d:])((?:(\d{1,2}):)?(\d{1,2}):(\d{2}))(?:$|[^\d:])`)
+	chapterTrim      = " \t-–—|:.,()[]•*>"
+	chapterNumber    = regexp.MustCompile(`^\d{1,3}[.)]\s+`)
+)
+
+// ParseChapters parses timestamps in a description, e.g.: "00:00 Intro".
+// Like youtube it requires at least 3 chapters in ascending order,
+// starting at 0:00. Timestamps beyond duration are ignored
+// if duration is non zero.
+func ParseChapters(description string, duration time.Duration) []Chapter {
+	var chapters []Chapter
+	for _, line := range strings.Split(description, "\n") {
+		m := chapterTimestamp.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+
+		h, _ := strconv.Atoi(submatch(line, m, 2))
+		min, _ := strconv.Atoi(submatch(line, m, 3))
+		sec, _ := strconv.Atoi(submatch(line, m, 4))
+		if sec >= 60 || (h != 0 && min >= 60) {
+			continue
+		}
+		start := time.Duration(h)*time.Hour +
+			time.Duration(min)*time.Minute +
+			time.Duration(sec)*time.Second
+
+		if duration > 0 && start >= duration {
+			continue
+		}
+		if len(chapters) != 0 && start <= chapters[len(chapters)-1].Start {
+			continue
+		}
+		if len(chapters) == 0 && start != 0 {
+			continue
+		}
+
+		title := line[:m[2]] + " " + line[m[3]:]
+		title = strings.Trim(chapterNumber.ReplaceAllString(strings.Trim(title, chapterTrim), ""), chapterTrim)
+		chapters = append(chapters, Chapter{title, start})
+	}
+
+	if len(chapters) < 3 {
+		return nil
+	}
+
+	return chapters
+}
+
+func submatch(s string, m []int, i int) string {
+	if m[2*i] < 0 {
+		return ""
+	}
+
+	return s[m[2*i]:m[2*i+1]]
+}
+
+// ShiftChapters returns chapters moved d earlier, e.g.: for audio that
+// had d trimmed from its start.
+func ShiftChapters(chapters []Chapter, d time.Duration) []Chapter {
+	if d == 0 || len(chapters) == 0 {
+		return chapters
+	}
+
+	shifted := make([]Chapter, len(chapters))
+	for i, c := range chapters {
+		if c.Start -= d; c.Start < 0 {
+			c.Start = 0
+		}
+		shifted[i] = c
+	}
+
+	return shifted
+}
+
+var (
+	ytChapterData    = regexp.MustCompile(`(?:ytInitialData|ytInitialPlayerResponse)"?\]?\s*=\s*\{`)
+	ytChapterTimeout = time.Second * 10
+)
+
+// ChapterFetcher is implemented by infos that can fetch chapters that are
+// not part of the info itself, e.g.: the chapter markers of a player.
+type ChapterFetcher interface {
+	ChaptersContext(ctx context.Context) ([]Chapter, error)
+}
+
+// FetchChapters returns the chapters of i, fetched if i is a ChapterFetcher.
+func FetchChapters(ctx context.Context, i Info) ([]Chapter, error) {
+	if f, ok := i.(ChapterFetcher); ok {
+		return f.ChaptersContext(ctx)
+	}
+
+	return i.Chapters(), nil
+}
+
+// fetchChapterMarkers fetches the chapter markers of the player on the
+// watch page at u.
+func fetchChapterMarkers(ctx context.Context, u *url.URL) ([]Chapter, error) {
+	ctx, cancel := context.WithTimeout(ctx, ytChapterTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching chapters failed: %s", res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ytChapterMarkers(body), nil
+}
+
+// ytChapterMarkers returns the chapters shown in the player, which youtube
+// also generates for items without timestamps in the description.
+func ytChapterMarkers(page []byte) []Chapter {
+	seen := make(map[time.Duration]struct{})
+	var chapters []Chapter
+	var s func(key string, i interface{})
+	s = func(key string, i interface{}) {
+		switch v := i.(type) {
+		case map[string]interface{}:
+			if key == "chapterRenderer" {
+				ms, ok := v["timeRangeStartMillis"].(float64)
+				start := time.Duration(ms) * time.Millisecond
+				if _, dup := seen[start]; ok && !dup {
+					seen[start] = struct{}{}
+					chapters = append(chapters, Chapter{ytText(v["title"]), start})
+				}
+				return
+			}
+			for k, it := range v {
+				s(k, it)
+			}
+		case []interface{}:
+			for _, it := range v {
+				s(key, it)
+			}
+		}
+	}
+
+	for _, m := range ytChapterData.FindAllIndex(page, -1) {
+		var data interface{}
+		if err := json.NewDecoder(bytes.NewReader(page[m[1]-1:])).Decode(&data); err != nil {
+			continue
+		}
+		s("", data)
+	}
+
+	sort.Slice(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+	if len(chapters) == 0 || chapters[0].Start != 0 {
+		return nil
+	}
+
+	return chapters
+}
+
+// ChapterAt returns the index of the chapter at pos, -1 if there are none.
+func ChapterAt(chapters []Chapter, pos time.Duration) int {
+	for i := len(chapters) - 1; i >= 0; i-- {
+		if pos >= chapters[i].Start {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,124 @@
+package search
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseChapters(t *testing.T) {
+	desc := `Tracklist:
+00:00 Intro
+1. 02:05 - Second
+3:10 Third
+9:99 not a timestamp
+1:02:03 beyond the duration`
+
+	exp := []Chapter{
+		{"Intro", 0},
+		{"Second", 2*time.Minute + 5*time.Second},
+		{"Third", 3*time.Minute + 10*time.Second},
+	}
+	if c := ParseChapters(desc, time.Hour); !reflect.DeepEqual(c, exp) {
+		t.Errorf("%v, expected %v", c, exp)
+	}
+
+	if c := ParseChapters("00:00 a\n01:00 b", 0); c != nil {
+		t.Errorf("expected less than 3 chapters to be ignored, got %v", c)
+	}
+	if c := ParseChapters("00:10 a\n01:00 b\n02:00 c", 0); c != nil {
+		t.Errorf("expected chapters not starting at 0:00 to be ignored, got %v", c)
+	}
+}
+
+func TestChapterMarkers(t *testing.T) {
+	page, err := ioutil.ReadFile(filepath.Join("testdata", "watch.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Chapter{
+		{"Intro", 0},
+		{"Second; {track}", 125500 * time.Millisecond},
+		{"Outro", 8 * time.Minute},
+	}
+	if c := ytChapterMarkers(page); !reflect.DeepEqual(c, exp) {
+		t.Errorf("%v, expected %v", c, exp)
+	}
+
+	if c := ytChapterMarkers([]byte("<html></html>")); c != nil {
+		t.Errorf("expected no chapters, got %v", c)
+	}
+}
+
+func TestChaptersContext(t *testing.T) {
+	page, err := ioutil.ReadFile(filepath.Join("testdata", "watch.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests, limited int32 = 0, 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.LoadInt32(&limited) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("<html></html>"))
+			return
+		}
+		w.Write(page)
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/watch?v=video")
+	info := &YoutubeInfo{url: u}
+	if c := info.Chapters(); c != nil {
+		t.Errorf("Chapters fetched %v", c)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("Chapters made %d requests", n)
+	}
+
+	if _, err := FetchChapters(context.Background(), info); err == nil {
+		t.Error("expected an error for a rate limited request")
+	}
+
+	atomic.StoreInt32(&limited, 0)
+	for i := 0; i < 2; i++ {
+		c, err := FetchChapters(context.Background(), info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(c) != 3 {
+			t.Errorf("%d chapters, expected the 3 markers", len(c))
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("%d requests, expected the failed one and a single successful one", n)
+	}
+
+	info = &YoutubeInfo{url: u, chapters: []Chapter{{"Intro", 0}}}
+	if c, err := FetchChapters(context.Background(), info); err != nil || len(c) != 1 {
+		t.Errorf("expected the chapters of the description, got %v %v", c, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("fetched markers for an item with chapters in the description")
+	}
+}
+
+func TestShiftChapters(t *testing.T) {
+	c := []Chapter{{"a", 0}, {"b", time.Second}, {"c", time.Minute}}
+	exp := []Chapter{{"a", 0}, {"b", 0}, {"c", time.Minute - 2*time.Second}}
+	if s := ShiftChapters(c, 2*time.Second); !reflect.DeepEqual(s, exp) {
+		t.Errorf("%v, expected %v", s, exp)
+	}
+	if c[1].Start != time.Second {
+		t.Error("ShiftChapters modified its argument")
+	}
+}
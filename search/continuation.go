@@ -0,0 +1,284 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ytAPIKey        = regexp.MustCompile(`"INNERTUBE_API_KEY"\s*:\s*"([^"]+)"`)
+	ytClientVersion = regexp.MustCompile(`"INNERTUBE_(?:CONTEXT_)?CLIENT_VERSION"\s*:\s*"([^"]+)"`)
+)
+
+// ytBrowseURL is where continuations are fetched, relative to the
+// scheme and host of the first page, %s is the api key.
+var ytBrowseURL = "/youtubei/v1/browse?key=%s"
+
+// ytContinuation fetches the next page of a playlist or channel.
+type ytContinuation struct {
+	base    string
+	token   string
+	key     string
+	version string
+}
+
+type ytBrowseRequest struct {
+	Context struct {
+		Client struct {
+			ClientName    string `json:"clientName"`
+			ClientVersion string `json:"clientVersion"`
+		} `json:"client"`
+	} `json:"context"`
+	Continuation string `json:"continuation"`
+}
+
+// ytPager pages through a playlist or channel, it is safe for
+// concurrent use.
+type ytPager struct {
+	u       *url.URL
+	re      *regexp.Regexp
+	timeout time.Duration
+
+	sem sync.Mutex
+	// conts[i] fetches page i+1, nil if page i was the last one.
+	conts []*ytContinuation
+}
+
+func newYTPager(u *url.URL, re *regexp.Regexp, timeout time.Duration) *ytPager {
+	return &ytPager{u: u, re: re, timeout: timeout}
+}
+
+// page returns the results of page n, no results and no error
+// if there are no more pages.
+func (p *ytPager) page(n int) ([]Result, error) {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	return p.fetch(n)
+}
+
+func (p *ytPager) fetch(n int) ([]Result, error) {
+	if n == 0 {
+		results, c, err := fetchPage(p.u, p.re, p.timeout)
+		if err != nil {
+			return nil, err
+		}
+		p.conts = []*ytContinuation{c}
+		return results, nil
+	}
+
+	for len(p.conts) < n {
+		if l := len(p.conts); l != 0 && p.conts[l-1] == nil {
+			return nil, nil
+		}
+		if _, err := p.fetch(len(p.conts)); err != nil {
+			return nil, err
+		}
+	}
+
+	c := p.conts[n-1]
+	if c == nil {
+		return nil, nil
+	}
+
+	results, next, err := c.next(p.re, p.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.conts) == n {
+		p.conts = append(p.conts, next)
+	}
+
+	return results, nil
+}
+
+// all returns up to max results of all pages, all of them if max <= 0.
+func (p *ytPager) all(max int) ([]Result, error) {
+	var results []Result
+	for page := 0; max <= 0 || len(results) < max; page++ {
+		r, err := p.page(page)
+		if err != nil {
+			return results, err
+		}
+		if len(r) == 0 {
+			break
+		}
+		results = append(results, r...)
+	}
+
+	if max > 0 && len(results) > max {
+		results = results[:max]
+	}
+
+	return results, nil
+}
+
+func fetchPage(u *url.URL, re *regexp.Regexp, to time.Duration) ([]Result, *ytContinuation, error) {
+	res, err := (&http.Client{Timeout: to}).Get(u.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matches := re.FindSubmatch(body)
+	if len(matches) != 2 {
+		return nil, nil, errors.New("regex doesnt match")
+	}
+	var yt interface{}
+	if err := json.Unmarshal(matches[1], &yt); err != nil {
+		return nil, nil, err
+	}
+
+	results, token, err := extract(yt, re)
+	if err != nil || token == "" {
+		return results, nil, err
+	}
+
+	c := &ytContinuation{base: u.Scheme + "://" + u.Host, token: token}
+	if m := ytAPIKey.FindSubmatch(body); m != nil {
+		c.key = string(m[1])
+	}
+	if m := ytClientVersion.FindSubmatch(body); m != nil {
+		c.version = string(m[1])
+	}
+	if c.key == "" || c.version == "" {
+		return results, nil, nil
+	}
+
+	return results, c, nil
+}
+
+func (c *ytContinuation) next(re *regexp.Regexp, to time.Duration) ([]Result, *ytContinuation, error) {
+	req := &ytBrowseRequest{Continuation: c.token}
+	req.Context.Client.ClientName = "WEB"
+	req.Context.Client.ClientVersion = c.version
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := (&http.Client{Timeout: to}).Post(
+		c.base+fmt.Sprintf(ytBrowseURL, url.QueryEscape(c.key)),
+		"application/json",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("Continuation failed: %s", res.Status)
+	}
+
+	var yt interface{}
+	if err := json.NewDecoder(res.Body).Decode(&yt); err != nil {
+		return nil, nil, err
+	}
+
+	results, token, err := extract(yt, re)
+	if err != nil || token == "" {
+		return results, nil, err
+	}
+
+	return results, &ytContinuation{c.base, token, c.key, c.version}, nil
+}
+
+// extract returns the videos in yt in order of appearance and the
+// first continuation token.
+func extract(yt interface{}, re *regexp.Regexp) ([]Result, string, error) {
+	var results []Result
+	var token string
+	seen := make(map[string]struct{})
+
+	var s func(key string, i interface{}) error
+	s = func(key string, i interface{}) error {
+		switch v := i.(type) {
+		case map[string]interface{}:
+			if strings.HasSuffix(key, "ideoRenderer") {
+				id, _ := v["videoId"].(string)
+				title := ytText(v["title"])
+				if id == "" || title == "" {
+					return nil
+				}
+				if _, ok := seen[id]; ok {
+					return nil
+				}
+				seen[id] = struct{}{}
+
+				u, err := url.Parse(fmt.Sprintf("https://youtube.com/watch?v=%s", id))
+				if err != nil {
+					return err
+				}
+				results = append(results, &YoutubeResult{id, re, u, title, nil})
+				return nil
+			}
+
+			if token == "" {
+				switch key {
+				case "continuationCommand":
+					token, _ = v["token"].(string)
+				case "nextContinuationData":
+					token, _ = v["continuation"].(string)
+				}
+			}
+
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if err := s(k, v[k]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, it := range v {
+				if err := s(key, it); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return results, token, s("", yt)
+}
+
+// ytText returns the text of either a runs or simpleText object.
+func ytText(i interface{}) string {
+	m, ok := i.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	if t, ok := m["simpleText"].(string); ok {
+		return t
+	}
+
+	runs, _ := m["runs"].([]interface{})
+	var text string
+	for _, r := range runs {
+		if r, ok := r.(map[string]interface{}); ok {
+			t, _ := r["text"].(string)
+			text += t
+		}
+	}
+
+	return text
+}
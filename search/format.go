@@ -0,0 +1,125 @@
+package search
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// FormatPolicy decides which stream formats are acceptable and in which
+// order they should be tried.
+type FormatPolicy struct {
+	// AudioOnly prefers formats without a video stream.
+	AudioOnly bool
+	// Codecs lists the preferred audio codecs, most preferred first.
+	Codecs []string
+	// Containers lists the preferred containers, most preferred first.
+	Containers []string
+	// MaxBitrate in kbps, 0 means unlimited.
+	MaxBitrate int
+}
+
+// DefaultFormatPolicy is used where no policy is given, it prefers
+// audio only formats.
+func DefaultFormatPolicy() *FormatPolicy {
+	return &FormatPolicy{AudioOnly: true}
+}
+
+// Accept reports whether f is usable at all.
+func (p *FormatPolicy) Accept(f *Format) bool {
+	if f.AudioEncoding == "" || f.AudioBitrate <= 0 {
+		return false
+	}
+
+	return p.MaxBitrate <= 0 || f.AudioBitrate <= p.MaxBitrate
+}
+
+// Less reports whether a is preferred over b.
+func (p *FormatPolicy) Less(a, b *Format) bool {
+	if p.AudioOnly && a.AudioOnly() != b.AudioOnly() {
+		return a.AudioOnly()
+	}
+
+	if ra, rb := rank(p.Codecs, a.AudioEncoding), rank(p.Codecs, b.AudioEncoding); ra != rb {
+		return ra < rb
+	}
+
+	if ra, rb := rank(p.Containers, a.Extension), rank(p.Containers, b.Extension); ra != rb {
+		return ra < rb
+	}
+
+	return a.AudioBitrate > b.AudioBitrate
+}
+
+// Apply returns the indexes of the acceptable formats, preferred first.
+func (p *FormatPolicy) Apply(formats []*Format) []int {
+	ix := make([]int, 0, len(formats))
+	for i, f := range formats {
+		if p.Accept(f) {
+			ix = append(ix, i)
+		}
+	}
+
+	sort.SliceStable(ix, func(i, j int) bool {
+		return p.Less(formats[ix[i]], formats[ix[j]])
+	})
+
+	return ix
+}
+
+// YoutubeDL returns the equivalent youtube-dl format selector.
+func (p *FormatPolicy) YoutubeDL() string {
+	abr := ""
+	if p.MaxBitrate > 0 {
+		abr = fmt.Sprintf("[abr<=%d]", p.MaxBitrate)
+	}
+
+	base := []string{"best" + abr}
+	if p.AudioOnly {
+		base = []string{"bestaudio" + abr, "best" + abr}
+	}
+
+	sel := make([]string, 0, len(p.Codecs)+len(p.Containers)+len(base))
+	for _, c := range p.Codecs {
+		if strings.EqualFold(c, "aac") {
+			// youtube-dl reports the aac codec by its rfc 6381 name.
+			c = "mp4a"
+		}
+		sel = append(sel, fmt.Sprintf("%s[acodec^=%s]", base[0], c))
+	}
+	for _, c := range p.Containers {
+		sel = append(sel, fmt.Sprintf("%s[ext=%s]", base[0], c))
+	}
+	sel = append(sel, base...)
+
+	return strings.Join(sel, "/")
+}
+
+func rank(list []string, v string) int {
+	for i := range list {
+		if strings.EqualFold(list[i], v) {
+			return i
+		}
+	}
+
+	return len(list)
+}
+
+// Ext guesses the file extension of a stream url, e.g.: for caching.
+func Ext(u *url.URL) string {
+	m := u.Query().Get("mime")
+	if m == "" {
+		return "mp4"
+	}
+
+	switch m, _, _ = mime.ParseMediaType(m); m {
+	case "audio/webm", "video/webm":
+		return "webm"
+	case "audio/mp4":
+		return "m4a"
+	}
+
+	return "mp4"
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+var testFormats = []*Format{
+	{Extension: "mp4", Resolution: "720p", VideoEncoding: "H.264", AudioEncoding: "aac", AudioBitrate: 192},
+	{Extension: "webm", AudioEncoding: "opus", AudioBitrate: 160},
+	{Extension: "m4a", AudioEncoding: "aac", AudioBitrate: 128},
+	{Extension: "webm", AudioEncoding: "opus", AudioBitrate: 64},
+	{Extension: "mp4", Resolution: "1080p", VideoEncoding: "H.264"},
+	{Extension: "webm", AudioEncoding: "vorbis", AudioBitrate: 0},
+}
+
+func TestFormatPolicyAccept(t *testing.T) {
+	tests := []struct {
+		max    int
+		accept []bool
+	}{
+		{0, []bool{true, true, true, true, false, false}},
+		{128, []bool{false, false, true, true, false, false}},
+	}
+
+	for _, test := range tests {
+		p := &FormatPolicy{MaxBitrate: test.max}
+		for i, f := range testFormats {
+			if a := p.Accept(f); a != test.accept[i] {
+				t.Errorf("max %d: format %d accepted: %t", test.max, i, a)
+			}
+		}
+	}
+}
+
+func TestFormatPolicyApply(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *FormatPolicy
+		ix   []int
+	}{
+		{"bitrate", &FormatPolicy{}, []int{0, 1, 2, 3}},
+		{"audio only", DefaultFormatPolicy(), []int{1, 2, 3, 0}},
+		{"codec", &FormatPolicy{AudioOnly: true, Codecs: []string{"AAC"}}, []int{2, 1, 3, 0}},
+		{"container", &FormatPolicy{Containers: []string{"webm", "m4a"}}, []int{1, 3, 2, 0}},
+		{"codec over container", &FormatPolicy{Codecs: []string{"opus"}, Containers: []string{"m4a"}}, []int{1, 3, 2, 0}},
+		{"max bitrate", &FormatPolicy{AudioOnly: true, MaxBitrate: 100}, []int{3}},
+	}
+
+	for _, test := range tests {
+		if ix := test.p.Apply(testFormats); !reflect.DeepEqual(ix, test.ix) {
+			t.Errorf("%s: %v, expected %v", test.name, ix, test.ix)
+		}
+	}
+}
+
+func TestFormatPolicyLess(t *testing.T) {
+	p := &FormatPolicy{AudioOnly: true, Codecs: []string{"opus"}}
+	if !p.Less(testFormats[1], testFormats[3]) || p.Less(testFormats[3], testFormats[1]) {
+		t.Error("expected the higher bitrate first")
+	}
+	if !p.Less(testFormats[2], testFormats[0]) {
+		t.Error("expected audio only first")
+	}
+	if p.Less(testFormats[1], testFormats[1]) {
+		t.Error("a format is not preferred over itself")
+	}
+}
+
+func TestFormatPolicyYoutubeDL(t *testing.T) {
+	tests := []struct {
+		p   *FormatPolicy
+		sel string
+	}{
+		{&FormatPolicy{}, "best"},
+		{DefaultFormatPolicy(), "bestaudio/best"},
+		{
+			&FormatPolicy{AudioOnly: true, Codecs: []string{"opus", "aac"}, MaxBitrate: 160},
+			"bestaudio[abr<=160][acodec^=opus]/bestaudio[abr<=160][acodec^=mp4a]/bestaudio[abr<=160]/best[abr<=160]",
+		},
+		{
+			&FormatPolicy{Containers: []string{"m4a"}},
+			"best[ext=m4a]/best",
+		},
+	}
+
+	for _, test := range tests {
+		if sel := test.p.YoutubeDL(); sel != test.sel {
+			t.Errorf("%+v: %s, expected %s", test.p, sel, test.sel)
+		}
+	}
+}
+
+func TestExt(t *testing.T) {
+	tests := map[string]string{
+		"https://host/videoplayback":                   "mp4",
+		"https://host/videoplayback?mime=audio%2Fwebm": "webm",
+		"https://host/videoplayback?mime=video%2Fwebm": "webm",
+		"https://host/videoplayback?mime=audio%2Fmp4":  "m4a",
+		"https://host/videoplayback?mime=video%2Fmp4":  "mp4",
+	}
+
+	for raw, ext := range tests {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e := Ext(u); e != ext {
+			t.Errorf("%s: %s, expected %s", raw, e, ext)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"time"
+
+	"github.com/frizinak/ym/metrics"
+)
+
+// EngineMetrics records the latency and errors of engines.
+type EngineMetrics struct {
+	duration *metrics.Metric
+	errors   *metrics.Metric
+}
+
+func NewEngineMetrics(r *metrics.Registry) *EngineMetrics {
+	return &EngineMetrics{
+		duration: r.Summary(
+			"ym_search_duration_seconds",
+			"Latency of search engine requests.",
+			"engine", "method",
+		),
+		errors: r.Counter(
+			"ym_search_errors_total",
+			"Failed search engine requests.",
+			"engine", "method",
+		),
+	}
+}
+
+// Measure returns e with its requests recorded as engine name.
+func (m *EngineMetrics) Measure(name string, e Engine) Engine {
+	return &measured{e, name, m}
+}
+
+type measured struct {
+	e    Engine
+	name string
+	m    *EngineMetrics
+}
+
+func (m *measured) record(method string, start time.Time, err error) {
+	m.m.duration.Observe(time.Since(start).Seconds(), m.name, method)
+	if err != nil {
+		m.m.errors.Inc(m.name, method)
+	}
+}
+
+func (m *measured) Search(q string, page int) ([]Result, error) {
+	start := time.Now()
+	r, err := m.e.Search(q, page)
+	m.record("search", start, err)
+	return r, err
+}
+
+func (m *measured) Page(url string, page int) ([]Result, error) {
+	start := time.Now()
+	r, err := m.e.Page(url, page)
+	m.record("page", start, err)
+	return r, err
+}
+
+func (m *measured) Playlist(id string, max int) ([]Result, error) {
+	start := time.Now()
+	r, err := m.e.Playlist(id, max)
+	m.record("playlist", start, err)
+	return r, err
+}
@@ -0,0 +1,169 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Preflight configures how URLs.FindContext checks candidate urls.
+type Preflight struct {
+	// Max is the maximum amount of urls to try.
+	Max int
+	// Concurrency is the maximum amount of simultaneous requests.
+	Concurrency int
+	// Timeout is the deadline of a single request.
+	Timeout time.Duration
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func NewPreflight(max, concurrency int, timeout time.Duration) *Preflight {
+	return &Preflight{Max: max, Concurrency: concurrency, Timeout: timeout}
+}
+
+// Rejection describes why a candidate url was not used.
+type Rejection struct {
+	URL    *url.URL
+	Reason error
+}
+
+// FindError is returned by URLs.FindContext if no url was acceptable.
+type FindError struct {
+	Rejections []Rejection
+}
+
+func (f *FindError) Error() string {
+	if len(f.Rejections) == 0 {
+		return "No suitable url found"
+	}
+
+	reasons := make([]string, len(f.Rejections))
+	for i, r := range f.Rejections {
+		reasons[i] = fmt.Sprintf("#%d: %s", i+1, r.Reason)
+	}
+
+	return "No suitable url found (" + strings.Join(reasons, ", ") + ")"
+}
+
+type preflightResult struct {
+	i   int
+	err error
+}
+
+// FindContext checks up to p.Max urls concurrently and returns the first
+// acceptable one in the order of urls, i.e.: by format preference.
+// A url is acceptable if a HEAD request, or a ranged GET request for servers
+// that don't allow HEAD, responds with a 2xx status.
+func (urls URLs) FindContext(ctx context.Context, p *Preflight) (*url.URL, error) {
+	if p == nil {
+		p = &Preflight{}
+	}
+	if p.Max > 0 && len(urls) > p.Max {
+		urls = urls[:p.Max]
+	}
+	if len(urls) == 0 {
+		return nil, &FindError{}
+	}
+
+	concurrency := p.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan preflightResult, len(urls))
+	sem := make(chan struct{}, concurrency)
+	go func() {
+		for i := range urls {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(i int) {
+				results <- preflightResult{i, p.check(ctx, urls[i])}
+				<-sem
+			}(i)
+		}
+	}()
+
+	done := make([]bool, len(urls))
+	errs := make([]error, len(urls))
+	next := 0
+	for next < len(urls) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case r := <-results:
+			done[r.i], errs[r.i] = true, r.err
+		}
+
+		for next < len(urls) && done[next] {
+			if errs[next] == nil {
+				return urls[next], nil
+			}
+			next++
+		}
+	}
+
+	err := &FindError{Rejections: make([]Rejection, len(urls))}
+	for i := range urls {
+		err.Rejections[i] = Rejection{urls[i], errs[i]}
+	}
+
+	return nil, err
+}
+
+func (p *Preflight) check(ctx context.Context, u *url.URL) error {
+	status, err := p.request(ctx, http.MethodHead, u)
+	if err == nil && (status == http.StatusMethodNotAllowed ||
+		status == http.StatusNotImplemented) {
+		status, err = p.request(ctx, http.MethodGet, u)
+	}
+
+	if err != nil {
+		return err
+	}
+	if status < 200 || status >= 300 {
+		return fmt.Errorf("status %d", status)
+	}
+
+	return nil
+}
+
+func (p *Preflight) request(ctx context.Context, method string, u *url.URL) (int, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	res.Body.Close()
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type preflightServer struct {
+	sem      sync.Mutex
+	requests int
+	inflight int
+	max      int
+}
+
+func newPreflightServer(t *testing.T) (*preflightServer, *httptest.Server) {
+	p := &preflightServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.sem.Lock()
+		p.requests++
+		p.inflight++
+		if p.inflight > p.max {
+			p.max = p.inflight
+		}
+		p.sem.Unlock()
+		defer func() {
+			p.sem.Lock()
+			p.inflight--
+			p.sem.Unlock()
+		}()
+
+		ranged := r.Method == http.MethodGet && r.Header.Get("Range") == "bytes=0-0"
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+		case "/nohead", "/notimplemented":
+			if r.Method == http.MethodHead {
+				status := http.StatusMethodNotAllowed
+				if r.URL.Path == "/notimplemented" {
+					status = http.StatusNotImplemented
+				}
+				w.WriteHeader(status)
+				return
+			}
+			if !ranged {
+				t.Errorf("unexpected %s without range", r.Method)
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second * 5):
+			}
+			w.WriteHeader(http.StatusOK)
+		case "/busy":
+			time.Sleep(time.Millisecond * 50)
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return p, srv
+}
+
+func (p *preflightServer) stats() (requests, max int) {
+	p.sem.Lock()
+	defer p.sem.Unlock()
+	return p.requests, p.max
+}
+
+func preflightURLs(t *testing.T, base string, paths ...string) URLs {
+	urls := make(URLs, len(paths))
+	for i, p := range paths {
+		u, err := url.Parse(base + p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls[i] = u
+	}
+	return urls
+}
+
+func TestFindOrder(t *testing.T) {
+	_, srv := newPreflightServer(t)
+	defer srv.Close()
+
+	tests := []struct {
+		paths []string
+		found string
+	}{
+		{[]string{"/ok"}, "/ok"},
+		{[]string{"/forbidden", "/nohead", "/ok"}, "/nohead"},
+		{[]string{"/missing", "/notimplemented"}, "/notimplemented"},
+		{[]string{"/slow", "/ok"}, "/ok"},
+	}
+
+	p := NewPreflight(0, 4, time.Millisecond*100)
+	for _, test := range tests {
+		u, err := preflightURLs(t, srv.URL, test.paths...).FindContext(context.Background(), p)
+		if err != nil {
+			t.Errorf("%v: %s", test.paths, err)
+			continue
+		}
+		if u.Path != test.found {
+			t.Errorf("%v: found %s, expected %s", test.paths, u.Path, test.found)
+		}
+	}
+}
+
+func TestFindRejections(t *testing.T) {
+	_, srv := newPreflightServer(t)
+	defer srv.Close()
+
+	urls := preflightURLs(t, srv.URL, "/forbidden", "/slow", "/missing")
+	_, err := urls.FindContext(context.Background(), NewPreflight(0, 3, time.Millisecond*100))
+	ferr, ok := err.(*FindError)
+	if !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(ferr.Rejections) != 3 {
+		t.Fatalf("%d rejections, expected 3", len(ferr.Rejections))
+	}
+
+	for i, r := range ferr.Rejections {
+		if r.URL != urls[i] {
+			t.Errorf("rejection %d is %s, expected %s", i, r.URL, urls[i])
+		}
+	}
+	if r := ferr.Rejections[0].Reason; r == nil || r.Error() != "status 403" {
+		t.Errorf("unexpected reason %v", r)
+	}
+	if r, ok := ferr.Rejections[1].Reason.(net.Error); !ok || !r.Timeout() {
+		t.Errorf("expected a timeout, got %v", ferr.Rejections[1].Reason)
+	}
+	if r := ferr.Rejections[2].Reason; r == nil || r.Error() != "status 404" {
+		t.Errorf("unexpected reason %v", r)
+	}
+
+	if _, err := (URLs{}).FindContext(context.Background(), nil); err == nil {
+		t.Error("expected an error without urls")
+	}
+}
+
+func TestFindConcurrency(t *testing.T) {
+	s, srv := newPreflightServer(t)
+	defer srv.Close()
+
+	paths := make([]string, 8)
+	for i := range paths {
+		paths[i] = "/busy"
+	}
+
+	_, err := preflightURLs(t, srv.URL, paths...).FindContext(
+		context.Background(),
+		NewPreflight(6, 2, time.Second),
+	)
+	if _, ok := err.(*FindError); !ok {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	requests, max := s.stats()
+	if requests != 6 {
+		t.Errorf("%d requests, expected Max (6)", requests)
+	}
+	if max != 2 {
+		t.Errorf("%d simultaneous requests, expected 2", max)
+	}
+}
+
+func TestFindCancel(t *testing.T) {
+	_, srv := newPreflightServer(t)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := preflightURLs(t, srv.URL, "/slow").FindContext(ctx, NewPreflight(0, 1, 0))
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+}
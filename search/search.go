@@ -1,8 +1,7 @@
 package search
 
 import (
-	"errors"
-	"net/http"
+	"context"
 	"net/url"
 	"reflect"
 	"time"
@@ -28,12 +27,17 @@ func ResultTypeName(r Result) string {
 }
 
 type Format struct {
+	Extension     string
 	Resolution    string
 	VideoEncoding string
 	AudioEncoding string
 	AudioBitrate  int
 }
 
+func (f *Format) AudioOnly() bool {
+	return f.VideoEncoding == ""
+}
+
 type Info interface {
 	ID() string
 	PageURL() *url.URL
@@ -42,20 +46,46 @@ type Info interface {
 	Formats() []*Format
 	Author() string
 	Duration() time.Duration
+	Thumbnail() *url.URL
+	Chapters() []Chapter
 }
 
 type Engine interface {
 	Search(q string, page int) ([]Result, error)
-	Page(url string) ([]Result, error)
+	Page(url string, page int) ([]Result, error)
+	Playlist(id string, max int) ([]Result, error)
+}
+
+// Collect searches page by page until it has at least amount results
+// or there are no more.
+func Collect(e Engine, q string, amount int) ([]Result, error) {
+	results := make([]Result, 0, amount)
+	page := 0
+	for {
+		_results, err := e.Search(q, page)
+		if err != nil {
+			return nil, err
+		}
+
+		page++
+		results = append(results, _results...)
+		if len(_results) == 0 || len(results) >= amount {
+			break
+		}
+	}
+
+	return results, nil
 }
 
 type Result interface {
 	ID() string
 
 	IsPlayList() bool
-	PlaylistResults(timeout time.Duration) ([]Result, error)
+	PlaylistResults(timeout time.Duration, max int) ([]Result, error)
 
-	DownloadURLs() (URLs, error)
+	// DownloadURLs returns the urls of the formats p accepts, preferred
+	// first, a nil p is the DefaultFormatPolicy.
+	DownloadURLs(p *FormatPolicy) (URLs, error)
 	PageURL() *url.URL
 
 	Title() string
@@ -66,21 +96,10 @@ type Result interface {
 
 type URLs []*url.URL
 
+// Find checks up to maxURLsToTry urls one by one, see FindContext.
 func (urls URLs) Find(maxURLsToTry int) (*url.URL, error) {
-	for i, u := range urls {
-		if i == maxURLsToTry {
-			break
-		}
-
-		res, err := http.Head(u.String())
-		if res != nil && res.Body != nil {
-			res.Body.Close()
-		}
-
-		if err == nil && res.StatusCode >= 200 && res.StatusCode < 300 {
-			return u, nil
-		}
-	}
-
-	return nil, errors.New("No suitable url found")
+	return urls.FindContext(
+		context.Background(),
+		&Preflight{Max: maxURLsToTry, Concurrency: 1},
+	)
 }
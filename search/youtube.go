@@ -6,23 +6,29 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os/exec"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rylio/ytdl"
 )
 
 type YoutubeInfo struct {
-	i       *ytdl.VideoInfo
-	formats []*Format
-	url     *url.URL
+	i        *ytdl.VideoInfo
+	chapters []Chapter
+	formats  []*Format
+	// yt holds the ytdl formats in the same order as formats.
+	yt  ytdl.FormatList
+	url *url.URL
+
+	sem     sync.Mutex
+	markers []Chapter
+	fetched bool
 }
 
 func (y *YoutubeInfo) ID() string              { return y.i.ID }
@@ -33,6 +39,39 @@ func (y *YoutubeInfo) Formats() []*Format      { return y.formats }
 func (y *YoutubeInfo) Author() string          { return y.i.Uploader }
 func (y *YoutubeInfo) Duration() time.Duration { return y.i.Duration }
 
+// Chapters returns the chapters in the description.
+func (y *YoutubeInfo) Chapters() []Chapter { return y.chapters }
+
+// ChaptersContext returns the chapters in the description, or fetches
+// the chapter markers of the player if there are none.
+func (y *YoutubeInfo) ChaptersContext(ctx context.Context) ([]Chapter, error) {
+	if len(y.chapters) != 0 {
+		return y.chapters, nil
+	}
+
+	y.sem.Lock()
+	defer y.sem.Unlock()
+	if y.fetched {
+		return y.markers, nil
+	}
+
+	markers, err := fetchChapterMarkers(ctx, y.url)
+	if err != nil {
+		return nil, err
+	}
+	y.markers, y.fetched = markers, true
+
+	return markers, nil
+}
+
+func (y *YoutubeInfo) Thumbnail() *url.URL {
+	return &url.URL{
+		Scheme: "https",
+		Host:   "i.ytimg.com",
+		Path:   "/vi/" + y.i.ID + "/hqdefault.jpg",
+	}
+}
+
 type YoutubeResult struct {
 	id    string
 	re    *regexp.Regexp
@@ -41,21 +80,37 @@ type YoutubeResult struct {
 	info  *YoutubeInfo
 }
 
+// NewYoutubeResult returns the result of the video with the given id.
+func NewYoutubeResult(id, title string) *YoutubeResult {
+	u, _ := url.Parse("https://youtube.com/watch?v=" + id)
+	return &YoutubeResult{id: id, url: u, title: title}
+}
+
 func (y *YoutubeResult) ID() string        { return y.id }
 func (y *YoutubeResult) Title() string     { return y.title }
 func (y *YoutubeResult) PageURL() *url.URL { return y.url }
 func (y *YoutubeResult) IsPlayList() bool  { return y.url.Query().Get("list") != "" }
 
-func (y *YoutubeResult) DownloadURLs() (URLs, error) {
-	u, err := y.libDownloadURLs()
+func (y *YoutubeResult) DownloadURLs(p *FormatPolicy) (URLs, error) {
+	if p == nil {
+		p = DefaultFormatPolicy()
+	}
+
+	u, err := y.libDownloadURLs(p)
 	if len(u) == 0 {
-		return y.cliDownloadURLs()
+		return y.cliDownloadURLs(p)
 	}
 
 	return u, err
 }
-func (y *YoutubeResult) cliDownloadURLs() (URLs, error) {
-	cmd := exec.Command("youtube-dl", "-g", "-f", "bestaudio", y.PageURL().String())
+
+func (y *YoutubeResult) cliDownloadURLs(p *FormatPolicy) (URLs, error) {
+	cmd := exec.Command(
+		"youtube-dl",
+		"-g",
+		"-f", p.YoutubeDL(),
+		y.PageURL().String(),
+	)
 	buf := bytes.NewBuffer(nil)
 	cmd.Stdout = buf
 	if err := cmd.Run(); err != nil {
@@ -66,21 +121,20 @@ func (y *YoutubeResult) cliDownloadURLs() (URLs, error) {
 	return URLs{u}, err
 }
 
-func (y *YoutubeResult) libDownloadURLs() (URLs, error) {
+func (y *YoutubeResult) libDownloadURLs(p *FormatPolicy) (URLs, error) {
 	if err := y.getInfo(); err != nil {
 		return nil, err
 	}
 
-	formats := y.info.i.Formats
-	if len(formats) == 0 {
+	ix := p.Apply(y.info.formats)
+	if len(ix) == 0 {
 		return nil, fmt.Errorf("No downloadable formats available")
 	}
 
 	c := ytdl.Client{HTTPClient: http.DefaultClient}
-	formats.Sort(ytdl.FormatAudioBitrateKey, true)
-	s := make(URLs, 0, len(formats))
-	for i := range formats {
-		u, err := c.GetDownloadURL(context.Background(), y.info.i, formats[i])
+	s := make(URLs, 0, len(ix))
+	for _, i := range ix {
+		u, err := c.GetDownloadURL(context.Background(), y.info.i, y.info.yt[i])
 		if err != nil {
 			continue
 		}
@@ -90,8 +144,10 @@ func (y *YoutubeResult) libDownloadURLs() (URLs, error) {
 	return s, nil
 }
 
-func (y *YoutubeResult) PlaylistResults(timeout time.Duration) ([]Result, error) {
-	results, err := match(
+// PlaylistResults returns up to max items of the playlist, all of them
+// if max <= 0.
+func (y *YoutubeResult) PlaylistResults(timeout time.Duration, max int) ([]Result, error) {
+	p := newYTPager(
 		&url.URL{
 			Scheme:   y.url.Scheme,
 			Host:     y.url.Host,
@@ -99,16 +155,10 @@ func (y *YoutubeResult) PlaylistResults(timeout time.Duration) ([]Result, error)
 			RawQuery: "list=" + y.url.Query().Get("list"),
 		},
 		y.re,
-		true,
 		timeout,
 	)
 
-	// Remove 'Play all' button
-	if len(results) != 0 {
-		results = results[1:]
-	}
-
-	return results, err
+	return p.all(max)
 }
 
 func (y *YoutubeResult) Info() (Info, error) {
@@ -129,26 +179,24 @@ func (y *YoutubeResult) getInfo() error {
 		return err
 	}
 
-	filtered := vid.Formats.Filter(
-		ytdl.FormatAudioBitrateKey,
-		[]interface{}{128, 192, 256, 400},
-	)
-
-	filtered.Sort(ytdl.FormatAudioBitrateKey, false)
-	formats := make([]*Format, 0, len(filtered))
-	for _, f := range filtered {
-		formats = append(
-			formats,
-			&Format{
-				f.Resolution,
-				f.VideoEncoding,
-				f.AudioEncoding,
-				f.AudioBitrate,
-			},
-		)
+	formats := make([]*Format, len(vid.Formats))
+	for i, f := range vid.Formats {
+		formats[i] = &Format{
+			f.Extension,
+			f.Resolution,
+			f.VideoEncoding,
+			f.AudioEncoding,
+			f.AudioBitrate,
+		}
 	}
 
-	y.info = &YoutubeInfo{vid, formats, y.url}
+	y.info = &YoutubeInfo{
+		i:        vid,
+		chapters: ParseChapters(vid.Description, vid.Duration),
+		formats:  formats,
+		yt:       vid.Formats,
+		url:      y.url,
+	}
 
 	return nil
 }
@@ -178,9 +226,18 @@ func (y *YoutubeResult) Unmarshal(b string) error {
 	return nil
 }
 
+// ytPagers is the amount of channels whose continuation is remembered.
+const ytPagers = 16
+
 type Youtube struct {
+	base    string
 	re      *regexp.Regexp
 	timeout time.Duration
+
+	sem    sync.Mutex
+	pagers map[string]*ytPager
+	// recent lists the keys of pagers, least recently used first.
+	recent []string
 }
 
 func NewYoutube(timeout time.Duration) (*Youtube, error) {
@@ -189,7 +246,18 @@ func NewYoutube(timeout time.Duration) (*Youtube, error) {
 		return nil, err
 	}
 
-	return &Youtube{re: re, timeout: timeout}, nil
+	return &Youtube{
+		base:    "https://www.youtube.com",
+		re:      re,
+		timeout: timeout,
+		pagers:  make(map[string]*ytPager),
+	}, nil
+}
+
+// SetBaseURL changes the scheme and host pages are fetched from,
+// e.g.: http://127.0.0.1:8080
+func (y *Youtube) SetBaseURL(base string) {
+	y.base = strings.TrimRight(base, "/")
 }
 
 func (y *Youtube) Search(q string, page int) ([]Result, error) {
@@ -201,7 +269,8 @@ func (y *Youtube) Search(q string, page int) ([]Result, error) {
 
 	u, err := url.Parse(
 		fmt.Sprintf(
-			"https://www.youtube.com/results?search_query=%s&sp=%s",
+			"%s/results?search_query=%s&sp=%s",
+			y.base,
 			url.QueryEscape(q),
 			url.QueryEscape(sp),
 		),
@@ -210,13 +279,16 @@ func (y *Youtube) Search(q string, page int) ([]Result, error) {
 		return nil, err
 	}
 
-	return match(u, y.re, false, y.timeout)
+	return match(u, y.re, y.timeout)
 }
 
-func (y *Youtube) Page(channel string) ([]Result, error) {
+// Page returns the given page of uploads of a channel, starting at 0.
+// An empty slice is returned if there are no more pages.
+func (y *Youtube) Page(channel string, page int) ([]Result, error) {
 	u, err := url.Parse(
 		fmt.Sprintf(
-			"https://www.youtube.com/%s/videos",
+			"%s/%s/videos",
+			y.base,
 			channel,
 		),
 	)
@@ -224,78 +296,78 @@ func (y *Youtube) Page(channel string) ([]Result, error) {
 		return nil, err
 	}
 
-	return match(u, y.re, false, y.timeout)
+	return y.pager(u, page == 0).page(page)
 }
 
-type ytInitialData struct {
-	Contents *ytRenderer `json:"contents"`
-}
+// pager returns the pager of the channel at u, a new one if reset.
+func (y *Youtube) pager(u *url.URL, reset bool) *ytPager {
+	key := u.String()
+	y.sem.Lock()
+	defer y.sem.Unlock()
 
-type ytRenderer struct {
-}
+	for i := range y.recent {
+		if y.recent[i] == key {
+			y.recent = append(y.recent[:i], y.recent[i+1:]...)
+			break
+		}
+	}
+	y.recent = append(y.recent, key)
 
-func (yr *ytRenderer) UnmarshalJSON(d []byte) error {
-	return nil
-}
+	p, ok := y.pagers[key]
+	if !ok || reset {
+		p = newYTPager(u, y.re, y.timeout)
+		y.pagers[key] = p
+	}
 
-func match(u *url.URL, re *regexp.Regexp, trimPlaylist bool, to time.Duration) ([]Result, error) {
-	res, err := (&http.Client{Timeout: to}).Get(u.String())
-	if err != nil {
-		return nil, err
+	if len(y.recent) > ytPagers {
+		delete(y.pagers, y.recent[0])
+		y.recent = y.recent[1:]
 	}
-	defer res.Body.Close()
 
-	body, err := ioutil.ReadAll(res.Body)
+	return p
+}
+
+// Playlist returns up to max items of the playlist with the given id,
+// all of them if max <= 0.
+func (y *Youtube) Playlist(list string, max int) ([]Result, error) {
+	u, err := url.Parse(
+		fmt.Sprintf(
+			"%s/playlist?list=%s",
+			y.base,
+			url.QueryEscape(list),
+		),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	matches := re.FindSubmatch(body)
-	if len(matches) != 2 {
-		return nil, errors.New("regex doesnt match")
-	}
-	yt := make(map[string]interface{})
-	if err := json.Unmarshal(matches[1], &yt); err != nil {
-		return nil, err
-	}
-	results := make([]Result, 0, len(matches))
-
-	var s func(i interface{}) error
-	s = func(i interface{}) error {
-		switch v := i.(type) {
-		case map[string]interface{}:
-			vid, ok1 := v["videoId"]
-			title, ok2 := v["title"]
-			if ok1 && ok2 {
-				id := vid.(string)
-				runs := title.(map[string]interface{})["runs"].([]interface{})[0]
-				name := runs.(map[string]interface{})["text"].(string)
-				u, err := url.Parse(fmt.Sprintf("https://youtube.com/watch?v=%s", id))
-				if err != nil {
-					return err
-				}
-				results = append(
-					results,
-					&YoutubeResult{id, re, u, name, nil},
-				)
-				return nil
-			}
-
-			for i := range v {
-				if err := s(v[i]); err != nil {
-					return err
-				}
-			}
-		case []interface{}:
-			for _, it := range v {
-				if err := s(it); err != nil {
-					return err
-				}
-			}
-		}
+	return newYTPager(u, y.re, y.timeout).all(max)
+}
 
-		return nil
+// ParsePlaylistID returns the playlist id of a playlist url or s itself
+// if it is not a url.
+func ParsePlaylistID(s string) string {
+	s = strings.TrimSpace(s)
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
 	}
 
-	return results, s(yt)
+	return u.Query().Get("list")
+}
+
+type ytInitialData struct {
+	Contents *ytRenderer `json:"contents"`
+}
+
+type ytRenderer struct {
+}
+
+func (yr *ytRenderer) UnmarshalJSON(d []byte) error {
+	return nil
+}
+
+func match(u *url.URL, re *regexp.Regexp, to time.Duration) ([]Result, error) {
+	results, _, err := fetchPage(u, re, to)
+	return results, err
 }
@@ -0,0 +1,184 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fixtureServer serves the pages in testdata like youtube would,
+// continuations are served from browse-<token>.json.
+func fixtureServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/playlist", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list") != "PLtest" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "playlist.html"))
+	})
+	mux.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, filepath.Join("testdata", "results.html"))
+	})
+	mux.HandleFunc("/youtubei/v1/browse", func(w http.ResponseWriter, r *http.Request) {
+		req := &ytBrowseRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("key") != "key123" || req.Context.Client.ClientVersion != "2.20200101" {
+			http.Error(w, "invalid client", http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", "browse-"+req.Continuation+".json"))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func mustURL(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func ids(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID()
+	}
+
+	return ids
+}
+
+func TestPlaylistContinuation(t *testing.T) {
+	s := fixtureServer()
+	defer s.Close()
+
+	y, err := NewYoutube(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.SetBaseURL(s.URL + "/")
+
+	all := []string{
+		"video01", "video02", "video03",
+		"video04", "video05", "video06",
+		"video06", "video07", "video08",
+	}
+	tests := []struct {
+		max int
+		ids []string
+	}{
+		{0, all},
+		{2, all[:2]},
+		{4, all[:4]},
+		{100, all},
+	}
+
+	for _, test := range tests {
+		results, err := y.Playlist("PLtest", test.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(results); !reflect.DeepEqual(got, test.ids) {
+			t.Errorf("max %d: %v, expected %v", test.max, got, test.ids)
+		}
+	}
+
+	results, _ := y.Playlist("PLtest", 1)
+	if len(results) != 1 || results[0].Title() != "Video 1" {
+		t.Errorf("unexpected first result %v", results)
+	}
+
+	if _, err := y.Playlist("PLother", 0); err == nil {
+		t.Error("expected an error for a missing playlist")
+	}
+}
+
+func TestPagerPages(t *testing.T) {
+	s := fixtureServer()
+	defer s.Close()
+
+	y, err := NewYoutube(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.SetBaseURL(s.URL)
+
+	// pages are fetched out of order and more than once
+	exp := map[int][]string{
+		2: {"video06", "video07", "video08"},
+		0: {"video01", "video02", "video03"},
+		1: {"video04", "video05", "video06"},
+		3: nil,
+		4: nil,
+	}
+	p := newYTPager(mustURL(t, s.URL+"/playlist?list=PLtest"), y.re, time.Second*5)
+	for _, n := range []int{2, 0, 1, 3, 1, 4} {
+		results, err := p.page(n)
+		if err != nil {
+			t.Fatalf("page %d: %s", n, err)
+		}
+		if got := ids(results); len(got) != len(exp[n]) || (len(got) != 0 && !reflect.DeepEqual(got, exp[n])) {
+			t.Errorf("page %d: %v, expected %v", n, got, exp[n])
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := fixtureServer()
+	defer s.Close()
+
+	y, err := NewYoutube(time.Second * 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.SetBaseURL(s.URL)
+
+	results, err := y.Search("query", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"search01", "search02"}
+	if got := ids(results); !reflect.DeepEqual(got, exp) {
+		t.Errorf("%v, expected %v", got, exp)
+	}
+	if results[1].Title() != "Second result" {
+		t.Errorf("title %q", results[1].Title())
+	}
+}
+
+func TestPagersBounded(t *testing.T) {
+	y, err := NewYoutube(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := y.pager(mustURL(t, "https://www.youtube.com/channel0/videos"), false)
+	for i := 0; i < ytPagers*2; i++ {
+		y.pager(mustURL(t, fmt.Sprintf("https://www.youtube.com/channel%d/videos", i%(ytPagers+1))), false)
+		// channel0 is used regularly and kept
+		if p := y.pager(mustURL(t, "https://www.youtube.com/channel0/videos"), false); p != first {
+			t.Fatal("recently used pager was dropped")
+		}
+	}
+
+	if len(y.pagers) != ytPagers || len(y.recent) != ytPagers {
+		t.Errorf("%d pagers (%d recent), expected %d", len(y.pagers), len(y.recent), ytPagers)
+	}
+
+	if p := y.pager(mustURL(t, "https://www.youtube.com/channel0/videos"), true); p == first {
+		t.Error("expected a new pager for page 0")
+	}
+}
@@ -0,0 +1,605 @@
+package ym
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/search"
+)
+
+const (
+	// apiTick is how often the event stream checks for state changes
+	// that aren't published on the bus (e.g.: the sleep timer).
+	apiTick = time.Millisecond * 500
+	// apiQueueTimeout is how long a request waits for the queue to accept
+	// a command.
+	apiQueueTimeout = time.Second * 5
+	// apiVolumeMax limits the steps of a single volume request.
+	apiVolumeMax = 100
+)
+
+// Item is a queue item or search result as returned by the API,
+// Index starts at 1.
+type Item struct {
+	Index  int    `json:"index,omitempty"`
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Resume bool   `json:"resume,omitempty"`
+}
+
+// Result returns the search result of i.
+func (i *Item) Result() search.Result {
+	return search.NewYoutubeResult(i.ID, i.Title)
+}
+
+// SleepTimer is the state of the sleep timer, Remaining is in seconds
+// and negative if AtEnd is set and the duration of the item is unknown.
+type SleepTimer struct {
+	Remaining float64 `json:"remaining"`
+	AtEnd     bool    `json:"at_end"`
+}
+
+// ChapterInfo is the chapter that is playing, Index starts at 0.
+type ChapterInfo struct {
+	Title string `json:"title"`
+	Index int    `json:"index"`
+	Total int    `json:"total"`
+}
+
+// SyncResult is the result of a sync as returned by the API.
+type SyncResult struct {
+	Added   []*Item `json:"added"`
+	Removed []*Item `json:"removed"`
+}
+
+// NowPlaying is the player state as returned by the API,
+// Position and Duration are in seconds.
+type NowPlaying struct {
+	State    string       `json:"state"`
+	Current  *Item        `json:"current"`
+	Position float64      `json:"position"`
+	Duration float64      `json:"duration"`
+	Chapter  *ChapterInfo `json:"chapter,omitempty"`
+	Sleep    *SleepTimer  `json:"sleep,omitempty"`
+	Volume   int          `json:"volume"`
+	Length   int          `json:"length"`
+	Random   bool         `json:"random"`
+	Repeat   bool         `json:"repeat"`
+}
+
+// API is an http handler that exposes a JSON control api:
+//
+//	GET    /status             now playing
+//	GET    /events             server-sent events: status, position and playlist
+//	GET    /playlist           queue
+//	POST   /playlist           enqueue {"id": "", "title": ""}, title is optional
+//	DELETE /playlist?index=n   delete item n, clear the queue if omitted
+//	POST   /playlist/move      {"from": n, "to": m}
+//	GET    /search?q=&page=    search
+//	POST   /play               resume or play {"index": n}
+//	POST   /pause              pause
+//	POST   /next, /prev        next or previous item
+//	POST   /seek               {"position": seconds} or {"relative": seconds}
+//	POST   /volume             {"delta": steps}
+//	POST   /command            any command the ui accepts {"command": ":sleep 30m"}
+//	POST   /sync               sync the queue {"link": "id|url|off"}, link is optional
+//
+// Transport commands are sent on the queue passed to Play.
+type API struct {
+	ym    *YM
+	token string
+	queue chan<- *command.Command
+	added func(search.Result)
+	mux   *http.ServeMux
+}
+
+// API creates an API that requires the given bearer token (or token
+// query parameter) unless it is empty. added, if not nil, is called
+// with every item that is enqueued.
+func (ym *YM) API(
+	token string,
+	queue chan<- *command.Command,
+	added func(search.Result),
+) *API {
+	a := &API{ym: ym, token: token, queue: queue, added: added}
+	a.mux = http.NewServeMux()
+	a.mux.HandleFunc("/status", a.get(a.status))
+	a.mux.HandleFunc("/events", a.events)
+	a.mux.HandleFunc("/playlist", a.playlist)
+	a.mux.HandleFunc("/playlist/move", a.post(a.move))
+	a.mux.HandleFunc("/search", a.get(a.search))
+	a.mux.HandleFunc("/play", a.post(a.play))
+	a.mux.HandleFunc("/pause", a.post(a.pause))
+	a.mux.HandleFunc("/next", a.post(a.send(">")))
+	a.mux.HandleFunc("/prev", a.post(a.send("<")))
+	a.mux.HandleFunc("/seek", a.post(a.seek))
+	a.mux.HandleFunc("/volume", a.post(a.volume))
+	a.mux.HandleFunc("/command", a.post(a.raw))
+	a.mux.HandleFunc("/sync", a.post(a.sync))
+
+	return a
+}
+
+// ListenAndServe serves the api on addr.
+func (a *API) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, a)
+}
+
+func (a *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(r) {
+		apiError(w, http.StatusUnauthorized, errors.New("Invalid token"))
+		return
+	}
+
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *API) authorized(r *http.Request) bool {
+	if a.token == "" {
+		return true
+	}
+
+	t := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		t = h[7:]
+	}
+
+	return subtle.ConstantTimeCompare([]byte(t), []byte(a.token)) == 1
+}
+
+type apiHandler func(r *http.Request) (interface{}, int, error)
+
+func (a *API) get(h apiHandler) http.HandlerFunc {
+	return a.method(http.MethodGet, h)
+}
+
+func (a *API) post(h apiHandler) http.HandlerFunc {
+	return a.method(http.MethodPost, h)
+}
+
+func (a *API) method(method string, h apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			apiError(w, http.StatusMethodNotAllowed, errors.New("Method not allowed"))
+			return
+		}
+		respond(w, h, r)
+	}
+}
+
+func respond(w http.ResponseWriter, h apiHandler, r *http.Request) {
+	v, code, err := h(r)
+	if err != nil {
+		apiError(w, code, err)
+		return
+	}
+
+	if v == nil {
+		v = struct{}{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func apiError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("Invalid request body: %s", err)
+	}
+
+	return nil
+}
+
+func newItem(index int, r search.Result) *Item {
+	return &Item{Index: index, ID: r.ID(), Title: r.Title(), URL: r.PageURL().String()}
+}
+
+// NowPlaying returns the current player state.
+func (ym *YM) NowPlaying() *NowPlaying {
+	ym.sem.Lock()
+	volume := ym.volume
+	ym.sem.Unlock()
+
+	n := &NowPlaying{
+		State:  ym.State().String(),
+		Volume: volume,
+		Length: ym.playlist.Length(),
+		Random: ym.playlist.Random(),
+		Repeat: ym.playlist.Repeat(),
+	}
+
+	if remaining, atEnd, ok := ym.Sleep(); ok {
+		n.Sleep = &SleepTimer{remaining.Round(time.Second).Seconds(), atEnd}
+	}
+
+	cur := ym.Current()
+	if cur == nil {
+		return n
+	}
+
+	pos := ym.clock.pos()
+	if pos.Dur == 0 {
+		pos.Dur = ym.duration(cur)
+	}
+	n.Current = newItem(ym.playlist.Index()+1, cur)
+	n.Position = pos.Cur.Seconds()
+	n.Duration = pos.Dur.Seconds()
+	if title, index, total, ok := ym.Chapter(); ok {
+		n.Chapter = &ChapterInfo{title, index, total}
+	}
+
+	return n
+}
+
+func (a *API) status(r *http.Request) (interface{}, int, error) {
+	return a.ym.NowPlaying(), http.StatusOK, nil
+}
+
+func (a *API) items() []*Item {
+	list := a.ym.playlist.ResultList()
+	items := make([]*Item, len(list))
+	for i, r := range list {
+		items[i] = newItem(i+1, r)
+		if a.ym.positions != nil {
+			items[i].Resume = a.ym.positions.Has(r.ID())
+		}
+	}
+
+	return items
+}
+
+func (a *API) playlist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		respond(w, func(r *http.Request) (interface{}, int, error) {
+			return a.items(), http.StatusOK, nil
+		}, r)
+	case http.MethodPost:
+		respond(w, a.enqueue, r)
+	case http.MethodDelete:
+		respond(w, a.delete, r)
+	default:
+		apiError(w, http.StatusMethodNotAllowed, errors.New("Method not allowed"))
+	}
+}
+
+func (a *API) enqueue(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if req.ID == "" {
+		return nil, http.StatusBadRequest, errors.New("No id specified")
+	}
+
+	res := search.NewYoutubeResult(req.ID, req.Title)
+	if req.Title == "" {
+		info, err := res.Info()
+		if err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+		res = search.NewYoutubeResult(req.ID, info.Title())
+	}
+
+	a.ym.playlist.Add(command.New(nil).SetResult(res))
+	if a.added != nil {
+		a.added(res)
+	}
+
+	return newItem(0, res), http.StatusCreated, nil
+}
+
+func (a *API) delete(r *http.Request) (interface{}, int, error) {
+	ix := r.URL.Query().Get("index")
+	if ix == "" {
+		return a.command(":clear")
+	}
+
+	i, err := strconv.Atoi(ix)
+	if err != nil || i < 1 || i > a.ym.playlist.Length() {
+		return nil, http.StatusBadRequest, errors.New("Invalid index")
+	}
+
+	return a.command(fmt.Sprintf(":delete %d", i))
+}
+
+func (a *API) move(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		From int `json:"from"`
+		To   int `json:"to"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	l := a.ym.playlist.Length()
+	if req.From < 1 || req.To < 1 || req.From > l || req.To > l {
+		return nil, http.StatusBadRequest, errors.New("Invalid index")
+	}
+
+	return a.command(fmt.Sprintf(":move %d %d", req.From, req.To))
+}
+
+func (a *API) search(r *http.Request) (interface{}, int, error) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return nil, http.StatusBadRequest, errors.New("No query specified")
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	results, err := a.ym.search.Search(q, page)
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	items := make([]*Item, 0, len(results))
+	for _, res := range results {
+		if !res.IsPlayList() {
+			items = append(items, newItem(0, res))
+		}
+	}
+
+	return items, http.StatusOK, nil
+}
+
+func (a *API) raw(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		Command string `json:"command"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if req.Command == "" {
+		return nil, http.StatusBadRequest, errors.New("No command specified")
+	}
+
+	return a.command(req.Command)
+}
+
+func (a *API) sync(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		Link string `json:"link"`
+	}
+	if r.ContentLength != 0 {
+		if err := decode(r, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	switch req.Link {
+	case "":
+	case "off":
+		if err := a.ym.Link(""); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return nil, http.StatusOK, nil
+	default:
+		if err := a.ym.Link(req.Link); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	diff, err := a.ym.Sync()
+	if err == errNotLinked {
+		return nil, http.StatusBadRequest, err
+	}
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	res := &SyncResult{
+		Added:   make([]*Item, len(diff.Added)),
+		Removed: make([]*Item, len(diff.Removed)),
+	}
+	for i, r := range diff.Added {
+		res.Added[i] = newItem(0, r)
+		if a.added != nil {
+			a.added(r)
+		}
+	}
+	for i, r := range diff.Removed {
+		res.Removed[i] = newItem(0, r)
+	}
+
+	return res, http.StatusOK, nil
+}
+
+func (a *API) command(cmd string) (interface{}, int, error) {
+	t := time.NewTimer(apiQueueTimeout)
+	defer t.Stop()
+	select {
+	case a.queue <- command.New([]rune(cmd)).SetDone():
+		return nil, http.StatusAccepted, nil
+	case <-t.C:
+		return nil, http.StatusServiceUnavailable, errors.New("Player is not accepting commands")
+	}
+}
+
+func (a *API) send(cmd string) apiHandler {
+	return func(r *http.Request) (interface{}, int, error) {
+		return a.command(cmd)
+	}
+}
+
+func (a *API) play(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		Index int `json:"index"`
+	}
+	if r.ContentLength != 0 {
+		if err := decode(r, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	if req.Index != 0 {
+		if req.Index < 0 || req.Index > a.ym.playlist.Length() {
+			return nil, http.StatusBadRequest, errors.New("Invalid index")
+		}
+		return a.command(strconv.Itoa(req.Index))
+	}
+
+	if a.ym.State() == StatePlay {
+		return nil, http.StatusOK, nil
+	}
+
+	return a.command(".")
+}
+
+func (a *API) pause(r *http.Request) (interface{}, int, error) {
+	if a.ym.State() != StatePlay {
+		return nil, http.StatusOK, nil
+	}
+
+	return a.command(".")
+}
+
+func (a *API) seek(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		Position *float64 `json:"position"`
+		Relative float64  `json:"relative"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	pos := a.ym.clock.pos().Cur.Seconds() + req.Relative
+	if req.Position != nil {
+		pos = *req.Position
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	return a.command(":seek " + strconv.FormatFloat(pos, 'f', 3, 64))
+}
+
+func (a *API) volume(r *http.Request) (interface{}, int, error) {
+	var req struct {
+		Delta int `json:"delta"`
+	}
+	if err := decode(r, &req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	step := 1
+	if req.Delta < 0 {
+		step, req.Delta = -1, -req.Delta
+	}
+	if req.Delta > apiVolumeMax {
+		req.Delta = apiVolumeMax
+	}
+	for i := 0; i < req.Delta; i++ {
+		if v, code, err := a.command(fmt.Sprintf(":volume %d", step)); err != nil {
+			return v, code, err
+		}
+	}
+
+	return nil, http.StatusAccepted, nil
+}
+
+// events streams status, position and playlist events until the client
+// disconnects. Status and playlist events are sent when they change and
+// once when the stream starts, position events every second while playing.
+// The playlist is only compared after events that can change it.
+func (a *API) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apiError(w, http.StatusMethodNotAllowed, errors.New("Method not allowed"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiError(w, http.StatusInternalServerError, errors.New("Streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v interface{}) error {
+		d, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, d); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	var lastStatus, lastPlaylist []byte
+	var lastPosition time.Time
+	sub := a.ym.Events().Notify()
+	defer sub.Close()
+	// the resume flag of items changes when they stop playing
+	list := a.ym.Events().NotifyOf(func(e Event) bool {
+		switch e.(type) {
+		case PlaylistEvent, TrackEvent, StateEvent:
+			return true
+		}
+		return false
+	})
+	defer list.Close()
+	tick := time.NewTicker(apiTick)
+	defer tick.Stop()
+	listChanged := true
+	for {
+		n := a.ym.NowPlaying()
+		pos := n.Position
+		n.Position = 0
+		if s, _ := json.Marshal(n); string(s) != string(lastStatus) {
+			lastStatus = s
+			n.Position = pos
+			if err := send("status", n); err != nil {
+				return
+			}
+		}
+
+		if n.State == "play" && time.Since(lastPosition) >= time.Second {
+			lastPosition = time.Now()
+			if err := send("position", map[string]float64{"position": pos}); err != nil {
+				return
+			}
+		}
+
+		if listChanged {
+			listChanged = false
+			items := a.items()
+			if p, _ := json.Marshal(items); string(p) != string(lastPlaylist) {
+				lastPlaylist = p
+				if err := send("playlist", items); err != nil {
+					return
+				}
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-list.C:
+			listChanged = true
+		case <-sub.C:
+		case <-tick.C:
+		}
+	}
+}
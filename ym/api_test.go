@@ -0,0 +1,132 @@
+package ym
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/search"
+)
+
+func testYM(t *testing.T) (*YM, func()) {
+	dir, err := ioutil.TempDir("", "ym-api-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan struct{})
+	go func() {
+		for range updates {
+		}
+	}()
+
+	pl := playlist.New(filepath.Join(dir, "playlist"), 10, updates)
+	return New(pl, &playlistEngine{}, nil, nil, nil, nil), func() {
+		close(updates)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAPIVolume(t *testing.T) {
+	ym, done := testYM(t)
+	defer done()
+
+	queue := make(chan *command.Command)
+	received := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case <-queue:
+				n++
+			case <-time.After(time.Millisecond * 100):
+				received <- n
+				return
+			}
+		}
+	}()
+
+	s := httptest.NewServer(ym.API("", queue, nil))
+	defer s.Close()
+
+	res, err := http.Post(s.URL+"/volume", "application/json", strings.NewReader(`{"delta":-1000000000}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		t.Errorf("status %d", res.StatusCode)
+	}
+
+	if n := <-received; n != apiVolumeMax {
+		t.Errorf("%d volume commands, expected %d", n, apiVolumeMax)
+	}
+}
+
+func TestAPIEvents(t *testing.T) {
+	ym, done := testYM(t)
+	defer done()
+
+	s := httptest.NewServer(ym.API("", make(chan *command.Command), nil))
+	defer s.Close()
+
+	res, err := http.Get(s.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		var event string
+		for scanner.Scan() {
+			l := scanner.Text()
+			switch {
+			case strings.HasPrefix(l, "event: "):
+				event = l[7:]
+			case strings.HasPrefix(l, "data: "):
+				events <- event + " " + l[6:]
+			}
+		}
+		close(events)
+	}()
+
+	next := func() string {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second * 5):
+			t.Fatal("no event received")
+		}
+		return ""
+	}
+
+	if e := next(); !strings.HasPrefix(e, "status ") {
+		t.Errorf("expected the status first, got %s", e)
+	}
+	if e := next(); e != "playlist []" {
+		t.Errorf("expected an empty playlist, got %s", e)
+	}
+
+	ym.playlist.Add(command.New(nil).SetResult(search.NewYoutubeResult("video", "Video")))
+	ym.Events().Publish(PlaylistEvent{})
+	for {
+		e := next()
+		if strings.HasPrefix(e, "status ") {
+			continue
+		}
+		if !strings.HasPrefix(e, "playlist ") || !strings.Contains(e, `"id":"video"`) {
+			t.Errorf("expected the playlist with the new item, got %s", e)
+		}
+		break
+	}
+}
@@ -0,0 +1,117 @@
+package ym
+
+import (
+	"context"
+	"time"
+
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/search"
+)
+
+// chapterRestart is how far into a chapter going to the previous chapter
+// restarts the current one instead.
+const chapterRestart = time.Second * 3
+
+type chapters struct {
+	id   string
+	list []search.Chapter
+}
+
+// loadChapters fetches the chapters of r in the background.
+func (ym *YM) loadChapters(r search.Result) {
+	ym.sem.Lock()
+	ym.chapters = chapters{id: r.ID()}
+	ym.sem.Unlock()
+
+	var trimmed time.Duration
+	if ym.cache.Get(r.ID()) != nil {
+		trimmed = ym.cache.Meta(r.ID()).Trimmed
+	}
+
+	go func() {
+		info, err := r.Info()
+		if err != nil {
+			return
+		}
+		// retried when the item plays again
+		list, _ := search.FetchChapters(context.Background(), info)
+		list = search.ShiftChapters(list, trimmed)
+
+		ym.sem.Lock()
+		if ym.chapters.id == r.ID() {
+			ym.chapters.list = list
+		}
+		ym.sem.Unlock()
+	}()
+}
+
+func (ym *YM) currentChapters() []search.Chapter {
+	cur := ym.Current()
+	ym.sem.Lock()
+	defer ym.sem.Unlock()
+	if cur == nil || ym.chapters.id != cur.ID() {
+		return nil
+	}
+
+	return ym.chapters.list
+}
+
+// Chapter returns the title and index of the chapter that is playing
+// and the total amount of chapters.
+func (ym *YM) Chapter() (title string, index, total int, ok bool) {
+	list := ym.currentChapters()
+	i := search.ChapterAt(list, ym.clock.pos().Cur)
+	if i < 0 {
+		return "", 0, 0, false
+	}
+
+	return list[i].Title, i, len(list), true
+}
+
+// chapter seeks to the chapter relative to the current one,
+// returns false if there is no such chapter.
+func (ym *YM) chapter(rel int) (player.Command, bool) {
+	list := ym.currentChapters()
+	pos := ym.clock.pos().Cur
+	i := search.ChapterAt(list, pos)
+	if i < 0 {
+		return player.CmdNil, false
+	}
+
+	if rel < 0 && pos-list[i].Start > chapterRestart {
+		rel++
+	}
+
+	i += rel
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(list) {
+		return player.CmdNil, false
+	}
+
+	return ym.seekTo(list[i].Start), true
+}
+
+// seekTo seeks in the current item, players that can't are restarted
+// at pos, in which case CmdStop is returned.
+func (ym *YM) seekTo(pos time.Duration) player.Command {
+	if s, ok := ym.player.(player.Seeker); ok {
+		if err := s.SeekTo(pos); err == nil {
+			ym.clock.set(pos)
+			ym.seeked()
+			return player.CmdNil
+		}
+	}
+
+	cur := ym.Current()
+	if cur == nil {
+		return player.CmdNil
+	}
+
+	ym.sem.Lock()
+	ym.startAt = startAt{cur.ID(), pos}
+	ym.sem.Unlock()
+	ym.playlist.SetIndex(ym.playlist.Index())
+	return player.CmdStop
+}
@@ -0,0 +1,175 @@
+package ym
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client talks to an API, e.g.: a daemon listening on a unix socket.
+type Client struct {
+	token string
+	http  *http.Client
+}
+
+// NewClient creates a client for the API listening on addr,
+// network is either unix or tcp.
+func NewClient(network, addr, token string) *Client {
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+
+	return &Client{
+		token: token,
+		http:  &http.Client{Transport: &http.Transport{DialContext: dial}},
+	}
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		d, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(d)
+	}
+
+	req, err := http.NewRequest(method, "http://ym"+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		var e struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+			return nil, fmt.Errorf("Request failed: %s", res.Status)
+		}
+		return nil, errors.New(e.Error)
+	}
+
+	return res, nil
+}
+
+// Do sends body as json to path and decodes the response into v
+// if it is not nil.
+func (c *Client) Do(method, path string, body, v interface{}) error {
+	res, err := c.request(context.Background(), method, path, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if v == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (c *Client) Status() (*NowPlaying, error) {
+	n := &NowPlaying{}
+	return n, c.Do(http.MethodGet, "/status", nil, n)
+}
+
+func (c *Client) Playlist() ([]*Item, error) {
+	var items []*Item
+	return items, c.Do(http.MethodGet, "/playlist", nil, &items)
+}
+
+func (c *Client) Search(q string, page int) ([]*Item, error) {
+	var items []*Item
+	return items, c.Do(
+		http.MethodGet,
+		fmt.Sprintf("/search?q=%s&page=%d", url.QueryEscape(q), page),
+		nil,
+		&items,
+	)
+}
+
+// Add enqueues the item with the given id, the daemon looks up
+// the title if it is empty.
+func (c *Client) Add(id, title string) (*Item, error) {
+	i := &Item{}
+	return i, c.Do(
+		http.MethodPost,
+		"/playlist",
+		map[string]string{"id": id, "title": title},
+		i,
+	)
+}
+
+// Command sends a command as typed in the ui, e.g.: >, :delete 3.
+func (c *Client) Command(cmd string) error {
+	return c.Do(
+		http.MethodPost,
+		"/command",
+		map[string]string{"command": cmd},
+		nil,
+	)
+}
+
+// Sync links the queue to link unless it is empty and syncs it,
+// see YM.Sync.
+func (c *Client) Sync(link string) (*SyncResult, error) {
+	r := &SyncResult{}
+	return r, c.Do(
+		http.MethodPost,
+		"/sync",
+		map[string]string{"link": link},
+		r,
+	)
+}
+
+// Events calls fn with the name and data of each server-sent event
+// until ctx is done, the connection is lost or fn returns an error.
+func (c *Client) Events(ctx context.Context, fn func(event string, data []byte) error) error {
+	res, err := c.request(ctx, http.MethodGet, "/events", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var event string
+	scan := bufio.NewScanner(res.Body)
+	scan.Buffer(nil, 1024*1024*16)
+	for scan.Scan() {
+		line := scan.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = line[7:]
+		case strings.HasPrefix(line, "data: "):
+			if err := fn(event, []byte(line[6:])); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := scan.Err(); err != nil {
+		return err
+	}
+
+	return io.EOF
+}
@@ -0,0 +1,108 @@
+package ym
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/search"
+)
+
+func TestClient(t *testing.T) {
+	ym, done := testYM(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "ym-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "ym.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue := make(chan *command.Command, 10)
+	added := func(search.Result) { ym.Events().Publish(PlaylistEvent{}) }
+	s := &http.Server{Handler: ym.API("secret", queue, added)}
+	go s.Serve(l)
+	defer s.Close()
+
+	if _, err := NewClient("unix", socket, "wrong").Status(); err == nil || err.Error() != "Invalid token" {
+		t.Errorf("expected an invalid token error, got %v", err)
+	}
+
+	// several uis attached to the same daemon
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	uis := make([]chan string, 2)
+	for i := range uis {
+		events := make(chan string, 100)
+		uis[i] = events
+		go NewClient("unix", socket, "secret").Events(ctx, func(event string, data []byte) error {
+			events <- event + " " + string(data)
+			return nil
+		})
+	}
+
+	c := NewClient("unix", socket, "secret")
+	if _, err := c.Status(); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := c.Add("video", "Video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ID != "video" || item.Title != "Video" {
+		t.Errorf("added %+v", item)
+	}
+
+	for i, events := range uis {
+		timeout := time.After(time.Second * 5)
+	wait:
+		for {
+			select {
+			case e := <-events:
+				if strings.HasPrefix(e, "playlist ") && strings.Contains(e, `"id":"video"`) {
+					break wait
+				}
+			case <-timeout:
+				t.Fatalf("ui %d did not receive the new playlist", i)
+			}
+		}
+	}
+
+	items, err := c.Playlist()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].ID != "video" {
+		t.Errorf("playlist %+v", items)
+	}
+
+	if err := c.Command(":delete 1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case cmd := <-queue:
+		if cmd.String() != ":delete 1" {
+			t.Errorf("received command %s", cmd)
+		}
+	default:
+		t.Error("command was not queued")
+	}
+
+	if _, err := c.Sync(""); err == nil || err.Error() != errNotLinked.Error() {
+		t.Errorf("expected %v, got %v", errNotLinked, err)
+	}
+}
@@ -0,0 +1,88 @@
+package ym
+
+import (
+	"sync"
+	"time"
+
+	"github.com/frizinak/ym/player"
+)
+
+// clock keeps track of the playback position, synced with the player
+// when it reports its position and estimated otherwise.
+type clock struct {
+	sem     sync.Mutex
+	offset  time.Duration
+	started time.Time
+	paused  bool
+	dur     time.Duration
+	// exact is set if dur is known and should not be replaced by the
+	// player's estimate.
+	exact bool
+}
+
+func (c *clock) reset(offset, dur time.Duration) {
+	c.sem.Lock()
+	c.offset = offset
+	c.started = time.Now()
+	c.paused = false
+	c.dur = dur
+	c.exact = dur > 0
+	c.sem.Unlock()
+}
+
+func (c *clock) pause(paused bool) {
+	c.sem.Lock()
+	if paused != c.paused {
+		c.offset = c.cur()
+		c.started = time.Now()
+		c.paused = paused
+	}
+	c.sem.Unlock()
+}
+
+func (c *clock) seek(d time.Duration) {
+	c.sem.Lock()
+	c.offset = c.cur() + d
+	if c.offset < 0 {
+		c.offset = 0
+	}
+	c.started = time.Now()
+	c.sem.Unlock()
+}
+
+func (c *clock) set(pos time.Duration) {
+	c.sem.Lock()
+	c.offset = pos
+	c.started = time.Now()
+	c.sem.Unlock()
+}
+
+func (c *clock) sync(p *player.Pos) *player.Pos {
+	c.sem.Lock()
+	defer c.sem.Unlock()
+	c.offset = p.Cur
+	c.started = time.Now()
+	if c.exact {
+		return &player.Pos{Cur: p.Cur, Dur: c.dur}
+	}
+	if p.Dur > 0 {
+		c.dur = p.Dur
+	}
+
+	return p
+}
+
+func (c *clock) pos() *player.Pos {
+	c.sem.Lock()
+	p := &player.Pos{Cur: c.cur(), Dur: c.dur}
+	c.sem.Unlock()
+	return p
+}
+
+func (c *clock) cur() time.Duration {
+	if c.paused {
+		return c.offset
+	}
+
+	return c.offset + time.Since(c.started)
+}
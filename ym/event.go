@@ -0,0 +1,243 @@
+package ym
+
+import (
+	"sync"
+	"time"
+
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/search"
+)
+
+type State int
+
+const (
+	StateStop State = iota
+	StatePlay
+	StatePause
+)
+
+func (s State) String() string {
+	switch s {
+	case StatePlay:
+		return "play"
+	case StatePause:
+		return "pause"
+	}
+	return "stop"
+}
+
+// Event is published on the Bus, it is one of the *Event types below.
+type Event interface {
+	event()
+}
+
+// StateEvent is published when playback starts, pauses or stops.
+type StateEvent struct{ State State }
+
+// TrackEvent is published when an item starts playing,
+// Track is nil once it stops.
+type TrackEvent struct{ Track search.Result }
+
+// PositionEvent is published when the player reports its position.
+type PositionEvent struct{ Pos *player.Pos }
+
+// SeekEvent is published when the position jumps, after a seek or when
+// an item starts anywhere but the beginning.
+type SeekEvent struct{ Pos time.Duration }
+
+// VolumeEvent is published when the player reports its volume.
+type VolumeEvent struct{ Volume int }
+
+// PlaylistEvent is published when the queue changes.
+type PlaylistEvent struct{}
+
+// ErrorEvent is published for errors that don't stop playback.
+type ErrorEvent struct{ Err error }
+
+func (StateEvent) event()    {}
+func (TrackEvent) event()    {}
+func (PositionEvent) event() {}
+func (SeekEvent) event()     {}
+func (VolumeEvent) event()   {}
+func (PlaylistEvent) event() {}
+func (ErrorEvent) event()    {}
+
+// Bus delivers published events to all subscriptions in order.
+// Publish never blocks, subscriptions returned by Subscribe queue events
+// until they are received so a slow subscriber can't hold up the player.
+type Bus struct {
+	sem  sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C     <-chan Event
+	c     chan Event
+	lossy bool
+	match func(Event) bool
+	done  chan struct{}
+	once  sync.Once
+	bus   *Bus
+
+	sem     sync.Mutex
+	pending []Event
+	wake    chan struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription whose channel has the given buffer,
+// events that don't fit are queued.
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	return b.subscribe(buffer, false, nil)
+}
+
+// Notify returns a subscription that drops events while its buffer
+// of one is full, for subscribers that only need to know something
+// happened.
+func (b *Bus) Notify() *Subscription {
+	return b.NotifyOf(nil)
+}
+
+// NotifyOf is Notify for the events match returns true for, so a pending
+// event means one of them happened since it was last received.
+func (b *Bus) NotifyOf(match func(Event) bool) *Subscription {
+	return b.subscribe(1, true, match)
+}
+
+func (b *Bus) subscribe(buffer int, lossy bool, match func(Event) bool) *Subscription {
+	c := make(chan Event, buffer)
+	s := &Subscription{
+		C:     c,
+		c:     c,
+		lossy: lossy,
+		match: match,
+		done:  make(chan struct{}),
+		bus:   b,
+		wake:  make(chan struct{}, 1),
+	}
+	if !lossy {
+		go s.deliver()
+	}
+	b.sem.Lock()
+	b.subs[s] = struct{}{}
+	b.sem.Unlock()
+	return s
+}
+
+// Close stops delivery, C is not closed.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.sem.Lock()
+		delete(s.bus.subs, s)
+		s.bus.sem.Unlock()
+		close(s.done)
+	})
+}
+
+// deliver sends the queued events on c in order until the
+// subscription is closed.
+func (s *Subscription) deliver() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+
+		s.sem.Lock()
+		events := s.pending
+		s.pending = nil
+		s.sem.Unlock()
+
+		for _, e := range events {
+			select {
+			case s.c <- e:
+			case <-s.done:
+				return
+			}
+		}
+	}
+}
+
+// Publish sends e to all subscriptions.
+func (b *Bus) Publish(e Event) {
+	b.sem.Lock()
+	subs := make([]*Subscription, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.sem.Unlock()
+
+	for _, s := range subs {
+		if s.match != nil && !s.match(e) {
+			continue
+		}
+		if s.lossy {
+			select {
+			case s.c <- e:
+			default:
+			}
+			continue
+		}
+
+		s.sem.Lock()
+		s.pending = append(s.pending, e)
+		s.sem.Unlock()
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Events returns the bus Play and the Sync* methods publish on.
+func (ym *YM) Events() *Bus {
+	return ym.bus
+}
+
+// State returns whether the player is playing, paused or stopped.
+func (ym *YM) State() State {
+	ym.status.RLock()
+	defer ym.status.RUnlock()
+	return ym.state
+}
+
+// Current returns the item that is playing or paused, nil if stopped.
+func (ym *YM) Current() search.Result {
+	ym.status.RLock()
+	defer ym.status.RUnlock()
+	return ym.current
+}
+
+func (ym *YM) setState(s State) {
+	ym.status.Lock()
+	if ym.state == StatePlay && s != StatePlay {
+		ym.played += time.Since(ym.playing)
+	} else if ym.state != StatePlay && s == StatePlay {
+		ym.playing = time.Now()
+	}
+	ym.state = s
+	ym.status.Unlock()
+	ym.bus.Publish(StateEvent{s})
+}
+
+func (ym *YM) setCurrent(r search.Result) {
+	ym.status.Lock()
+	ym.current = r
+	ym.status.Unlock()
+	if r != nil {
+		ym.metrics.played.Inc()
+	}
+	ym.bus.Publish(TrackEvent{r})
+}
+
+func (ym *YM) seeked() {
+	ym.bus.Publish(SeekEvent{ym.clock.pos().Cur})
+}
+
+func (ym *YM) error(err error) {
+	ym.bus.Publish(ErrorEvent{err})
+}
@@ -0,0 +1,134 @@
+package ym
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBusOrder(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(0)
+	defer sub.Close()
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			b.Publish(VolumeEvent{i})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second * 5):
+		t.Fatal("Publish blocked on a subscriber that isn't receiving")
+	}
+
+	for i := 0; i < 1000; i++ {
+		e := (<-sub.C).(VolumeEvent)
+		if e.Volume != i {
+			t.Fatalf("received %d, expected %d", e.Volume, i)
+		}
+	}
+}
+
+func TestBusNotify(t *testing.T) {
+	b := NewBus()
+	all := b.Notify()
+	defer all.Close()
+	errs := b.NotifyOf(func(e Event) bool {
+		_, ok := e.(ErrorEvent)
+		return ok
+	})
+	defer errs.Close()
+
+	b.Publish(VolumeEvent{1})
+	b.Publish(VolumeEvent{2})
+	if e := (<-all.C).(VolumeEvent); e.Volume != 1 {
+		t.Errorf("received %d, expected the first event", e.Volume)
+	}
+	select {
+	case e := <-all.C:
+		t.Errorf("received %v, expected it to be dropped", e)
+	default:
+	}
+	select {
+	case e := <-errs.C:
+		t.Errorf("received %v, expected only errors", e)
+	default:
+	}
+
+	b.Publish(ErrorEvent{errors.New("err")})
+	select {
+	case <-errs.C:
+	default:
+		t.Error("error was not received")
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	b := NewBus()
+	sub := b.Subscribe(0)
+	b.Publish(PlaylistEvent{})
+	sub.Close()
+	sub.Close()
+	b.Publish(PlaylistEvent{})
+
+	b.sem.Lock()
+	n := len(b.subs)
+	b.sem.Unlock()
+	if n != 0 {
+		t.Errorf("%d subscriptions left after Close", n)
+	}
+}
+
+func TestBusConcurrent(t *testing.T) {
+	b := NewBus()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Publish(StateEvent{StatePlay})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sub := b.Subscribe(j % 3)
+				if j%2 == 0 {
+					select {
+					case <-sub.C:
+					case <-time.After(time.Millisecond):
+					}
+				}
+				sub.Close()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sub := b.Notify()
+				select {
+				case <-sub.C:
+				default:
+				}
+				sub.Close()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 10):
+		t.Fatal("deadlock")
+	}
+}
@@ -0,0 +1,168 @@
+package ym
+
+import (
+	"context"
+	"time"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/search"
+)
+
+// queued is an item that was handed to the player ahead of time.
+type queued struct {
+	result   search.Result
+	start    time.Duration
+	commands chan player.Command
+	wait     func()
+	cached   bool
+}
+
+type prepared struct {
+	result search.Result
+	file   string
+	params []player.Param
+	start  time.Duration
+	cached bool
+	err    error
+}
+
+// SetGapless enables handing the next item to the player before the
+// current one ends if the player supports it (see player.Appender).
+// A crossfade > 0 overlaps consecutive items instead.
+func (ym *YM) SetGapless(gapless bool, crossfade time.Duration) {
+	ym.gapless = gapless
+	ym.crossfade = crossfade
+}
+
+// playing is an item handed to the player, fading is closed once it
+// starts fading out into the next item and done once it ended.
+type playing struct {
+	fading <-chan struct{}
+	done   chan struct{}
+}
+
+func newPlaying(fading <-chan struct{}, wait func()) *playing {
+	p := &playing{fading: fading, done: make(chan struct{})}
+	go func() {
+		wait()
+		close(p.done)
+	}()
+
+	return p
+}
+
+// handoff is the next item to play, after is closed once the item that
+// is fading out into it ended.
+type handoff struct {
+	cmd   *command.Command
+	after <-chan struct{}
+}
+
+// fadedIn is an item that was spawned while the previous one faded out.
+type fadedIn struct {
+	result   search.Result
+	start    time.Duration
+	commands chan player.Command
+}
+
+// spawn starts file, crossfading if the player supports it.
+func (ym *YM) spawn(file string, params []player.Param) (chan player.Command, *playing, error) {
+	if f, ok := ym.player.(player.Crossfader); ok && ym.crossfade > 0 {
+		commands, fading, wait, err := f.SpawnFade(file, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		return commands, newPlaying(fading, wait), nil
+	}
+
+	commands, wait, err := ym.player.Spawn(file, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return commands, newPlaying(nil, wait), nil
+}
+
+func (ym *YM) appender() player.Appender {
+	if !ym.gapless || ym.crossfade > 0 {
+		return nil
+	}
+
+	a, _ := ym.player.(player.Appender)
+	return a
+}
+
+func (ym *YM) prepare(r search.Result, c chan<- *prepared) {
+	p := &prepared{result: r, cached: ym.cache.Get(r.ID()) != nil}
+	p.file, p.params, p.start, p.err = ym.resolve(r, true)
+	c <- p
+}
+
+// resolve returns the file or url to play for r and the params to
+// pass to the player. Items that are resolved ahead of time should peek
+// so they don't consume the start position of the item about to play
+// and aren't counted in the metrics as they might never play.
+func (ym *YM) resolve(r search.Result, peek bool) (
+	file string,
+	params []player.Param,
+	start time.Duration,
+	err error,
+) {
+	// TODO
+	// if c.Cmd() != '!' {
+	cached := ym.cache.Get(r.ID())
+	if cached != nil {
+		file = cached.Path()
+	}
+	if !peek {
+		ym.countCached(cached != nil)
+	}
+	// }
+
+	if file == "" {
+		if u := ym.prefetched(r.ID()); u != nil {
+			file = u.String()
+		}
+	}
+
+	if file == "" {
+		u, err := r.DownloadURLs(ym.formats)
+		if err != nil {
+			ym.resolveFailed(r, peek)
+			return "", nil, 0, err
+		}
+		du, err := u.FindContext(context.Background(), ym.preflight)
+		if err != nil {
+			ym.resolveFailed(r, peek)
+			return "", nil, 0, err
+		}
+		file = du.String()
+	}
+
+	params = []player.Param{player.ParamSilent}
+
+	// TODO
+	// if c.Cmd() != '!' {
+	params = append(params, player.ParamNoVideo)
+	// }
+
+	if gain, ok := ym.gain(r.ID()); ok {
+		params = append(params, player.Gain(gain))
+	}
+	if cached != nil {
+		// analyzing a stream would download it again
+		ym.analyze(r.ID(), file)
+	}
+	ym.inspect(r.ID())
+
+	start = ym.resumeAt(r.ID(), peek)
+	if start > 0 {
+		params = append(params, player.Start(start))
+	}
+
+	if ym.crossfade > 0 {
+		params = append(params, player.Crossfade(ym.crossfade))
+	}
+
+	return
+}
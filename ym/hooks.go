@@ -0,0 +1,73 @@
+package ym
+
+import (
+	"strconv"
+
+	"github.com/frizinak/ym/hooks"
+	"github.com/frizinak/ym/search"
+)
+
+// RunHooks runs h for the events published by Play, it never returns.
+func (ym *YM) RunHooks(h *hooks.Hooks) {
+	sub := ym.bus.Subscribe(100)
+	defer sub.Close()
+
+	var cur search.Result
+	state := StateStop
+	for e := range sub.C {
+		switch e := e.(type) {
+		case TrackEvent:
+			if cur != nil {
+				h.Run(hooks.TrackEnd, ym.hookEnv(cur, state, nil))
+			}
+			cur = e.Track
+			if cur != nil {
+				h.Run(hooks.TrackStart, ym.hookEnv(cur, state, nil))
+			}
+		case StateEvent:
+			if e.State == StatePause {
+				h.Run(hooks.Pause, ym.hookEnv(cur, e.State, nil))
+			} else if e.State == StatePlay && state == StatePause {
+				h.Run(hooks.Resume, ym.hookEnv(cur, e.State, nil))
+			}
+			state = e.State
+		case PlaylistEvent:
+			h.Run(hooks.PlaylistChanged, ym.hookEnv(cur, state, nil))
+		case ErrorEvent:
+			h.Run(hooks.Error, ym.hookEnv(cur, state, e.Err))
+		}
+	}
+}
+
+// hookEnv returns the variables passed to hooks, r and err can be nil.
+// They are only looked up when the hook runs as the info might have
+// to be fetched.
+func (ym *YM) hookEnv(r search.Result, state State, err error) func() map[string]string {
+	return func() map[string]string {
+		env := map[string]string{
+			"STATE":           state.String(),
+			"PLAYLIST_LENGTH": strconv.Itoa(ym.playlist.Length()),
+		}
+		if err != nil {
+			env["ERROR"] = err.Error()
+		}
+		if r == nil {
+			return env
+		}
+
+		env["ID"] = r.ID()
+		env["TITLE"] = r.Title()
+		env["URL"] = r.PageURL().String()
+		if d := ym.duration(r); d > 0 {
+			env["DURATION"] = strconv.Itoa(int(d.Seconds()))
+		}
+		if info, err := r.Info(); err == nil {
+			env["AUTHOR"] = info.Author()
+		}
+		if c := ym.cache.Get(r.ID()); c != nil {
+			env["FILE"] = c.Path()
+		}
+
+		return env
+	}
+}
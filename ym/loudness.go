@@ -0,0 +1,108 @@
+package ym
+
+import (
+	"fmt"
+	"math"
+)
+
+type Normalize int
+
+const (
+	NormalizeOff Normalize = iota
+	// NormalizeTrack adjusts each track to the target loudness.
+	NormalizeTrack
+	// NormalizeAlbum treats the entire playlist as a single album and
+	// applies the same gain to every track.
+	NormalizeAlbum
+)
+
+// maxTruePeak is the highest true peak (dBTP) a track is allowed
+// to reach after applying gain.
+const maxTruePeak = -1.0
+
+func ParseNormalize(mode string) (Normalize, error) {
+	switch mode {
+	case "", "off":
+		return NormalizeOff, nil
+	case "track":
+		return NormalizeTrack, nil
+	case "album":
+		return NormalizeAlbum, nil
+	}
+
+	return NormalizeOff, fmt.Errorf("Invalid normalization mode '%s'", mode)
+}
+
+// SetNormalization configures loudness normalization towards target LUFS.
+func (ym *YM) SetNormalization(mode Normalize, target float64) {
+	ym.normalize = mode
+	ym.target = target
+}
+
+// gain returns the gain in dB to be applied to the given item,
+// ok is false if the loudness has not been measured yet.
+func (ym *YM) gain(id string) (gain float64, ok bool) {
+	switch ym.normalize {
+	case NormalizeTrack:
+		l := ym.cache.Meta(id).Loudness
+		if l == nil {
+			return 0, false
+		}
+		return calcGain(ym.target, l.Integrated, l.TruePeak), true
+	case NormalizeAlbum:
+		var energy float64
+		var n int
+		peak := math.Inf(-1)
+		for _, r := range ym.playlist.ResultList() {
+			l := ym.cache.Meta(r.ID()).Loudness
+			if l == nil {
+				continue
+			}
+
+			n++
+			energy += math.Pow(10, l.Integrated/10)
+			peak = math.Max(peak, l.TruePeak)
+		}
+
+		if n == 0 {
+			return 0, false
+		}
+
+		integrated := 10 * math.Log10(energy/float64(n))
+		return calcGain(ym.target, integrated, peak), true
+	}
+
+	return 0, false
+}
+
+// analyze measures the loudness of the given item in the background
+// if it is not known yet.
+func (ym *YM) analyze(id, input string) {
+	if ym.normalize == NormalizeOff || ym.cache.Meta(id).Loudness != nil {
+		return
+	}
+
+	ym.sem.Lock()
+	if _, ok := ym.analyzing[id]; ok {
+		ym.sem.Unlock()
+		return
+	}
+	ym.analyzing[id] = struct{}{}
+	ym.sem.Unlock()
+
+	go func() {
+		ym.cache.Analyze(id, input)
+		ym.sem.Lock()
+		delete(ym.analyzing, id)
+		ym.sem.Unlock()
+	}()
+}
+
+func calcGain(target, integrated, truePeak float64) float64 {
+	g := target - integrated
+	if max := maxTruePeak - truePeak; g > max {
+		g = max
+	}
+
+	return g
+}
@@ -0,0 +1,58 @@
+package ym
+
+import (
+	"time"
+
+	"github.com/frizinak/ym/search"
+)
+
+// knownDuration returns the duration of id as inspected after caching
+// if it is exact.
+func (ym *YM) knownDuration(id string) time.Duration {
+	if m := ym.cache.Meta(id).Media; m != nil && m.Exact {
+		return m.Duration()
+	}
+
+	return 0
+}
+
+// duration prefers the inspected duration over the one reported by the
+// search engine, an estimated one is only used as a last resort.
+func (ym *YM) duration(r search.Result) time.Duration {
+	if d := ym.knownDuration(r.ID()); d > 0 {
+		return d
+	}
+
+	if info, err := r.Info(); err == nil && info.Duration() > 0 {
+		return info.Duration()
+	}
+
+	if m := ym.cache.Meta(r.ID()).Media; m != nil {
+		return m.Duration()
+	}
+
+	return 0
+}
+
+// inspect inspects cached items that were cached before inspection
+// was available, or whose duration was estimated, in the background.
+func (ym *YM) inspect(id string) {
+	if m := ym.cache.Meta(id).Media; m != nil && m.Exact || ym.cache.Get(id) == nil {
+		return
+	}
+
+	ym.sem.Lock()
+	if _, ok := ym.inspecting[id]; ok {
+		ym.sem.Unlock()
+		return
+	}
+	ym.inspecting[id] = struct{}{}
+	ym.sem.Unlock()
+
+	go func() {
+		ym.cache.Inspect(id)
+		ym.sem.Lock()
+		delete(ym.inspecting, id)
+		ym.sem.Unlock()
+	}()
+}
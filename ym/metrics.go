@@ -0,0 +1,89 @@
+package ym
+
+import (
+	"path"
+	"strings"
+	"time"
+
+	"github.com/frizinak/ym/metrics"
+	"github.com/frizinak/ym/search"
+)
+
+type ymMetrics struct {
+	played  *metrics.Metric
+	skips   *metrics.Metric
+	resolve *metrics.Metric
+	hits    *metrics.Metric
+	misses  *metrics.Metric
+}
+
+// SetMetrics registers the playback metrics on r.
+func (ym *YM) SetMetrics(r *metrics.Registry) {
+	ym.metrics = ymMetrics{
+		played: r.Counter("ym_tracks_played_total", "Items that started playing."),
+		skips:  r.Counter("ym_skips_total", "Items skipped before they ended."),
+		resolve: r.Counter(
+			"ym_resolve_errors_total",
+			"Failed stream url resolutions.",
+			"backend",
+		),
+		hits:   r.Counter("ym_cache_hits_total", "Items played from the cache."),
+		misses: r.Counter("ym_cache_misses_total", "Items streamed as they weren't cached."),
+	}
+
+	r.CounterFunc("ym_play_seconds_total", "Time spent playing.", func() float64 {
+		return ym.playTime().Seconds()
+	})
+	r.GaugeFunc("ym_cache_hit_ratio", "Ratio of items played from the cache.", func() float64 {
+		hits, misses := ym.metrics.hits.Value(), ym.metrics.misses.Value()
+		if hits+misses == 0 {
+			return 0
+		}
+		return hits / (hits + misses)
+	})
+	r.GaugeFunc("ym_playlist_length", "Amount of items in the queue.", func() float64 {
+		return float64(ym.playlist.Length())
+	})
+}
+
+// playTime returns the total time spent playing.
+func (ym *YM) playTime() time.Duration {
+	ym.status.RLock()
+	defer ym.status.RUnlock()
+	d := ym.played
+	if ym.state == StatePlay {
+		d += time.Since(ym.playing)
+	}
+
+	return d
+}
+
+// skipped records skipping the current item.
+func (ym *YM) skipped() {
+	if ym.Current() != nil {
+		ym.metrics.skips.Inc()
+	}
+}
+
+// countCached records whether an item that starts playing was cached.
+func (ym *YM) countCached(hit bool) {
+	if hit {
+		ym.metrics.hits.Inc()
+		return
+	}
+	ym.metrics.misses.Inc()
+}
+
+// resolveFailed records failing to resolve r unless it was resolved
+// ahead of time.
+func (ym *YM) resolveFailed(r search.Result, peek bool) {
+	if !peek {
+		ym.metrics.resolve.Inc(backend(r))
+	}
+}
+
+// backend returns the name of the engine r belongs to, e.g.: youtube.
+func backend(r search.Result) string {
+	name := path.Base(search.ResultTypeName(r))
+	return strings.ToLower(strings.TrimSuffix(name, "Result"))
+}
@@ -0,0 +1,43 @@
+package ym
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/metrics"
+	"github.com/frizinak/ym/search"
+)
+
+func TestResolveMetrics(t *testing.T) {
+	ym, done := testYM(t)
+	defer done()
+	ym.SetMetrics(metrics.NewRegistry())
+
+	r := search.NewYoutubeResult("video", "Video")
+	u, _ := url.Parse("https://stream.example/video")
+	ym.prefetcher.urls[r.ID()] = &resolved{u, time.Now().Add(time.Minute)}
+
+	// prepared ahead of time, maybe more than once
+	for i := 0; i < 2; i++ {
+		if _, _, _, err := ym.resolve(r, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ym.resolveFailed(r, true)
+	if h, m, e := ym.metrics.hits.Value(), ym.metrics.misses.Value(), ym.metrics.resolve.Value("youtube"); h+m+e != 0 {
+		t.Errorf("peeking counted %v hits, %v misses and %v errors", h, m, e)
+	}
+
+	file, _, _, err := ym.resolve(r, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != u.String() {
+		t.Errorf("resolved %s", file)
+	}
+	ym.resolveFailed(r, false)
+	if h, m, e := ym.metrics.hits.Value(), ym.metrics.misses.Value(), ym.metrics.resolve.Value("youtube"); h != 0 || m != 1 || e != 1 {
+		t.Errorf("%v hits, %v misses and %v errors, expected a miss and an error", h, m, e)
+	}
+}
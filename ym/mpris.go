@@ -0,0 +1,395 @@
+// +build linux
+
+package ym
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/player"
+)
+
+const (
+	mprisName   = "org.mpris.MediaPlayer2.ym"
+	mprisPath   = "/org/mpris/MediaPlayer2"
+	mprisRoot   = "org.mpris.MediaPlayer2"
+	mprisPlayer = "org.mpris.MediaPlayer2.Player"
+	mprisTrack  = "/org/frizinak/ym/track/"
+	mprisTick   = time.Millisecond * 500
+	// mprisTimeout is how long a method call waits for the queue to accept
+	// a command.
+	mprisTimeout = time.Second * 2
+)
+
+// mprisMethods maps methods that can't have their dbus name.
+var mprisMethods = map[string]string{"SeekRelative": "Seek"}
+
+var mprisStatus = map[string]string{
+	"play":  "Playing",
+	"pause": "Paused",
+	"stop":  "Stopped",
+}
+
+// MPRIS exposes the player on dbus as an MPRIS2 media player.
+// Commands are sent on the queue passed to Play.
+type MPRIS struct {
+	ym    *YM
+	conn  *dbus.Conn
+	props *prop.Properties
+	queue chan<- *command.Command
+
+	name string
+	sem  sync.Mutex
+	id   string
+	quit chan struct{}
+}
+
+type mprisRootIface struct{}
+
+func (mprisRootIface) Raise() *dbus.Error { return nil }
+func (mprisRootIface) Quit() *dbus.Error  { return nil }
+
+// ServeMPRIS serves MPRIS on the session bus.
+func (ym *YM) ServeMPRIS(queue chan<- *command.Command) (io.Closer, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	return ym.MPRIS(conn, queue)
+}
+
+// MPRIS exports the MPRIS interfaces on conn and requests the
+// org.mpris.MediaPlayer2.ym name, or an instance name if it is taken.
+func (ym *YM) MPRIS(conn *dbus.Conn, queue chan<- *command.Command) (*MPRIS, error) {
+	m := &MPRIS{ym: ym, conn: conn, queue: queue, quit: make(chan struct{})}
+
+	if err := conn.Export(mprisRootIface{}, mprisPath, mprisRoot); err != nil {
+		return nil, err
+	}
+	if err := conn.ExportWithMap(m, mprisMethods, mprisPath, mprisPlayer); err != nil {
+		return nil, err
+	}
+
+	props, err := prop.Export(conn, mprisPath, m.properties())
+	if err != nil {
+		return nil, err
+	}
+	m.props = props
+
+	node := &introspect.Node{
+		Name: mprisPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       mprisRoot,
+				Methods:    introspect.Methods(mprisRootIface{}),
+				Properties: props.Introspection(mprisRoot),
+			},
+			{
+				Name:       mprisPlayer,
+				Methods:    mprisIntrospect(m),
+				Properties: props.Introspection(mprisPlayer),
+				Signals: []introspect.Signal{{
+					Name: "Seeked",
+					Args: []introspect.Arg{{Name: "Position", Type: "x"}},
+				}},
+			},
+		},
+	}
+	err = conn.Export(
+		introspect.NewIntrospectable(node),
+		mprisPath,
+		"org.freedesktop.DBus.Introspectable",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{
+		mprisName,
+		mprisName + ".instance" + strconv.Itoa(os.Getpid()),
+	} {
+		reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil {
+			return nil, err
+		}
+		if reply == dbus.RequestNameReplyPrimaryOwner {
+			m.name = name
+			go m.run()
+			return m, nil
+		}
+	}
+
+	return nil, errors.New("Could not acquire an MPRIS bus name")
+}
+
+func mprisIntrospect(v interface{}) []introspect.Method {
+	methods := introspect.Methods(v)
+	for i := range methods {
+		if n, ok := mprisMethods[methods[i].Name]; ok {
+			methods[i].Name = n
+		}
+	}
+
+	return methods
+}
+
+func (m *MPRIS) properties() map[string]map[string]*prop.Prop {
+	ro := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	rw := func(v interface{}, cb func(*prop.Change) *dbus.Error) *prop.Prop {
+		return &prop.Prop{Value: v, Writable: true, Emit: prop.EmitTrue, Callback: cb}
+	}
+
+	return map[string]map[string]*prop.Prop{
+		mprisRoot: {
+			"CanQuit":             ro(false),
+			"CanRaise":            ro(false),
+			"HasTrackList":        ro(false),
+			"Identity":            ro("ym"),
+			"SupportedUriSchemes": ro([]string{}),
+			"SupportedMimeTypes":  ro([]string{}),
+		},
+		mprisPlayer: {
+			"PlaybackStatus": ro("Stopped"),
+			"LoopStatus":     rw("None", m.setLoop),
+			"Rate":           rw(1.0, func(*prop.Change) *dbus.Error { return nil }),
+			"Shuffle":        rw(false, m.setShuffle),
+			"Metadata":       ro(m.metadata()),
+			"Volume":         rw(1.0, m.setVolume),
+			"Position":       {Value: int64(0), Emit: prop.EmitFalse},
+			"MinimumRate":    ro(1.0),
+			"MaximumRate":    ro(1.0),
+			"CanGoNext":      ro(true),
+			"CanGoPrevious":  ro(true),
+			"CanPlay":        ro(true),
+			"CanPause":       ro(true),
+			"CanSeek":        ro(true),
+			"CanControl":     ro(true),
+		},
+	}
+}
+
+func (m *MPRIS) send(cmd string) *dbus.Error {
+	t := time.NewTimer(mprisTimeout)
+	defer t.Stop()
+	select {
+	case m.queue <- command.New([]rune(cmd)).SetDone():
+		return nil
+	case <-t.C:
+		return dbus.MakeFailedError(errors.New("Player is not accepting commands"))
+	}
+}
+
+func (m *MPRIS) Next() *dbus.Error      { return m.send(">") }
+func (m *MPRIS) Previous() *dbus.Error  { return m.send("<") }
+func (m *MPRIS) Stop() *dbus.Error      { return m.Pause() }
+func (m *MPRIS) PlayPause() *dbus.Error { return m.send(".") }
+
+func (m *MPRIS) Play() *dbus.Error {
+	if m.ym.State() != StatePlay {
+		return m.send(".")
+	}
+	return nil
+}
+
+func (m *MPRIS) Pause() *dbus.Error {
+	if m.ym.State() == StatePlay {
+		return m.send(".")
+	}
+	return nil
+}
+
+// SeekRelative implements Seek, it seeks offset microseconds relative
+// to the current position.
+func (m *MPRIS) SeekRelative(offset int64) *dbus.Error {
+	pos := m.ym.clock.pos().Cur + time.Duration(offset)*time.Microsecond
+	if pos < 0 {
+		pos = 0
+	}
+	return m.seek(pos)
+}
+
+// SetPosition seeks to pos microseconds if track is the current track.
+func (m *MPRIS) SetPosition(track dbus.ObjectPath, pos int64) *dbus.Error {
+	cur := m.ym.Current()
+	if cur == nil || track != trackPath(cur.ID()) || pos < 0 {
+		return nil
+	}
+	return m.seek(time.Duration(pos) * time.Microsecond)
+}
+
+func (m *MPRIS) OpenUri(uri string) *dbus.Error {
+	return dbus.MakeFailedError(errors.New("OpenUri is not supported"))
+}
+
+func (m *MPRIS) seek(pos time.Duration) *dbus.Error {
+	return m.send(fmt.Sprintf(":seek %.3f", pos.Seconds()))
+}
+
+func (m *MPRIS) setLoop(c *prop.Change) *dbus.Error {
+	v, _ := c.Value.(string)
+	switch v {
+	case "None", "Track", "Playlist":
+	default:
+		return prop.ErrInvalidArg
+	}
+	if (v != "None") != m.ym.playlist.Repeat() {
+		return m.send(":repeat")
+	}
+	return nil
+}
+
+func (m *MPRIS) setShuffle(c *prop.Change) *dbus.Error {
+	if v, _ := c.Value.(bool); v != m.ym.playlist.Random() {
+		return m.send(":shuffle")
+	}
+	return nil
+}
+
+func (m *MPRIS) setVolume(c *prop.Change) *dbus.Error {
+	v, _ := c.Value.(float64)
+	v = math.Max(0, math.Min(1, v))
+	m.ym.sem.Lock()
+	cur := m.ym.volume
+	m.ym.sem.Unlock()
+	if cur < 0 {
+		return nil
+	}
+
+	steps := int(math.Round((v*100 - float64(cur)) / player.VolumeStep))
+	step := 1
+	if steps < 0 {
+		step, steps = -1, -steps
+	}
+	if steps > apiVolumeMax {
+		steps = apiVolumeMax
+	}
+	for i := 0; i < steps; i++ {
+		if err := m.send(fmt.Sprintf(":volume %d", step)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func trackPath(id string) dbus.ObjectPath {
+	return dbus.ObjectPath(mprisTrack + hex.EncodeToString([]byte(id)))
+}
+
+func (m *MPRIS) metadata() map[string]dbus.Variant {
+	cur := m.ym.Current()
+	if cur == nil {
+		return map[string]dbus.Variant{
+			"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(mprisTrack + "none")),
+		}
+	}
+
+	md := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(trackPath(cur.ID())),
+		"xesam:title":   dbus.MakeVariant(cur.Title()),
+		"xesam:url":     dbus.MakeVariant(cur.PageURL().String()),
+	}
+	if d := m.ym.duration(cur); d > 0 {
+		md["mpris:length"] = dbus.MakeVariant(d.Microseconds())
+	}
+	if info, err := cur.Info(); err == nil {
+		if a := info.Author(); a != "" {
+			md["xesam:artist"] = dbus.MakeVariant([]string{a})
+		}
+		if u := info.Thumbnail(); u != nil {
+			md["mpris:artUrl"] = dbus.MakeVariant(u.String())
+		}
+	}
+
+	return md
+}
+
+// set updates a property, emitting PropertiesChanged only if it changed.
+func (m *MPRIS) set(iface, name string, v interface{}) {
+	if fmt.Sprint(m.props.GetMust(iface, name)) != fmt.Sprint(v) {
+		m.props.SetMust(iface, name, v)
+	}
+}
+
+func (m *MPRIS) run() {
+	sub := m.ym.Events().Notify()
+	defer sub.Close()
+	seeks := m.ym.Events().NotifyOf(func(e Event) bool {
+		_, ok := e.(SeekEvent)
+		return ok
+	})
+	defer seeks.Close()
+	tick := time.NewTicker(mprisTick)
+	defer tick.Stop()
+	for {
+		m.update()
+		select {
+		case <-m.quit:
+			return
+		case <-sub.C:
+		case <-seeks.C:
+			m.update()
+			m.conn.Emit(
+				mprisPath,
+				mprisPlayer+".Seeked",
+				m.ym.clock.pos().Cur.Microseconds(),
+			)
+		case <-tick.C:
+		}
+	}
+}
+
+func (m *MPRIS) update() {
+	n := m.ym.NowPlaying()
+	loop := "None"
+	if n.Repeat {
+		loop = "Playlist"
+	}
+
+	m.set(mprisPlayer, "PlaybackStatus", mprisStatus[n.State])
+	m.set(mprisPlayer, "LoopStatus", loop)
+	m.set(mprisPlayer, "Shuffle", n.Random)
+	if n.Volume >= 0 {
+		m.set(mprisPlayer, "Volume", float64(n.Volume)/100)
+	}
+	m.props.SetMust(
+		mprisPlayer,
+		"Position",
+		int64(n.Position*float64(time.Second/time.Microsecond)),
+	)
+
+	var id string
+	if n.Current != nil {
+		id = n.Current.ID
+	}
+	m.sem.Lock()
+	changed := id != m.id
+	m.id = id
+	m.sem.Unlock()
+	if changed {
+		m.props.SetMust(mprisPlayer, "Metadata", m.metadata())
+	}
+}
+
+// Close releases the bus name and stops updating the properties.
+func (m *MPRIS) Close() error {
+	close(m.quit)
+	_, err := m.conn.ReleaseName(m.name)
+	return err
+}
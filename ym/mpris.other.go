@@ -0,0 +1,15 @@
+// +build !linux
+
+package ym
+
+import (
+	"errors"
+	"io"
+
+	"github.com/frizinak/ym/command"
+)
+
+// ServeMPRIS is only supported on linux.
+func (ym *YM) ServeMPRIS(queue chan<- *command.Command) (io.Closer, error) {
+	return nil, errors.New("MPRIS is only supported on linux")
+}
@@ -0,0 +1,192 @@
+// +build linux
+
+package ym
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"github.com/frizinak/ym/command"
+	"github.com/frizinak/ym/player"
+)
+
+// sessionBus starts a private dbus-daemon and returns its address.
+func sessionBus(t *testing.T) (string, func()) {
+	bin, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	cmd := exec.Command(bin, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		cmd.Process.Kill()
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(addr), func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+}
+
+func busConn(t *testing.T, addr string) *dbus.Conn {
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func TestMPRIS(t *testing.T) {
+	addr, stop := sessionBus(t)
+	defer stop()
+
+	ym, done := testYM(t)
+	defer done()
+
+	server := busConn(t, addr)
+	defer server.Close()
+	client := busConn(t, addr)
+	defer client.Close()
+
+	queue := make(chan *command.Command, 10)
+	m, err := ym.MPRIS(server, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	obj := client.Object(mprisName, mprisPath)
+	v, err := obj.GetProperty(mprisRoot + ".Identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value() != "ym" {
+		t.Errorf("identity %v", v.Value())
+	}
+
+	v, err = obj.GetProperty(mprisPlayer + ".PlaybackStatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Value() != "Stopped" {
+		t.Errorf("playback status %v", v.Value())
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	client.Signal(signals)
+	err = client.AddMatchSignal(
+		dbus.WithMatchObjectPath(mprisPath),
+		dbus.WithMatchInterface(mprisPlayer),
+		dbus.WithMatchMember("Seeked"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := func() *command.Command {
+		select {
+		case cmd := <-queue:
+			return cmd
+		case <-time.After(time.Second * 5):
+			t.Fatal("no command received")
+		}
+		return nil
+	}
+
+	if err := obj.Call(mprisPlayer+".PlayPause", 0).Err; err != nil {
+		t.Fatal(err)
+	}
+	if cmd := received(); !cmd.Pause() {
+		t.Errorf("PlayPause sent %s", cmd)
+	}
+
+	ym.clock.reset(0, time.Minute)
+	if err := obj.Call(mprisPlayer+".Seek", 0, int64(time.Second*5/time.Microsecond)).Err; err != nil {
+		t.Fatal(err)
+	}
+	cmd := received()
+	pos, ok := cmd.Seek()
+	if !ok || pos < time.Second*5 || pos > time.Second*6 {
+		t.Errorf("Seek sent %s", cmd)
+	}
+
+	// the player seeks and publishes the new position
+	ym.clock.set(pos)
+	ym.seeked()
+	select {
+	case s := <-signals:
+		if us, _ := s.Body[0].(int64); us < int64(pos/time.Microsecond) {
+			t.Errorf("Seeked to %dµs, expected %dµs", us, pos/time.Microsecond)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("Seeked was not emitted")
+	}
+}
+
+func TestMPRISVolume(t *testing.T) {
+	addr, stop := sessionBus(t)
+	defer stop()
+
+	ym, done := testYM(t)
+	defer done()
+	ym.volume = 50
+
+	server := busConn(t, addr)
+	defer server.Close()
+	client := busConn(t, addr)
+	defer client.Close()
+
+	// nothing reads the queue once it is full
+	queue := make(chan *command.Command, 20)
+	m, err := ym.MPRIS(server, queue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	obj := client.Object(mprisName, mprisPath)
+	if err := obj.SetProperty(mprisPlayer+".Volume", dbus.MakeVariant(1e6)); err != nil {
+		t.Fatal(err)
+	}
+	if n, exp := len(queue), 50/player.VolumeStep; n != exp {
+		t.Errorf("%d volume commands, expected %d", n, exp)
+	}
+	for len(queue) != 0 {
+		if cmd := <-queue; cmd.String() != ":volume 1" {
+			t.Errorf("Volume sent %s", cmd)
+		}
+	}
+
+	for i := 0; i < cap(queue); i++ {
+		queue <- command.New(nil)
+	}
+	start := time.Now()
+	if err := obj.Call(mprisPlayer+".Next", 0).Err; err == nil {
+		t.Error("expected an error when the queue is stuck")
+	}
+	if d := time.Since(start); d > mprisTimeout*2 {
+		t.Errorf("Next blocked for %s", d)
+	}
+}
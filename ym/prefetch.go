@@ -0,0 +1,142 @@
+package ym
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/frizinak/ym/search"
+)
+
+const (
+	// prefetchTTL is used for urls that don't specify when they expire.
+	prefetchTTL = time.Minute * 30
+	// prefetchMargin is subtracted from the expiry time of a url.
+	prefetchMargin = time.Minute * 5
+)
+
+type resolved struct {
+	url     *url.URL
+	expires time.Time
+}
+
+// prefetcher resolves stream urls of upcoming items in the background.
+type prefetcher struct {
+	sem     sync.Mutex
+	urls    map[string]*resolved
+	running bool
+	again   bool
+}
+
+// SetPrefetch enables resolving the stream urls of the next amount of
+// items in the background and, if download is not nil, passing them to
+// it to be cached.
+func (ym *YM) SetPrefetch(amount int, download func(search.Result)) {
+	ym.prefetchAmount = amount
+	ym.prefetchDownload = download
+}
+
+// prefetched returns the resolved url of id if it is still valid.
+func (ym *YM) prefetched(id string) *url.URL {
+	ym.prefetcher.sem.Lock()
+	defer ym.prefetcher.sem.Unlock()
+	r, ok := ym.prefetcher.urls[id]
+	if !ok {
+		return nil
+	}
+
+	if time.Now().After(r.expires) {
+		delete(ym.prefetcher.urls, id)
+		return nil
+	}
+
+	return r.url
+}
+
+// prefetch resolves the upcoming items, if a prefetch is already running
+// it will run again once it is done.
+func (ym *YM) prefetch() {
+	if ym.prefetchAmount <= 0 {
+		return
+	}
+
+	p := &ym.prefetcher
+	p.sem.Lock()
+	if p.running {
+		p.again = true
+		p.sem.Unlock()
+		return
+	}
+	p.running = true
+	p.sem.Unlock()
+
+	go func() {
+		for {
+			upcoming := ym.playlist.Upcoming(ym.prefetchAmount)
+			results := make([]search.Result, 0, len(upcoming))
+			keep := make(map[string]struct{}, len(upcoming))
+			for _, c := range upcoming {
+				if r := c.Result(); r != nil {
+					results = append(results, r)
+					keep[r.ID()] = struct{}{}
+				}
+			}
+
+			p.sem.Lock()
+			for id := range p.urls {
+				if _, ok := keep[id]; !ok {
+					delete(p.urls, id)
+				}
+			}
+			p.sem.Unlock()
+
+			for _, r := range results {
+				ym.prefetchOne(r)
+			}
+
+			p.sem.Lock()
+			if !p.again {
+				p.running = false
+				p.sem.Unlock()
+				return
+			}
+			p.again = false
+			p.sem.Unlock()
+		}
+	}()
+}
+
+func (ym *YM) prefetchOne(r search.Result) {
+	id := r.ID()
+	if ym.cache.Get(id) != nil || ym.prefetched(id) != nil {
+		return
+	}
+
+	urls, err := r.DownloadURLs(ym.formats)
+	if err != nil {
+		return
+	}
+
+	u, err := urls.FindContext(context.Background(), ym.preflight)
+	if err != nil {
+		return
+	}
+
+	ym.prefetcher.sem.Lock()
+	ym.prefetcher.urls[id] = &resolved{u, expires(u)}
+	ym.prefetcher.sem.Unlock()
+
+	if ym.prefetchDownload != nil {
+		ym.prefetchDownload(r)
+	}
+}
+
+func expires(u *url.URL) time.Time {
+	if e, err := strconv.ParseInt(u.Query().Get("expire"), 10, 64); err == nil {
+		return time.Unix(e, 0).Add(-prefetchMargin)
+	}
+
+	return time.Now().Add(prefetchTTL)
+}
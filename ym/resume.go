@@ -0,0 +1,117 @@
+package ym
+
+import (
+	"time"
+
+	"github.com/frizinak/ym/player"
+	"github.com/frizinak/ym/resume"
+	"github.com/frizinak/ym/search"
+)
+
+const (
+	resumeInterval = time.Second * 5
+	// Items that are stopped this close to their end are considered done.
+	resumeEndMargin = time.Second * 30
+)
+
+// SetResume enables remembering and resuming the playback position of
+// items that are at least min long.
+func (ym *YM) SetResume(positions *resume.Positions, min time.Duration) {
+	ym.positions = positions
+	ym.resumeMin = min
+}
+
+// SyncPosition should be called with positions reported by the player,
+// it publishes p with the duration corrected if it is known exactly.
+func (ym *YM) SyncPosition(p *player.Pos) {
+	ym.bus.Publish(PositionEvent{ym.clock.sync(p)})
+}
+
+// Position returns the (estimated) playback position of the current item.
+func (ym *YM) Position() *player.Pos {
+	return ym.clock.pos()
+}
+
+// startAt overrides the resume position of the next item to start.
+type startAt struct {
+	id  string
+	pos time.Duration
+}
+
+// resumeAt returns the position id should start at, a pending start
+// position or restart of id is only consumed if peek is false.
+func (ym *YM) resumeAt(id string, peek bool) time.Duration {
+	ym.sem.Lock()
+	at := ym.startAt
+	restart := ym.restart == id
+	if !peek && at.id == id {
+		ym.startAt = startAt{}
+	}
+	if !peek && restart {
+		ym.restart = ""
+	}
+	ym.sem.Unlock()
+	if at.id == id {
+		return at.pos
+	}
+
+	if ym.positions == nil {
+		return 0
+	}
+
+	if restart {
+		if !peek {
+			ym.positions.Del(id)
+		}
+		return 0
+	}
+
+	return ym.positions.Get(id)
+}
+
+// pendingStart reports whether id has a start position or restart that
+// wasn't consumed yet.
+func (ym *YM) pendingStart(id string) bool {
+	ym.sem.Lock()
+	defer ym.sem.Unlock()
+	return ym.startAt.id == id || ym.restart == id
+}
+
+func (ym *YM) restartCurrent() bool {
+	cur := ym.Current()
+	if cur == nil {
+		return false
+	}
+
+	ym.sem.Lock()
+	ym.restart = cur.ID()
+	ym.sem.Unlock()
+	ym.playlist.SetIndex(ym.playlist.Index())
+	return true
+}
+
+func (ym *YM) rememberPosition(r search.Result) {
+	if ym.positions == nil || r == nil {
+		return
+	}
+
+	pos := ym.clock.pos()
+	dur := pos.Dur
+	if dur == 0 {
+		dur = ym.duration(r)
+	}
+	if dur == 0 {
+		return
+	}
+
+	if dur < ym.resumeMin {
+		return
+	}
+
+	if dur-pos.Cur < resumeEndMargin {
+		ym.positions.Del(r.ID())
+		return
+	}
+
+	ym.positions.Set(r.ID(), pos.Cur)
+}
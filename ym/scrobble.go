@@ -0,0 +1,82 @@
+package ym
+
+import (
+	"time"
+
+	"github.com/frizinak/ym/scrobble"
+	"github.com/frizinak/ym/search"
+)
+
+// scrobbling is the item that is playing and how long it played.
+type scrobbling struct {
+	result search.Result
+	at     time.Time
+	played time.Duration
+	since  time.Time
+}
+
+func (s *scrobbling) pause(now time.Time) {
+	if !s.since.IsZero() {
+		s.played += now.Sub(s.since)
+		s.since = time.Time{}
+	}
+}
+
+// Scrobble submits the items Play played long enough to s,
+// it never returns. Listens are built in the background as the info
+// of an item might have to be fetched.
+func (ym *YM) Scrobble(s *scrobble.Scrobbler) {
+	sub := ym.bus.Subscribe(100)
+	defer sub.Close()
+
+	var cur *scrobbling
+	for e := range sub.C {
+		now := time.Now()
+		switch e := e.(type) {
+		case TrackEvent:
+			if cur != nil {
+				cur.pause(now)
+				go func(cur *scrobbling) {
+					l := ym.listen(cur.result, cur.at)
+					if scrobble.Scrobblable(l.Duration, cur.played) {
+						s.Scrobble(l)
+					}
+				}(cur)
+				cur = nil
+			}
+			if e.Track != nil {
+				cur = &scrobbling{result: e.Track, at: now, since: now}
+				go func(r search.Result, at time.Time) {
+					s.NowPlaying(ym.listen(r, at))
+				}(e.Track, now)
+			}
+		case StateEvent:
+			if cur == nil {
+				continue
+			}
+			if e.State == StatePlay {
+				if cur.since.IsZero() {
+					cur.since = now
+				}
+				continue
+			}
+			cur.pause(now)
+		}
+	}
+}
+
+func (ym *YM) listen(r search.Result, at time.Time) *scrobble.Listen {
+	var author string
+	if info, err := r.Info(); err == nil {
+		author = info.Author()
+	}
+	artist, track := scrobble.ParseTitle(r.Title(), author)
+
+	return &scrobble.Listen{
+		Artist:   artist,
+		Track:    track,
+		Duration: ym.duration(r),
+		URL:      r.PageURL().String(),
+		At:       at,
+	}
+}
@@ -0,0 +1,181 @@
+package ym
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/frizinak/ym/player"
+)
+
+// sleepFadeSteps is the amount of volume steps used to fade out.
+const sleepFadeSteps = 20
+
+type sleep struct {
+	at  time.Time
+	end bool
+	// amount of volume steps faded out so far.
+	faded int
+	// amount of volume steps to restore once a new player is spawned.
+	restore int
+}
+
+// SetSleepFade sets the duration over which the volume is faded out
+// before the sleep timer stops playback.
+func (ym *YM) SetSleepFade(fade time.Duration) {
+	ym.sleepFade = fade
+}
+
+// SyncVolume should be called with the volume reported by the player.
+func (ym *YM) SyncVolume(volume int) {
+	ym.sem.Lock()
+	ym.volume = volume
+	ym.sem.Unlock()
+	ym.bus.Publish(VolumeEvent{volume})
+}
+
+// Sleep returns the time left until the sleep timer stops playback.
+// ok is false if no timer is active and atEnd is true if playback will
+// stop after the current item, in which case remaining is negative
+// if the duration of the item is not known.
+func (ym *YM) Sleep() (remaining time.Duration, atEnd, ok bool) {
+	ym.sem.Lock()
+	s := ym.sleep
+	ym.sem.Unlock()
+	return ym.sleepRemaining(s)
+}
+
+func (ym *YM) sleepRemaining(s sleep) (remaining time.Duration, atEnd, ok bool) {
+	switch {
+	case s.end:
+		pos := ym.clock.pos()
+		if pos.Dur == 0 {
+			return -1, true, true
+		}
+		return pos.Dur - pos.Cur, true, true
+	case !s.at.IsZero():
+		return time.Until(s.at), false, true
+	}
+
+	return 0, false, false
+}
+
+// setSleep parses the argument of the sleep command and returns the
+// commands needed to restore the volume if a fade out was cancelled.
+func (ym *YM) setSleep(arg string) ([]player.Command, error) {
+	s := sleep{}
+	switch arg {
+	case "off":
+	case "end":
+		s.end = true
+	default:
+		d, err := parseSleep(arg)
+		if err != nil {
+			return nil, err
+		}
+		s.at = time.Now().Add(d)
+	}
+
+	ym.sem.Lock()
+	restore := ym.sleep.faded
+	s.restore = ym.sleep.restore
+	ym.sleep = s
+	ym.sem.Unlock()
+
+	return repeat(player.CmdVolUp, restore), nil
+}
+
+// sleepStep returns the volume commands needed to progress the fade out,
+// expired is true when playback should be paused.
+func (ym *YM) sleepStep() (cmds []player.Command, expired bool) {
+	ym.sem.Lock()
+	defer ym.sem.Unlock()
+	remaining, atEnd, ok := ym.sleepRemaining(ym.sleep)
+	if !ok || remaining < 0 && atEnd {
+		return nil, false
+	}
+
+	if !atEnd && remaining <= 0 {
+		return nil, true
+	}
+
+	if remaining > ym.sleepFade {
+		return nil, false
+	}
+
+	want := sleepFadeSteps
+	if ym.sleepFade > 0 {
+		pct := float64(ym.sleepFade-remaining) / float64(ym.sleepFade)
+		want = int(pct*sleepFadeSteps + 0.999)
+	}
+	for ym.sleep.faded < want && ym.volume != 0 {
+		cmds = append(cmds, player.CmdVolDown)
+		ym.sleep.faded++
+	}
+
+	return cmds, false
+}
+
+// sleepExpire disables the sleep timer and returns the commands needed
+// to restore the volume.
+func (ym *YM) sleepExpire() []player.Command {
+	ym.sem.Lock()
+	n := ym.sleep.faded
+	ym.sleep = sleep{}
+	ym.sem.Unlock()
+	return repeat(player.CmdVolUp, n)
+}
+
+// sleepAtEnd is called when an item ended and reports whether playback
+// should hold. The volume will be restored once a new player is spawned.
+func (ym *YM) sleepAtEnd() bool {
+	ym.sem.Lock()
+	defer ym.sem.Unlock()
+	if !ym.sleep.end {
+		return false
+	}
+
+	ym.sleep = sleep{restore: ym.sleep.faded}
+	return true
+}
+
+// wakeUp resumes playback held by sleepAtEnd.
+func (ym *YM) wakeUp() {
+	select {
+	case ym.wake <- struct{}{}:
+	default:
+	}
+}
+
+// sleepRestore returns the volume commands to send to a newly spawned
+// player. Players that don't keep their volume start at their default
+// one, so a fade out in progress starts over.
+func (ym *YM) sleepRestore() []player.Command {
+	ym.sem.Lock()
+	defer ym.sem.Unlock()
+	if k, ok := ym.player.(player.VolumeKeeper); !ok || !k.KeepsVolume() {
+		ym.sleep.restore = 0
+		ym.sleep.faded = 0
+		return nil
+	}
+
+	n := ym.sleep.restore
+	ym.sleep.restore = 0
+	return repeat(player.CmdVolUp, n)
+}
+
+// parseSleep parses a duration, plain numbers are minutes.
+func parseSleep(arg string) (time.Duration, error) {
+	if m, err := strconv.Atoi(arg); err == nil {
+		return time.Duration(m) * time.Minute, nil
+	}
+
+	return time.ParseDuration(arg)
+}
+
+func repeat(c player.Command, n int) []player.Command {
+	cmds := make([]player.Command, n)
+	for i := range cmds {
+		cmds[i] = c
+	}
+	return cmds
+}
@@ -0,0 +1,67 @@
+package ym
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frizinak/ym/player"
+)
+
+type volumePlayer struct {
+	player.Player
+	keeps bool
+}
+
+func (v volumePlayer) KeepsVolume() bool { return v.keeps }
+
+func TestSleepRestore(t *testing.T) {
+	for _, keeps := range []bool{true, false} {
+		ym := New(nil, nil, volumePlayer{keeps: keeps}, nil, nil, nil)
+		ym.volume = 100
+		ym.clock.reset(0, time.Minute)
+		if _, err := ym.setSleep("end"); err != nil {
+			t.Fatal(err)
+		}
+		ym.SetSleepFade(time.Hour)
+
+		cmds, _ := ym.sleepStep()
+		if len(cmds) != sleepFadeSteps {
+			t.Fatalf("faded %d steps, expected %d", len(cmds), sleepFadeSteps)
+		}
+		if !ym.sleepAtEnd() {
+			t.Fatal("expected playback to hold")
+		}
+
+		restore := ym.sleepRestore()
+		if keeps && len(restore) != sleepFadeSteps {
+			t.Errorf("restored %d steps, expected %d", len(restore), sleepFadeSteps)
+		}
+		if !keeps && len(restore) != 0 {
+			t.Errorf("restored %d steps on a player that starts at its default volume", len(restore))
+		}
+		if restore := ym.sleepRestore(); len(restore) != 0 {
+			t.Errorf("restored %d steps twice", len(restore))
+		}
+	}
+}
+
+func TestSleepFadeRestarts(t *testing.T) {
+	ym := New(nil, nil, volumePlayer{}, nil, nil, nil)
+	ym.volume = 100
+	ym.SetSleepFade(time.Minute)
+	if _, err := ym.setSleep("30s"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := ym.sleepStep()
+	if len(first) == 0 {
+		t.Fatal("expected the fade out to start")
+	}
+
+	// a new player starts at its default volume and is faded again
+	ym.sleepRestore()
+	second, _ := ym.sleepStep()
+	if len(second) < len(first) {
+		t.Errorf("faded %d steps after a new player spawned, expected %d", len(second), len(first))
+	}
+}
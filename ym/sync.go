@@ -0,0 +1,62 @@
+package ym
+
+import (
+	"errors"
+
+	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/search"
+)
+
+var errNotLinked = errors.New("Queue is not linked to a playlist")
+
+// SetSync configures Sync to load up to max items of the linked playlist
+// and to remove items that were removed from it if remove is true, in
+// which case the whole playlist is loaded regardless of max.
+func (ym *YM) SetSync(max int, remove bool) {
+	ym.syncMax = max
+	ym.syncRemove = remove
+}
+
+// Link links the queue to the remote playlist with the given id or url,
+// an empty string removes the link.
+func (ym *YM) Link(list string) error {
+	return ym.playlist.SetLink(search.ParsePlaylistID(list))
+}
+
+// Linked returns whether the queue is linked to a remote playlist.
+func (ym *YM) Linked() bool {
+	return ym.playlist.Link() != ""
+}
+
+// Sync fetches the linked playlist and updates the queue accordingly,
+// see playlist.Playlist.Sync.
+func (ym *YM) Sync() (playlist.Diff, error) {
+	ym.syncer.Lock()
+	defer ym.syncer.Unlock()
+
+	list := ym.playlist.Link()
+	if list == "" {
+		return playlist.Diff{}, errNotLinked
+	}
+
+	max := ym.syncMax
+	if ym.syncRemove {
+		// Anything beyond max would be considered removed.
+		max = 0
+	}
+
+	results, err := ym.search.Playlist(list, max)
+	if err != nil {
+		return playlist.Diff{}, err
+	}
+	if len(results) == 0 {
+		return playlist.Diff{}, errors.New("Playlist is empty or does not exist")
+	}
+
+	diff := ym.playlist.Sync(results, ym.syncRemove)
+	if !diff.Empty() {
+		ym.prefetch()
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,88 @@
+package ym
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/search"
+)
+
+// playlistEngine serves a single playlist of n items.
+type playlistEngine struct {
+	n   int
+	max int
+}
+
+func (e *playlistEngine) Search(q string, page int) ([]search.Result, error) { return nil, nil }
+func (e *playlistEngine) Page(u string, page int) ([]search.Result, error)   { return nil, nil }
+
+func (e *playlistEngine) Playlist(id string, max int) ([]search.Result, error) {
+	e.max = max
+	n := e.n
+	if max > 0 && max < n {
+		n = max
+	}
+
+	results := make([]search.Result, n)
+	for i := range results {
+		results[i] = search.NewYoutubeResult(fmt.Sprintf("video%03d", i), "")
+	}
+
+	return results, nil
+}
+
+func TestSyncBeyondMax(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ym-sync-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	updates := make(chan struct{})
+	go func() {
+		for range updates {
+		}
+	}()
+	defer close(updates)
+
+	pl := playlist.New(filepath.Join(dir, "playlist"), 10, updates)
+	e := &playlistEngine{n: 30}
+	ym := New(pl, e, nil, nil, nil, nil)
+	if err := ym.Link("PLtest"); err != nil {
+		t.Fatal(err)
+	}
+
+	ym.SetSync(20, false)
+	diff, err := ym.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.max != 20 || len(diff.Added) != 20 {
+		t.Errorf("fetched up to %d and added %d items, expected 20", e.max, len(diff.Added))
+	}
+
+	ym.SetSync(20, true)
+	diff, err = ym.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.max != 0 {
+		t.Errorf("fetched up to %d items, expected the whole playlist", e.max)
+	}
+	if len(diff.Added) != 10 || len(diff.Removed) != 0 {
+		t.Errorf("added %d and removed %d items, expected 10 and 0", len(diff.Added), len(diff.Removed))
+	}
+
+	e.n = 25
+	diff, err = ym.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Removed) != 5 || pl.Length() != 25 {
+		t.Errorf("removed %d items leaving %d, expected 5 and 25", len(diff.Removed), pl.Length())
+	}
+}
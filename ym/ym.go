@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/frizinak/ym/cache"
 	"github.com/frizinak/ym/command"
 	"github.com/frizinak/ym/player"
 	"github.com/frizinak/ym/playlist"
+	"github.com/frizinak/ym/resume"
 	"github.com/frizinak/ym/search"
 )
 
@@ -19,11 +22,48 @@ type YM struct {
 	player   player.Player
 	cache    *cache.Cache
 
-	state   string
+	bus     *Bus
+	status  sync.RWMutex
+	state   State
 	current search.Result
+	played  time.Duration
+	playing time.Time
 	addr    *net.TCPAddr
 
-	preflights int
+	preflight *search.Preflight
+	formats   *search.FormatPolicy
+
+	normalize Normalize
+	target    float64
+
+	clock     clock
+	positions *resume.Positions
+	resumeMin time.Duration
+	restart   string
+	startAt   startAt
+	chapters  chapters
+
+	gapless   bool
+	crossfade time.Duration
+
+	prefetcher       prefetcher
+	prefetchAmount   int
+	prefetchDownload func(search.Result)
+
+	syncer     sync.Mutex
+	syncMax    int
+	syncRemove bool
+
+	volume    int
+	sleep     sleep
+	sleepFade time.Duration
+	wake      chan struct{}
+
+	sem        sync.Mutex
+	analyzing  map[string]struct{}
+	inspecting map[string]struct{}
+
+	metrics ymMetrics
 }
 
 func New(
@@ -32,41 +72,35 @@ func New(
 	player player.Player,
 	cache *cache.Cache,
 	sock *net.TCPAddr,
-	downloadPreflights int,
+	preflight *search.Preflight,
 ) *YM {
 	return &YM{
-		playlist,
-		search,
-		player,
-		cache,
-		"stop",
-		nil,
-		sock,
-		downloadPreflights,
+		playlist:   playlist,
+		search:     search,
+		player:     player,
+		cache:      cache,
+		bus:        NewBus(),
+		addr:       sock,
+		preflight:  preflight,
+		volume:     -1,
+		wake:       make(chan struct{}),
+		prefetcher: prefetcher{urls: make(map[string]*resolved)},
+		analyzing:  make(map[string]struct{}),
+		inspecting: make(map[string]struct{}),
 	}
 }
 
-func (ym *YM) ExecSearch(q string, amount int) ([]search.Result, error) {
-	results := make([]search.Result, 0, amount)
-	page := 0
-	for {
-		_results, err := ym.search.Search(q, page)
-		if err != nil {
-			return nil, err
-		}
-
-		page++
-		results = append(results, _results...)
-		if len(_results) == 0 || len(results) >= amount {
-			break
-		}
-	}
+// SetFormatPolicy sets the preference of the formats that are streamed.
+func (ym *YM) SetFormatPolicy(p *search.FormatPolicy) {
+	ym.formats = p
+}
 
-	return results, nil
+func (ym *YM) ExecSearch(q string, amount int) ([]search.Result, error) {
+	return search.Collect(ym.search, q, amount)
 }
 
-func (ym *YM) ExecPage(url string) ([]search.Result, error) {
-	return ym.search.Page(url)
+func (ym *YM) ExecPage(url string, page int) ([]search.Result, error) {
+	return ym.search.Page(url, page)
 }
 
 func (ym *YM) Listen() error {
@@ -97,18 +131,21 @@ func (ym *YM) Listen() error {
 				case "status":
 					msg = []string{
 						"volume: -1",
-						"repeat: 0",
-						"random: 0",
+						fmt.Sprintf("repeat: %d", boolInt(ym.playlist.Repeat())),
+						fmt.Sprintf("random: %d", boolInt(ym.playlist.Random())),
 						"single: 0",
 						"consume: 0",
 						"playlist: 1",
 						fmt.Sprintf("playlistlength: %d", ym.playlist.Length()),
 						"mixrampdb: 0.000000",
-						fmt.Sprintf("state: %s", ym.state),
+						fmt.Sprintf("state: %s", ym.State()),
 					}
 
+				case "idle":
+					msg = []string{"changed: " + ym.idle()}
+
 				case "currentsong":
-					cur := ym.current
+					cur := ym.Current()
 					if cur == nil {
 						break
 					}
@@ -116,6 +153,7 @@ func (ym *YM) Listen() error {
 					if err != nil {
 						break
 					}
+					dur := ym.duration(cur)
 
 					msg = []string{
 						fmt.Sprintf("file: %s", cur.PageURL().String()),
@@ -126,8 +164,8 @@ func (ym *YM) Listen() error {
 						fmt.Sprintf("Date: %d", info.Created().Year()),
 						"Genre: -",
 						"Composer: -",
-						fmt.Sprintf("Time: %d", int(info.Duration().Seconds())),
-						fmt.Sprintf("duration: %0.3f", info.Duration().Seconds()),
+						fmt.Sprintf("Time: %d", int(dur.Seconds())),
+						fmt.Sprintf("duration: %0.3f", dur.Seconds()),
 						"Pos: 1",
 						"Id: -",
 					}
@@ -142,30 +180,98 @@ func (ym *YM) Listen() error {
 	}
 }
 
-func (ym *YM) Play(
-	queue <-chan *command.Command,
-	current chan<- search.Result,
-	status chan<- string,
-	errs chan<- error,
-	quit <-chan struct{},
-) error {
+// Play handles commands from queue until quit receives, state changes
+// and errors are published on Events.
+func (ym *YM) Play(queue <-chan *command.Command, quit <-chan struct{}) error {
 	var commands chan player.Command
 
-	iq := make(chan *command.Command)
-	wait := make(chan func())
+	iq := make(chan *handoff)
+	wait := make(chan *playing)
+	faded := make(chan *fadedIn)
 	go func() {
+		var after <-chan struct{}
 		for {
-			iq <- ym.playlist.Read()
-			if w := <-wait; w != nil {
-				w()
+			iq <- &handoff{ym.playlist.Read(), after}
+			after = nil
+			if p := <-wait; p != nil {
+				select {
+				case <-p.fading:
+					if _, atEnd, _ := ym.Sleep(); !atEnd {
+						// it stays current until it ended, see faded
+						after = p.done
+						continue
+					}
+					<-p.done
+				case <-p.done:
+				}
+				ym.rememberPosition(ym.Current())
+			}
+			ym.setState(StateStop)
+			ym.setCurrent(nil)
+			if ym.sleepAtEnd() {
+				<-ym.wake
 			}
-			ym.state = "stop"
-			status <- "■"
-			current <- nil
-			ym.current = nil
 		}
 	}()
 
+	var next *queued
+	prep := make(chan *prepared)
+
+	dropNext := func() {
+		if next == nil {
+			return
+		}
+		next.commands <- player.CmdStop
+		close(next.commands)
+		next = nil
+	}
+
+	prepare := func() {
+		n := ym.playlist.Peek()
+		if ym.appender() == nil || ym.Current() == nil || n == nil || n.Result() == nil {
+			return
+		}
+
+		if next != nil {
+			if next.result.ID() == n.Result().ID() {
+				return
+			}
+			dropNext()
+		}
+
+		go ym.prepare(n.Result(), prep)
+	}
+
+	send := func(cmds ...player.Command) {
+		for _, c := range cmds {
+			if commands == nil {
+				return
+			}
+
+			commands <- c
+			if c == player.CmdStop {
+				close(commands)
+				commands = nil
+				dropNext()
+			}
+		}
+	}
+
+	started := func(result search.Result, start time.Duration) {
+		ym.clock.reset(start, ym.knownDuration(result.ID()))
+		ym.loadChapters(result)
+		ym.setState(StatePlay)
+		ym.setCurrent(result)
+		if start > 0 {
+			ym.seeked()
+		}
+	}
+
+	tick := time.NewTicker(resumeInterval)
+	defer tick.Stop()
+	sleepTick := time.NewTicker(time.Second)
+	defer sleepTick.Stop()
+
 	for {
 		select {
 		case <-quit:
@@ -173,57 +279,109 @@ func (ym *YM) Play(
 				commands <- player.CmdStop
 			}
 			return nil
-		case c := <-iq:
-			result := c.Result()
+		case <-tick.C:
+			if ym.State() == StatePlay {
+				go ym.rememberPosition(ym.Current())
+			}
+		case <-sleepTick.C:
+			cmds, expired := ym.sleepStep()
+			send(cmds...)
+			if !expired {
+				continue
+			}
+
+			if ym.State() == StatePlay {
+				send(player.CmdPause)
+				ym.setState(StatePause)
+				ym.clock.pause(true)
+			}
+			send(ym.sleepExpire()...)
+		case p := <-prep:
+			n := ym.playlist.Peek()
+			appender := ym.appender()
+			if p.err != nil || appender == nil || next != nil ||
+				ym.Current() == nil || n == nil || n.Result() == nil ||
+				n.Result().ID() != p.result.ID() {
+				continue
+			}
+
+			cmds, w, err := appender.Append(p.file, p.params)
+			if err != nil {
+				continue
+			}
+			next = &queued{p.result, p.start, cmds, w, p.cached}
+
+		case f := <-faded:
+			if f.commands != commands {
+				// stopped while the previous item was fading out
+				continue
+			}
+			ym.rememberPosition(ym.Current())
+			started(f.result, f.start)
+			prepare()
+			ym.prefetch()
+
+		case h := <-iq:
+			result := h.cmd.Result()
 			if result == nil {
 				continue
 			}
 
-			var file string
-			// TODO
-			// if c.Cmd() != '!' {
-			cached := ym.cache.Get(result.ID())
-			if cached != nil {
-				file = cached.Path()
+			if commands != nil {
+				close(commands)
+				commands = nil
 			}
-			// }
 
-			if file == "" {
-				u, err := result.DownloadURLs()
-				if err != nil {
-					errs <- err
-					wait <- nil
-					continue
-				}
-				du, err := u.Find(ym.preflights)
+			var start time.Duration
+			var err error
+			var p *playing
+
+			if next != nil && next.result.ID() == result.ID() &&
+				!ym.pendingStart(result.ID()) {
+				commands, start = next.commands, next.start
+				p = newPlaying(nil, next.wait)
+				ym.countCached(next.cached)
+				next = nil
+			} else {
+				dropNext()
+				var file string
+				var params []player.Param
+				file, params, start, err = ym.resolve(result, false)
 				if err != nil {
-					errs <- err
+					ym.error(err)
 					wait <- nil
 					continue
 				}
-				file = du.String()
-			}
 
-			params := []player.Param{player.ParamSilent}
+				commands, p, err = ym.spawn(file, params)
+			}
 
-			// TODO
-			// if c.Cmd() != '!' {
-			params = append(params, player.ParamNoVideo)
-			// }
+			if h.after != nil && err == nil {
+				wait <- p
+				send(ym.sleepRestore()...)
+				go func(f *fadedIn) {
+					select {
+					case <-h.after:
+					case <-quit:
+						return
+					}
+					select {
+					case faded <- f:
+					case <-quit:
+					}
+				}(&fadedIn{result, start, commands})
+				continue
+			}
 
-			var err error
-			var w func()
-
-			commands, w, err = ym.player.Spawn(file, params)
-			ym.state = "play"
-			status <- "▶"
-			current <- result
-			wait <- w
-			ym.current = result
+			started(result, start)
+			wait <- p
 			if err != nil {
-				errs <- err
+				ym.error(err)
 				continue
 			}
+			prepare()
+			ym.prefetch()
+			send(ym.sleepRestore()...)
 
 		case cmd := <-queue:
 			var c player.Command = player.CmdNil
@@ -235,10 +393,14 @@ func (ym *YM) Play(
 			if cmd.Next() {
 				ym.playlist.Next(1)
 				c = player.CmdStop
+				ym.skipped()
+				ym.wakeUp()
 
 			} else if cmd.Prev() {
 				ym.playlist.Prev(1)
 				c = player.CmdStop
+				ym.skipped()
+				ym.wakeUp()
 
 			} else if from, to := cmd.Move(); from != 0 && to != 0 {
 				ym.playlist.Move(from-1, to-1)
@@ -258,15 +420,21 @@ func (ym *YM) Play(
 				ym.playlist.Truncate()
 				c = player.CmdStop
 
+			} else if cmd.Restart() {
+				if ym.restartCurrent() {
+					c = player.CmdStop
+				}
+
 			} else if cmd.Pause() {
-				switch ym.state {
-				case "pause":
-					status <- "▶"
-					ym.state = "play"
-				case "play":
-					status <- "⏸"
-					ym.state = "pause"
+				switch ym.State() {
+				case StatePause:
+					ym.setState(StatePlay)
+				case StatePlay:
+					ym.setState(StatePause)
+				case StateStop:
+					ym.wakeUp()
 				}
+				ym.clock.pause(ym.State() == StatePause)
 
 				c = player.CmdPause
 
@@ -281,22 +449,71 @@ func (ym *YM) Play(
 
 			} else if cmd.SeekBack() {
 				c = player.CmdSeekBackward
+				ym.clock.seek(-player.SeekStep)
+				ym.seeked()
 			} else if cmd.SeekForward() {
 				c = player.CmdSeekForward
+				ym.clock.seek(player.SeekStep)
+				ym.seeked()
+
+			} else if pos, ok := cmd.Seek(); ok {
+				c = ym.seekTo(pos)
+
+			} else if cmd.NextChapter() {
+				var ok bool
+				if c, ok = ym.chapter(1); !ok {
+					ym.playlist.Next(1)
+					c = player.CmdStop
+					ym.skipped()
+				}
+			} else if cmd.PrevChapter() {
+				c, _ = ym.chapter(-1)
+
+			} else if arg := cmd.Sleep(); arg != "" {
+				cmds, err := ym.setSleep(arg)
+				if err != nil {
+					ym.error(err)
+					continue
+				}
+				send(cmds...)
 
 			} else if cmd.Rand() {
 				ym.playlist.ToggleRandom()
+
+			} else if cmd.Repeat() {
+				ym.playlist.ToggleRepeat()
 			}
 
 			if c != player.CmdNil {
-				if commands != nil {
-					commands <- c
-					if c == player.CmdStop {
-						close(commands)
-						commands = nil
-					}
-				}
+				send(c)
 			}
+			prepare()
+			ym.prefetch()
 		}
 	}
 }
+
+// idle waits for a change and returns the mpd subsystem it belongs to.
+func (ym *YM) idle() string {
+	sub := ym.bus.Subscribe(10)
+	defer sub.Close()
+	for e := range sub.C {
+		switch e.(type) {
+		case StateEvent, TrackEvent:
+			return "player"
+		case VolumeEvent:
+			return "mixer"
+		case PlaylistEvent:
+			return "playlist"
+		}
+	}
+
+	return ""
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}